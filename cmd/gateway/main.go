@@ -1,17 +1,35 @@
 package main
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"log/slog"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
+	"sync"
 	"syscall"
+	"time"
 
-	resendclient "github.com/igorrius/resend-railway-gateway/internal/adapters/resend"
+	goSMTP "github.com/emersion/go-smtp"
+	"github.com/igorrius/resend-railway-gateway/internal/adapters/admin"
+	"github.com/igorrius/resend-railway-gateway/internal/adapters/capture"
+	"github.com/igorrius/resend-railway-gateway/internal/adapters/httpapi"
 	smtpserver "github.com/igorrius/resend-railway-gateway/internal/adapters/smtp"
+	"github.com/igorrius/resend-railway-gateway/internal/adapters/webhook"
 	"github.com/igorrius/resend-railway-gateway/internal/app"
 	"github.com/igorrius/resend-railway-gateway/internal/config"
+	"github.com/igorrius/resend-railway-gateway/internal/delivery"
+	"github.com/igorrius/resend-railway-gateway/internal/domain"
+	"github.com/igorrius/resend-railway-gateway/internal/gateway"
+	"github.com/igorrius/resend-railway-gateway/internal/listener"
 	"github.com/igorrius/resend-railway-gateway/internal/logging"
+	"github.com/igorrius/resend-railway-gateway/internal/proxyproto"
+	"github.com/igorrius/resend-railway-gateway/internal/ratelimit"
+	"github.com/igorrius/resend-railway-gateway/internal/suppression"
 )
 
 func main() {
@@ -19,30 +37,196 @@ func main() {
 	root := logging.NewConfiguredLogger()
 	slog.SetDefault(root)
 
-	cfg, err := config.Load()
+	cfg, err := loadConfig()
 	if err != nil {
 		root.Error("config_load_failed", "error", err)
 		os.Exit(1)
 	}
-	// optional override for Railway dynamic ports
-	if v := os.Getenv("PORT"); v != "" {
-		cfg.SMTPListerAddr = ":" + v
+
+	if cfg.Provider == config.ProviderCapture && cfg.ProviderImplicit {
+		// A deployment that lost its API key would otherwise accept mail
+		// without sending it.
+		root.Warn("capture_mode_no_api_key",
+			"detail", "RESEND_API_KEY and RESEND_ROUTES are unset: emails are captured, NOT sent; set PROVIDER=capture to silence this warning",
+			"capture_ui", cfg.HTTPListenAddr)
 	}
 
-	sender := resendclient.NewClient(cfg.ResendAPIKey)
-	svc := app.NewService(sender, logging.New(root), cfg.SendTimeout)
-	server := smtpserver.NewServer(cfg.SMTPListerAddr, svc)
+	logger := logging.New(root)
+	gw, err := gateway.New(cfg, root, logger)
+	if err != nil {
+		root.Error("config_load_failed", "error", err)
+		os.Exit(1)
+	}
+	svc, suppressions := gw.Service, gw.Suppressions
+	mux := http.NewServeMux()
+	serveHTTP := false
+	if gw.Capture != nil {
+		mux.Handle("/", capture.NewHandler(gw.Capture))
+		serveHTTP = true
+	}
+
+	var adm *admin.Handler
+	if cfg.AdminToken != "" {
+		adm = admin.NewHandler(cfg.AdminToken, suppressions, logger)
+		mux.Handle("/api/suppressions", adm)
+		mux.Handle("/api/suppressions/", adm)
+		serveHTTP = true
+	}
+	if gw.Verifier != nil {
+		whOpts := []webhook.Option{webhook.WithListener(suppressOnEvent(suppressions, logger))}
+		if cfg.DSNFrom != "" {
+			from, err := domain.ParseAddress(cfg.DSNFrom)
+			if err != nil {
+				root.Error("config_load_failed", "error", fmt.Errorf("DSN_FROM: %w", err))
+				os.Exit(1)
+			}
+			bouncer := app.NewBouncer(svc, logger, from, cfg.DSNReportingMTA)
+			// Notifications are sent outside the webhook request so that
+			// Resend does not time out waiting for the acknowledgement.
+			whOpts = append(whOpts, webhook.WithListener(func(ev delivery.Event, rec delivery.Record) {
+				go bouncer.Notify(ev, rec)
+			}))
+		}
+		wh := webhook.NewHandler(gw.Verifier, gw.Tracker, logger, whOpts...)
+		mux.Handle("/webhooks/", wh)
+		// Statuses name senders and recipients: they are served to
+		// operators only.
+		if adm != nil {
+			mux.Handle("/api/status/", adm.Authorize(wh))
+		}
+		serveHTTP = true
+	}
+	policy, err := connPolicy(cfg)
+	if err != nil {
+		root.Error("config_load_failed", "error", err)
+		os.Exit(1)
+	}
+	limiter := rateLimiter(cfg)
+	if cfg.RateLimitStateFile != "" {
+		if err := limiter.Load(cfg.RateLimitStateFile); err != nil {
+			root.Error("rate_limit_state_load_failed", "error", err)
+		}
+	}
+	var api *httpapi.Handler
+	if len(cfg.APITokens) > 0 {
+		api = httpapi.NewHandler(svc, cfg.APITokens, logger, httpapi.WithRateLimits(limiter))
+		mux.Handle("/emails", api)
+		mux.Handle("/emails/", api)
+		serveHTTP = true
+	}
+	var httpServer *http.Server
+	if serveHTTP {
+		httpServer = &http.Server{Addr: cfg.HTTPListenAddr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	}
+	smtpOpts := []smtpserver.Option{
+		smtpserver.WithUsers(cfg.SMTPUsers),
+		smtpserver.WithMaxMessageBytes(cfg.SMTPMaxMessageBytes),
+		smtpserver.WithConnPolicy(policy),
+		smtpserver.WithLogger(logger),
+		smtpserver.WithRateLimits(limiter, ratelimit.NewConnLimiter(cfg.MaxConnsPerIP)),
+	}
+	listeners, err := openListeners(cfg)
+	if err != nil {
+		root.Error("smtp_listen_failed", "error", err)
+		os.Exit(1)
+	}
+	// One server per protocol serves all of its listeners with the same backend.
+	server := smtpserver.NewServer("", svc, smtpOpts...)
+	var lmtpServer *goSMTP.Server
+	for _, l := range listeners {
+		if l.lmtp && lmtpServer == nil {
+			lmtpServer = smtpserver.NewLMTPServer("", svc, smtpOpts...)
+		}
+	}
 
 	// Set up signal handling for graceful shutdown
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
-	errCh := make(chan error, 1)
+	errCh := make(chan error, len(listeners))
 
-	// Start server in a separate goroutine
-	go func() {
-		root.Info("smtp_listen", "addr", cfg.SMTPListerAddr)
-		errCh <- server.ListenAndServe()
-	}()
+	if cfg.RateLimitStateFile != "" {
+		go persistRateLimits(limiter, cfg.RateLimitStateFile, root)
+	}
+	// reload applies the current configuration to the running gateway. A
+	// secret refresh only applies it when a secret was rotated.
+	var reloadMu sync.Mutex
+	applied := cfg
+	reload := func(refresh bool) {
+		failed := "config_reload_failed"
+		if refresh {
+			failed = "secrets_refresh_failed"
+		}
+		next, err := loadConfig()
+		if err != nil {
+			root.Error(failed, "error", err)
+			return
+		}
+		reloadMu.Lock()
+		defer reloadMu.Unlock()
+		rotated := config.SecretsChanged(applied, next)
+		if refresh && len(rotated) == 0 {
+			return
+		}
+		if err := gw.Reconfigure(next); err != nil {
+			root.Error(failed, "error", err)
+			return
+		}
+		// Compared with the last reload, a setting needing a restart is
+		// reported once when it changes rather than on every reload.
+		restart := config.RestartRequired(applied, next)
+		applied = next
+		limiter.SetRules(rateRules(next))
+		// Credentials are swapped in place; enabling or disabling the HTTP
+		// API, the admin API or webhooks needs a restart.
+		for _, s := range []*goSMTP.Server{server, lmtpServer} {
+			if s != nil {
+				s.Backend.(*smtpserver.Backend).SetUsers(next.SMTPUsers)
+			}
+		}
+		if api != nil && len(next.APITokens) > 0 {
+			api.SetTokens(next.APITokens)
+		}
+		if adm != nil && next.AdminToken != "" {
+			adm.SetToken(next.AdminToken)
+		}
+		if len(rotated) > 0 {
+			root.Info("secrets_rotated", "settings", rotated)
+		}
+		if len(restart) > 0 {
+			root.Warn("config_reload_restart_required", "settings", restart)
+		}
+		if !refresh {
+			root.Info("config_reloaded", "file", cfg.ConfigFile)
+		}
+	}
+	if cfg.ConfigFile != "" {
+		go watchConfig(cfg.ConfigFile, cfg.WatchInterval, func() { reload(false) })
+	}
+	if len(cfg.Secrets) > 0 && cfg.SecretsRefresh > 0 {
+		go refreshSecrets(cfg.SecretsRefresh, func() { reload(true) })
+	}
+
+	if httpServer != nil {
+		go func() {
+			root.Info("http_listen", "addr", cfg.HTTPListenAddr)
+			if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				root.Error("http_server_error", "error", err)
+			}
+		}()
+	}
+
+	// Start every listener in a separate goroutine
+	for _, l := range listeners {
+		srv, event := server, "smtp_listen"
+		if l.lmtp {
+			srv, event = lmtpServer, "lmtp_listen"
+		}
+		go func() {
+			root.Info(event, "network", l.Addr().Network(), "addr", l.Addr().String(),
+				"systemd", l.systemd, "proxy_protocol", l.proxy)
+			errCh <- srv.Serve(l)
+		}()
+	}
 
 	var exitCode int
 	select {
@@ -52,6 +236,11 @@ func main() {
 		if cerr := server.Close(); cerr != nil {
 			root.Error("smtp_server_close_error", "error", cerr)
 		}
+		if lmtpServer != nil {
+			if cerr := lmtpServer.Close(); cerr != nil {
+				root.Error("lmtp_server_close_error", "error", cerr)
+			}
+		}
 		// Wait for ListenAndServe to return; treat closing of the listener as normal
 		err = <-errCh
 		if err != nil && !errors.Is(err, os.ErrClosed) {
@@ -68,5 +257,199 @@ func main() {
 		}
 	}
 
+	if httpServer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := httpServer.Shutdown(ctx); err != nil {
+			root.Error("http_server_close_error", "error", err)
+		}
+		cancel()
+	}
+	if cfg.RateLimitStateFile != "" {
+		if err := limiter.Save(cfg.RateLimitStateFile); err != nil {
+			root.Error("rate_limit_state_save_failed", "error", err)
+		}
+	}
+	if err := suppressions.Close(); err != nil {
+		root.Error("suppression_close_failed", "error", err)
+	}
 	os.Exit(exitCode)
 }
+
+// suppressOnEvent suppresses the recipients of hard bounces and complaints
+// reported by webhooks.
+func suppressOnEvent(list *suppression.List, logger domain.MessageLogger) webhook.Listener {
+	return func(ev delivery.Event, rec delivery.Record) {
+		if len(ev.To) == 0 {
+			ev.To = rec.To
+		}
+		entries := suppression.FromEvent(ev)
+		if len(entries) == 0 {
+			return
+		}
+		if err := list.AddAll(entries); err != nil {
+			logger.Error("suppression_update_failed", map[string]any{"error": err})
+			return
+		}
+		logger.Info("recipients_suppressed", map[string]any{"id": ev.EmailID, "to": ev.To, "reason": entries[0].Reason})
+	}
+}
+
+// connPolicy builds the SMTP connection policy from the configured network lists.
+func connPolicy(cfg config.Config) (smtpserver.ConnPolicy, error) {
+	allow, err := smtpserver.ParseNetworks(cfg.AllowNetworks)
+	if err != nil {
+		return smtpserver.ConnPolicy{}, fmt.Errorf("SMTP_ALLOW_NETWORKS: %w", err)
+	}
+	deny, err := smtpserver.ParseNetworks(cfg.DenyNetworks)
+	if err != nil {
+		return smtpserver.ConnPolicy{}, fmt.Errorf("SMTP_DENY_NETWORKS: %w", err)
+	}
+	trusted, err := smtpserver.ParseNetworks(cfg.TrustedNetworks)
+	if err != nil {
+		return smtpserver.ConnPolicy{}, fmt.Errorf("SMTP_TRUSTED_NETWORKS: %w", err)
+	}
+	return smtpserver.ConnPolicy{Allow: allow, Deny: deny, Trusted: trusted, RequireAuth: cfg.RequireAuth}, nil
+}
+
+// serveListener is a listener with the protocol it serves.
+type serveListener struct {
+	net.Listener
+	lmtp    bool
+	systemd bool
+	proxy   bool
+}
+
+// openListeners opens the SMTP and LMTP listeners. Sockets passed by systemd
+// socket activation replace the configured addresses: those named "lmtp"
+// (FileDescriptorName=lmtp) serve LMTP, all others SMTP. SMTP listeners on
+// TCP parse the PROXY protocol when trusted proxy networks are configured.
+func openListeners(cfg config.Config) ([]serveListener, error) {
+	proxies, err := smtpserver.ParseNetworks(cfg.ProxyNetworks)
+	if err != nil {
+		return nil, fmt.Errorf("PROXY_PROTOCOL_TRUSTED_NETWORKS: %w", err)
+	}
+	var out []serveListener
+	add := func(l net.Listener, lmtp, systemd bool) {
+		sl := serveListener{Listener: l, lmtp: lmtp, systemd: systemd}
+		if !lmtp && len(proxies) > 0 && l.Addr().Network() == "tcp" {
+			sl.Listener, sl.proxy = &proxyproto.Listener{Listener: l, Trusted: proxies}, true
+		}
+		out = append(out, sl)
+	}
+	inherited, err := listener.Systemd()
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range inherited {
+		add(l.Listener, l.Name == "lmtp", true)
+	}
+	if len(out) > 0 {
+		return out, nil
+	}
+	for _, lmtp := range []bool{false, true} {
+		addrs, name := cfg.SMTPListerAddr, "SMTP_LISTEN_ADDR"
+		if lmtp {
+			addrs, name = cfg.LMTPListenAddr, "LMTP_LISTEN_ADDR"
+		}
+		for _, addr := range strings.Split(addrs, ",") {
+			if addr = strings.TrimSpace(addr); addr == "" {
+				continue
+			}
+			l, err := listener.Listen(addr, cfg.SocketMode)
+			if err != nil {
+				for _, o := range out {
+					o.Close()
+				}
+				return nil, fmt.Errorf("%s %s: %w", name, addr, err)
+			}
+			add(l, lmtp, false)
+		}
+	}
+	if len(out) == 0 {
+		return nil, errors.New("SMTP_LISTEN_ADDR: no listen address")
+	}
+	return out, nil
+}
+
+// loadConfig loads the configuration, listening on PORT when it is set.
+func loadConfig() (config.Config, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return cfg, err
+	}
+	// optional override for Railway dynamic ports
+	if v := os.Getenv("PORT"); v != "" {
+		cfg.SMTPListerAddr = ":" + v
+	}
+	return cfg, nil
+}
+
+// watchConfig calls reload on SIGHUP and, when interval is positive, after
+// the configuration file at path changed. Changes are detected by polling
+// the modification time and size, which also notices files replaced through
+// a symbolic link, as in Kubernetes ConfigMap volumes.
+func watchConfig(path string, interval time.Duration, reload func()) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	var tick <-chan time.Time
+	if interval > 0 {
+		tick = time.Tick(interval)
+	}
+	last := stamp(path)
+	for {
+		select {
+		case <-hup:
+		case <-tick:
+			if stamp(path) == last {
+				continue
+			}
+		}
+		last = stamp(path)
+		reload()
+	}
+}
+
+// refreshSecrets calls refresh every interval so that credentials rotated in
+// their files or secret manager are picked up without a restart.
+func refreshSecrets(interval time.Duration, refresh func()) {
+	for range time.Tick(interval) {
+		refresh()
+	}
+}
+
+// fileStamp identifies a version of a file.
+type fileStamp struct {
+	mod  time.Time
+	size int64
+}
+
+func stamp(path string) fileStamp {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{fi.ModTime(), fi.Size()}
+}
+
+// rateLimiter builds the message quota limiter from the configured limits.
+func rateLimiter(cfg config.Config) *ratelimit.Limiter {
+	return ratelimit.New(rateRules(cfg))
+}
+
+// rateRules converts the configured limits to quota rules.
+func rateRules(cfg config.Config) []ratelimit.Rule {
+	rules := make([]ratelimit.Rule, 0, len(cfg.RateLimits))
+	for _, l := range cfg.RateLimits {
+		rules = append(rules, ratelimit.Rule{Scope: ratelimit.Scope(l.Scope), Window: l.Window, Limit: l.Limit})
+	}
+	return rules
+}
+
+// persistRateLimits periodically saves quota counters so that daily quotas survive restarts.
+func persistRateLimits(limiter *ratelimit.Limiter, path string, log *slog.Logger) {
+	for range time.Tick(time.Minute) {
+		if err := limiter.Save(path); err != nil {
+			log.Error("rate_limit_state_save_failed", "error", err)
+		}
+	}
+}
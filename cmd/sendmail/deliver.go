@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/emersion/go-sasl"
+	goSMTP "github.com/emersion/go-smtp"
+	smtpserver "github.com/igorrius/resend-railway-gateway/internal/adapters/smtp"
+	"github.com/igorrius/resend-railway-gateway/internal/config"
+	"github.com/igorrius/resend-railway-gateway/internal/domain"
+	"github.com/igorrius/resend-railway-gateway/internal/gateway"
+	"github.com/igorrius/resend-railway-gateway/internal/listener"
+	"github.com/igorrius/resend-railway-gateway/internal/logging"
+)
+
+// submitter submits messages to a running gateway over SMTP.
+type submitter struct {
+	addr           string
+	user, password string
+}
+
+func (s *submitter) dial() (net.Conn, error) {
+	if path, ok := listener.UnixPath(s.addr); ok {
+		return net.Dial("unix", path)
+	}
+	return net.Dial("tcp", s.addr)
+}
+
+func (s *submitter) Send(from string, rcpts []string, msg []byte) error {
+	conn, err := s.dial()
+	if err != nil {
+		return err
+	}
+	c := goSMTP.NewClient(conn)
+	defer c.Close()
+	if s.user != "" {
+		if err := c.Auth(sasl.NewPlainClient("", s.user, s.password)); err != nil {
+			return err
+		}
+	}
+	if err := c.SendMail(from, rcpts, bytes.NewReader(msg)); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// ServeSMTP relays the SMTP session on standard input and output to the
+// gateway byte for byte.
+func (s *submitter) ServeSMTP() error {
+	conn, err := s.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	go func() {
+		io.Copy(conn, os.Stdin)
+		if cw, ok := conn.(interface{ CloseWrite() error }); ok {
+			cw.CloseWrite()
+		}
+	}()
+	_, err = io.Copy(os.Stdout, conn)
+	return err
+}
+
+// direct sends messages through the application service in process, with
+// the same configuration as the gateway server.
+type direct struct {
+	gw  *gateway.Gateway
+	cfg config.Config
+	log *slog.Logger
+}
+
+func newDirect() (*direct, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	// Standard output belongs to the caller (and to the SMTP session of
+	// -bs), and cron mails whatever a job writes: log warnings and errors
+	// to standard error unless asked for more.
+	if os.Getenv("LOG_LEVEL") == "" {
+		os.Setenv("LOG_LEVEL", "WARN")
+	}
+	root := logging.NewConfiguredLoggerTo(os.Stderr)
+	gw, err := gateway.New(cfg, root, logging.New(root))
+	if err != nil {
+		return nil, err
+	}
+	return &direct{gw: gw, cfg: cfg, log: root}, nil
+}
+
+func (d *direct) Send(from string, rcpts []string, msg []byte) error {
+	if err := d.gw.Service.CheckSender("", from); err != nil {
+		return err
+	}
+	email := smtpserver.ParseMIMEMessage(from, rcpts, msg)
+	email.Envelope = domain.Envelope{MailFrom: from, RemoteAddr: "sendmail"}
+	return d.gw.Service.HandleEmail(email)
+}
+
+// ServeSMTP serves one SMTP session on standard input and output with the
+// gateway's SMTP server. The session counts as a local client, like one on
+// a Unix socket, so network policies do not apply to it.
+func (d *direct) ServeSMTP() error {
+	srv := smtpserver.NewServer("", d.gw.Service,
+		smtpserver.WithMaxMessageBytes(d.cfg.SMTPMaxMessageBytes),
+		smtpserver.WithLogger(logging.New(d.log)))
+	err := srv.Serve(newStdioListener())
+	if errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
+
+// stdioListener accepts a single connection made of standard input and
+// output, then reports itself closed once that connection is closed.
+type stdioListener struct {
+	conn   *stdioConn
+	closed chan struct{}
+	once   sync.Once
+	served bool
+}
+
+func newStdioListener() *stdioListener {
+	l := &stdioListener{closed: make(chan struct{})}
+	l.conn = &stdioConn{close: l.Close}
+	return l
+}
+
+func (l *stdioListener) Accept() (net.Conn, error) {
+	if !l.served {
+		l.served = true
+		return l.conn, nil
+	}
+	<-l.closed
+	return nil, net.ErrClosed
+}
+
+func (l *stdioListener) Close() error {
+	l.once.Do(func() { close(l.closed) })
+	return nil
+}
+
+func (l *stdioListener) Addr() net.Addr { return stdioAddr }
+
+var stdioAddr = &net.UnixAddr{Name: "stdio", Net: "unix"}
+
+// stdioConn is a net.Conn reading standard input and writing standard output.
+type stdioConn struct {
+	close func() error
+}
+
+func (c *stdioConn) Read(b []byte) (int, error)  { return os.Stdin.Read(b) }
+func (c *stdioConn) Write(b []byte) (int, error) { return os.Stdout.Write(b) }
+func (c *stdioConn) Close() error                { return c.close() }
+func (c *stdioConn) LocalAddr() net.Addr         { return stdioAddr }
+func (c *stdioConn) RemoteAddr() net.Addr        { return stdioAddr }
+
+// Deadlines are not supported on standard input and output; the session
+// ends when the client quits or closes its end.
+func (c *stdioConn) SetDeadline(time.Time) error      { return nil }
+func (c *stdioConn) SetReadDeadline(time.Time) error  { return nil }
+func (c *stdioConn) SetWriteDeadline(time.Time) error { return nil }
+
+// smtpExitCode maps a reply of the gateway to an exit status.
+func smtpExitCode(err error) (int, bool) {
+	var serr *goSMTP.SMTPError
+	if !errors.As(err, &serr) {
+		return 0, false
+	}
+	switch {
+	case serr.Code >= 500 && serr.Code != 552 && serr.Code != 554:
+		return exUnavailable, true
+	case serr.Code >= 500:
+		return exDataErr, true
+	default:
+		return exTempFail, true
+	}
+}
+
+// serviceExitCode maps an error of the application service to an exit status.
+func serviceExitCode(err error) (int, bool) {
+	var verr *domain.ValidationError
+	var rerr *domain.RecipientRejectedError
+	switch {
+	case errors.As(err, &verr):
+		return exDataErr, true
+	case errors.Is(err, domain.ErrSenderNotAllowed),
+		errors.Is(err, domain.ErrRecipientNotAllowed),
+		errors.Is(err, domain.ErrRecipientSuppressed):
+		return exUnavailable, true
+	case errors.As(err, &rerr) && rerr.Permanent:
+		return exUnavailable, true
+	}
+	return 0, false
+}
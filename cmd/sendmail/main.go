@@ -0,0 +1,105 @@
+// Command sendmail is a sendmail-compatible front end to the gateway for
+// programs that deliver mail by piping a message to /usr/sbin/sendmail.
+//
+// Usage:
+//
+//	sendmail [-t] [-i] [-oi] [-f SENDER] [-F NAME] [RECIPIENT...] < message
+//	sendmail -bs
+//
+// The message is read from standard input; without -i (or -oi) a line with
+// a single dot ends it. With -t the recipients are taken from the To, Cc and
+// Bcc headers in addition to the command line, and the Bcc header is removed.
+// -bs speaks SMTP on standard input and output instead. Other -o options are
+// accepted and ignored.
+//
+// When SENDMAIL_GATEWAY is set to the address of a running gateway
+// ("host:port", "unix:/path" or an absolute socket path) the message is
+// submitted to it over SMTP, authenticating with SENDMAIL_USER and
+// SENDMAIL_PASSWORD when set. Otherwise it is sent directly through the
+// provider using the gateway's configuration from the environment.
+//
+// The exit status follows sysexits.h: 64 for usage errors, 65 for malformed
+// or oversized messages, 69 for other permanent and 75 for temporary
+// failures, after which the caller may try again later.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Exit codes from sysexits.h.
+const (
+	exUsage       = 64
+	exDataErr     = 65
+	exUnavailable = 69
+	exTempFail    = 75
+)
+
+func main() {
+	opts, err := parseArgs(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "sendmail:", err)
+		fmt.Fprintln(os.Stderr, "usage: sendmail [-t] [-i] [-f sender] [-F name] [recipient...] | sendmail -bs")
+		os.Exit(exUsage)
+	}
+	var d deliverer
+	if addr := os.Getenv("SENDMAIL_GATEWAY"); addr != "" {
+		d = &submitter{addr: addr, user: os.Getenv("SENDMAIL_USER"), password: os.Getenv("SENDMAIL_PASSWORD")}
+	} else {
+		d, err = newDirect()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "sendmail:", err)
+			os.Exit(exUnavailable)
+		}
+	}
+	if err := run(d, opts); err != nil {
+		fmt.Fprintln(os.Stderr, "sendmail:", err)
+		os.Exit(exitCode(err))
+	}
+}
+
+// deliverer sends messages to the gateway, either over SMTP or in process.
+type deliverer interface {
+	// Send delivers msg from the envelope sender to rcpts.
+	Send(from string, rcpts []string, msg []byte) error
+	// ServeSMTP runs an SMTP session on standard input and output.
+	ServeSMTP() error
+}
+
+// run reads the message from standard input and delivers it as opts say.
+func run(d deliverer, opts options) error {
+	if opts.smtp {
+		return d.ServeSMTP()
+	}
+	raw, err := readMessage(os.Stdin, opts.ignoreDots)
+	if err != nil {
+		return err
+	}
+	msg, from, rcpts, err := prepareMessage(raw, opts)
+	if err != nil {
+		return err
+	}
+	return d.Send(from, rcpts, msg)
+}
+
+// usageError is an error in the command line or in what it asks for.
+type usageError struct{ msg string }
+
+func (e usageError) Error() string { return e.msg }
+
+// exitCode maps an error to a sysexits.h status.
+func exitCode(err error) int {
+	var uerr usageError
+	if errors.As(err, &uerr) {
+		return exUsage
+	}
+	if code, ok := smtpExitCode(err); ok {
+		return code
+	}
+	if code, ok := serviceExitCode(err); ok {
+		return code
+	}
+	return exTempFail
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/igorrius/resend-railway-gateway/internal/domain"
+)
+
+// options are the parsed command line flags.
+type options struct {
+	smtp         bool // -bs
+	extractRcpts bool // -t
+	ignoreDots   bool // -i, -oi
+	from         string
+	fullName     string
+	rcpts        []string
+}
+
+// parseArgs parses sendmail's command line. Values of -f and -F may be
+// attached ("-fuser@host") or separate; recipients may be comma separated.
+func parseArgs(args []string) (options, error) {
+	var opts options
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			opts.rcpts = appendRcpts(opts.rcpts, args[i+1:]...)
+			break
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			opts.rcpts = appendRcpts(opts.rcpts, arg)
+			continue
+		}
+		switch flag := arg[:2]; {
+		case arg == "-t":
+			opts.extractRcpts = true
+		case arg == "-i" || arg == "-oi":
+			opts.ignoreDots = true
+		case arg == "-bs":
+			opts.smtp = true
+		case arg == "-bm":
+			opts.smtp = false
+		case flag == "-o":
+			// -odb, -oem and friends tune the local queue; there is none.
+		case flag == "-f" || flag == "-r" || flag == "-F":
+			value := arg[2:]
+			if value == "" {
+				if i+1 == len(args) {
+					return options{}, usageError{fmt.Sprintf("option %s needs a value", arg)}
+				}
+				i++
+				value = args[i]
+			}
+			if flag == "-F" {
+				opts.fullName = value
+			} else {
+				opts.from = value
+			}
+		default:
+			return options{}, usageError{fmt.Sprintf("unsupported option %s", arg)}
+		}
+	}
+	return opts, nil
+}
+
+func appendRcpts(rcpts []string, args ...string) []string {
+	for _, arg := range args {
+		for _, r := range strings.Split(arg, ",") {
+			if r = strings.TrimSpace(r); r != "" {
+				rcpts = append(rcpts, r)
+			}
+		}
+	}
+	return rcpts
+}
+
+// readMessage reads the message from r with CRLF line endings. Unless
+// ignoreDots is set, a line holding a single dot ends the message.
+func readMessage(r io.Reader, ignoreDots bool) ([]byte, error) {
+	var buf bytes.Buffer
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if line == "" && err == io.EOF {
+			break
+		}
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+		if !ignoreDots && line == "." {
+			break
+		}
+		buf.WriteString(line)
+		buf.WriteString("\r\n")
+		if err == io.EOF {
+			break
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// prepareMessage works out the envelope of raw and fixes its header: with
+// -t the recipients come from the To, Cc and Bcc headers as well, the Bcc
+// header is removed, and a missing From header is added from -F and the
+// envelope sender. The envelope sender is -f, else the From header, else
+// the invoking user at the local host.
+func prepareMessage(raw []byte, opts options) (msg []byte, from string, rcpts []string, err error) {
+	fields, body := splitHeader(raw)
+	var hdrFrom []domain.Address
+	var out bytes.Buffer
+	rcpts = append(rcpts, opts.rcpts...)
+	for _, f := range fields {
+		switch name, value := f.name(), f.value(); {
+		case strings.EqualFold(name, "From"):
+			hdrFrom = domain.ParseAddressListLenient(value)
+		case opts.extractRcpts && (strings.EqualFold(name, "To") || strings.EqualFold(name, "Cc") || strings.EqualFold(name, "Bcc")):
+			for _, a := range domain.ParseAddressListLenient(value) {
+				rcpts = append(rcpts, a.Addr)
+			}
+			if strings.EqualFold(name, "Bcc") {
+				continue
+			}
+		}
+		out.WriteString(string(f))
+	}
+	if len(rcpts) == 0 {
+		return nil, "", nil, usageError{"no recipients"}
+	}
+	switch {
+	case opts.from != "":
+		from = opts.from
+	case len(hdrFrom) > 0:
+		from = hdrFrom[0].Addr
+	default:
+		from = localUser()
+	}
+	if !hasField(fields, "From") {
+		addr := domain.Address{Name: opts.fullName, Addr: from}
+		out.WriteString("From: " + addr.String() + "\r\n")
+	}
+	out.WriteString("\r\n")
+	out.Write(body)
+	return out.Bytes(), from, rcpts, nil
+}
+
+// field is a raw header field including continuation lines and the final CRLF.
+type field string
+
+func (f field) name() string {
+	name, _, _ := strings.Cut(string(f), ":")
+	return strings.TrimSpace(name)
+}
+
+func (f field) value() string {
+	_, value, _ := strings.Cut(string(f), ":")
+	return strings.TrimSpace(strings.NewReplacer("\r\n", "", "\t", " ").Replace(value))
+}
+
+// splitHeader splits a CRLF message into its header fields and its body.
+// The body starts after the first blank line, or at the first line that is
+// not a header field when the blank line is missing.
+func splitHeader(raw []byte) ([]field, []byte) {
+	var fields []field
+	for len(raw) > 0 {
+		line, rest, _ := bytes.Cut(raw, []byte("\r\n"))
+		if len(line) == 0 {
+			return fields, rest
+		}
+		switch {
+		case (line[0] == ' ' || line[0] == '\t') && len(fields) > 0:
+			fields[len(fields)-1] += field(line) + "\r\n"
+		case bytes.IndexByte(line, ':') > 0:
+			fields = append(fields, field(line)+"\r\n")
+		default:
+			return fields, raw
+		}
+		raw = rest
+	}
+	return fields, nil
+}
+
+func hasField(fields []field, name string) bool {
+	for _, f := range fields {
+		if strings.EqualFold(f.name(), name) {
+			return true
+		}
+	}
+	return false
+}
+
+// localUser returns the invoking user's address at the local host name.
+func localUser() string {
+	user := os.Getenv("USER")
+	if user == "" {
+		user = os.Getenv("LOGNAME")
+	}
+	if user == "" {
+		user = "root"
+	}
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "localhost"
+	}
+	return user + "@" + host
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseArgs(t *testing.T) {
+	opts, err := parseArgs([]string{"-t", "-oi", "-odb", "-fme@example.com", "-F", "Me Myself", "a@example.com,b@example.com", "--", "-c@example.com"})
+	if err != nil {
+		t.Fatalf("parseArgs: %v", err)
+	}
+	want := options{
+		extractRcpts: true,
+		ignoreDots:   true,
+		from:         "me@example.com",
+		fullName:     "Me Myself",
+		rcpts:        []string{"a@example.com", "b@example.com", "-c@example.com"},
+	}
+	if !reflect.DeepEqual(opts, want) {
+		t.Errorf("got %+v, want %+v", opts, want)
+	}
+	if opts, _ := parseArgs([]string{"-bs"}); !opts.smtp {
+		t.Error("-bs not recognized")
+	}
+	for _, args := range [][]string{{"-f"}, {"-x"}, {"-bp"}} {
+		if _, err := parseArgs(args); err == nil {
+			t.Errorf("parseArgs(%q) succeeded", args)
+		}
+	}
+}
+
+func TestReadMessage(t *testing.T) {
+	in := "Subject: hi\n\nline\n.\nignored\n"
+	got, _ := readMessage(strings.NewReader(in), false)
+	if string(got) != "Subject: hi\r\n\r\nline\r\n" {
+		t.Errorf("dot terminated: %q", got)
+	}
+	got, _ = readMessage(strings.NewReader(in), true)
+	if string(got) != "Subject: hi\r\n\r\nline\r\n.\r\nignored\r\n" {
+		t.Errorf("-i: %q", got)
+	}
+}
+
+func TestPrepareMessage_ExtractRecipients(t *testing.T) {
+	raw := "To: A <a@example.com>,\r\n b@example.com\r\nBcc: hidden@example.com\r\nSubject: hi\r\n\r\nbody\r\n"
+	msg, from, rcpts, err := prepareMessage([]byte(raw), options{extractRcpts: true, from: "me@example.com", fullName: "Me"})
+	if err != nil {
+		t.Fatalf("prepareMessage: %v", err)
+	}
+	if from != "me@example.com" {
+		t.Errorf("from = %q", from)
+	}
+	if want := []string{"a@example.com", "b@example.com", "hidden@example.com"}; !reflect.DeepEqual(rcpts, want) {
+		t.Errorf("rcpts = %v, want %v", rcpts, want)
+	}
+	want := "To: A <a@example.com>,\r\n b@example.com\r\nSubject: hi\r\nFrom: \"Me\" <me@example.com>\r\n\r\nbody\r\n"
+	if string(msg) != want {
+		t.Errorf("msg = %q, want %q", msg, want)
+	}
+}
+
+func TestPrepareMessage_SenderFromHeader(t *testing.T) {
+	raw := "From: Sender <s@example.com>\r\nBcc: kept@example.com\r\n\r\nbody\r\n"
+	msg, from, rcpts, err := prepareMessage([]byte(raw), options{rcpts: []string{"r@example.com"}})
+	if err != nil {
+		t.Fatalf("prepareMessage: %v", err)
+	}
+	if from != "s@example.com" || !reflect.DeepEqual(rcpts, []string{"r@example.com"}) {
+		t.Errorf("envelope = %q %v", from, rcpts)
+	}
+	// Without -t the header is passed on untouched.
+	if string(msg) != raw {
+		t.Errorf("msg = %q", msg)
+	}
+}
+
+func TestPrepareMessage_NoHeader(t *testing.T) {
+	msg, _, _, err := prepareMessage([]byte("just text\r\n"), options{from: "me@example.com", rcpts: []string{"r@example.com"}})
+	if err != nil {
+		t.Fatalf("prepareMessage: %v", err)
+	}
+	if string(msg) != "From: me@example.com\r\n\r\njust text\r\n" {
+		t.Errorf("msg = %q", msg)
+	}
+	if _, _, _, err := prepareMessage([]byte("Subject: x\r\n\r\n"), options{}); err == nil {
+		t.Error("message without recipients accepted")
+	}
+}
@@ -0,0 +1,156 @@
+// Command suppressions manages the gateway's suppression list through the
+// admin HTTP API.
+//
+// Usage:
+//
+//	suppressions [-url URL] [-token TOKEN] list
+//	suppressions add [-reason REASON] [-detail TEXT] ADDRESS...
+//	suppressions remove ADDRESS...
+//	suppressions import FILE.csv   ("-" reads standard input)
+//	suppressions export            (CSV on standard output)
+//
+// The URL and token default to GATEWAY_ADMIN_URL and ADMIN_API_TOKEN.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+func main() {
+	baseURL := flag.String("url", getenv("GATEWAY_ADMIN_URL", "http://localhost:8025"), "gateway HTTP address")
+	token := flag.String("token", os.Getenv("ADMIN_API_TOKEN"), "admin API bearer token")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: suppressions [-url URL] [-token TOKEN] list|add|remove|import|export [args]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	c := &client{base: strings.TrimRight(*baseURL, "/"), token: *token, http: &http.Client{Timeout: time.Minute}}
+	if err := run(c, flag.Arg(0), flag.Args()[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "suppressions:", err)
+		os.Exit(1)
+	}
+}
+
+func run(c *client, cmd string, args []string) error {
+	switch cmd {
+	case "list":
+		var entries []struct {
+			Address   string    `json:"address"`
+			Reason    string    `json:"reason"`
+			Detail    string    `json:"detail"`
+			ExpiresAt time.Time `json:"expires_at"`
+		}
+		body, err := c.do(http.MethodGet, "/api/suppressions", nil)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(body, &entries); err != nil {
+			return err
+		}
+		for _, e := range entries {
+			expires := "never"
+			if !e.ExpiresAt.IsZero() {
+				expires = e.ExpiresAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%s\t%s\t%s\t%s\n", e.Address, e.Reason, expires, e.Detail)
+		}
+		return nil
+	case "add":
+		fs := flag.NewFlagSet("add", flag.ExitOnError)
+		reason := fs.String("reason", "manual", "bounce, complaint, rejected or manual")
+		detail := fs.String("detail", "", "free-form note")
+		fs.Parse(args)
+		payload, _ := json.Marshal(map[string]string{"reason": *reason, "detail": *detail})
+		for _, addr := range fs.Args() {
+			if _, err := c.do(http.MethodPut, "/api/suppressions/"+url.PathEscape(addr), payload); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "remove":
+		for _, addr := range args {
+			if _, err := c.do(http.MethodDelete, "/api/suppressions/"+url.PathEscape(addr), nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "import":
+		if len(args) != 1 {
+			return fmt.Errorf("import needs exactly one file")
+		}
+		var (
+			data []byte
+			err  error
+		)
+		if args[0] == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(args[0])
+		}
+		if err != nil {
+			return err
+		}
+		body, err := c.do(http.MethodPost, "/api/suppressions/import", data)
+		if err != nil {
+			return err
+		}
+		fmt.Print(string(body))
+		return nil
+	case "export":
+		body, err := c.do(http.MethodGet, "/api/suppressions/export", nil)
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(body)
+		return err
+	default:
+		return fmt.Errorf("unknown command %q", cmd)
+	}
+}
+
+type client struct {
+	base  string
+	token string
+	http  *http.Client
+}
+
+func (c *client) do(method, path string, body []byte) ([]byte, error) {
+	req, err := http.NewRequest(method, c.base+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
+
+func getenv(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
@@ -3,8 +3,10 @@ module github.com/igorrius/resend-railway-gateway
 go 1.25
 
 require (
+	github.com/emersion/go-sasl v0.0.0-20241020182733-b788ff22d5a6
 	github.com/emersion/go-smtp v0.24.0
 	github.com/resend/resend-go/v2 v2.23.0
+	golang.org/x/net v0.50.0
+	golang.org/x/text v0.34.0
+	gopkg.in/yaml.v3 v3.0.1
 )
-
-require github.com/emersion/go-sasl v0.0.0-20241020182733-b788ff22d5a6 // indirect
@@ -0,0 +1,149 @@
+// Package admin serves the operator HTTP API for managing gateway state.
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/igorrius/resend-railway-gateway/internal/domain"
+	"github.com/igorrius/resend-railway-gateway/internal/suppression"
+)
+
+// maxImportBytes bounds suppression list uploads.
+const maxImportBytes = 32 << 20
+
+// Handler serves, for clients presenting the admin bearer token:
+//
+//	GET    /api/suppressions            list entries (JSON)
+//	GET    /api/suppressions/export     export entries (CSV)
+//	POST   /api/suppressions/import     import entries (CSV body)
+//	GET    /api/suppressions/{address}  look up an entry
+//	PUT    /api/suppressions/{address}  add an entry; optional JSON body {reason, detail, expires_at}
+//	DELETE /api/suppressions/{address}  remove an entry
+type Handler struct {
+	mu           sync.RWMutex
+	token        string
+	suppressions *suppression.List
+	logger       domain.MessageLogger
+	mux          *http.ServeMux
+}
+
+// NewHandler creates a Handler. token must not be empty.
+func NewHandler(token string, suppressions *suppression.List, logger domain.MessageLogger) *Handler {
+	h := &Handler{token: token, suppressions: suppressions, logger: logger, mux: http.NewServeMux()}
+	h.mux.HandleFunc("GET /api/suppressions", h.list)
+	h.mux.HandleFunc("GET /api/suppressions/export", h.export)
+	h.mux.HandleFunc("POST /api/suppressions/import", h.importCSV)
+	h.mux.HandleFunc("GET /api/suppressions/{address}", h.get)
+	h.mux.HandleFunc("PUT /api/suppressions/{address}", h.put)
+	h.mux.HandleFunc("DELETE /api/suppressions/{address}", h.remove)
+	return h
+}
+
+// SetToken replaces the admin token, for example after it was rotated. An
+// empty token refuses every request.
+func (h *Handler) SetToken(token string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.token = token
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.Authorize(h.mux).ServeHTTP(w, r)
+}
+
+// Authorize returns a handler serving next only to clients presenting the
+// admin token, for other operator endpoints such as the delivery status API.
+func (h *Handler) Authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.mu.RLock()
+		token := h.token
+		h.mu.RUnlock()
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *Handler) list(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, h.suppressions.Entries())
+}
+
+func (h *Handler) export(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="suppressions.csv"`)
+	if err := h.suppressions.Export(w); err != nil {
+		h.logger.Error("suppression_export_failed", map[string]any{"error": err})
+	}
+}
+
+func (h *Handler) importCSV(w http.ResponseWriter, r *http.Request) {
+	n, err := h.suppressions.Import(http.MaxBytesReader(w, r.Body, maxImportBytes))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.logger.Info("suppressions_imported", map[string]any{"count": n})
+	writeJSON(w, http.StatusOK, map[string]int{"imported": n})
+}
+
+func (h *Handler) get(w http.ResponseWriter, r *http.Request) {
+	e, ok := h.suppressions.Get(r.PathValue("address"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, http.StatusOK, e)
+}
+
+func (h *Handler) put(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Reason    suppression.Reason `json:"reason"`
+		Detail    string             `json:"detail"`
+		ExpiresAt time.Time          `json:"expires_at"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<16)).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "invalid JSON body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	e := suppression.Entry{Address: r.PathValue("address"), Reason: body.Reason, Detail: body.Detail, ExpiresAt: body.ExpiresAt}
+	if err := h.suppressions.Add(e); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.logger.Info("suppression_added", map[string]any{"to": e.Address, "reason": e.Reason})
+	e, _ = h.suppressions.Get(e.Address)
+	writeJSON(w, http.StatusOK, e)
+}
+
+func (h *Handler) remove(w http.ResponseWriter, r *http.Request) {
+	addr := r.PathValue("address")
+	removed, err := h.suppressions.Remove(addr)
+	if err != nil {
+		h.logger.Error("suppression_update_failed", map[string]any{"error": err})
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if !removed {
+		http.NotFound(w, r)
+		return
+	}
+	h.logger.Info("suppression_removed", map[string]any{"to": addr})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,103 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/igorrius/resend-railway-gateway/internal/suppression"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Info(string, map[string]any)  {}
+func (nopLogger) Error(string, map[string]any) {}
+
+func do(t *testing.T, h http.Handler, method, path, token, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHandler_Suppressions(t *testing.T) {
+	list, _ := suppression.Open("", nil)
+	h := NewHandler("s3cret", list, nopLogger{})
+
+	if rec := do(t, h, http.MethodGet, "/api/suppressions", "", ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without token, got %d", rec.Code)
+	}
+	if rec := do(t, h, http.MethodGet, "/api/suppressions", "wrong", ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 with a wrong token, got %d", rec.Code)
+	}
+
+	rec := do(t, h, http.MethodPut, "/api/suppressions/dead@example.com", "s3cret", `{"reason":"bounce","detail":"manual cleanup"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("put: %d %s", rec.Code, rec.Body)
+	}
+	if rec := do(t, h, http.MethodPut, "/api/suppressions/b@example.com", "s3cret", ""); rec.Code != http.StatusOK {
+		t.Fatalf("put without body: %d %s", rec.Code, rec.Body)
+	}
+	if rec := do(t, h, http.MethodPut, "/api/suppressions/not-an-address", "s3cret", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid address, got %d", rec.Code)
+	}
+
+	rec = do(t, h, http.MethodGet, "/api/suppressions/dead@example.com", "s3cret", "")
+	var e suppression.Entry
+	if err := json.Unmarshal(rec.Body.Bytes(), &e); err != nil || e.Reason != suppression.ReasonBounce {
+		t.Errorf("unexpected entry %s", rec.Body)
+	}
+
+	rec = do(t, h, http.MethodPost, "/api/suppressions/import", "s3cret", "c@example.com,complaint\n")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"imported":1`) {
+		t.Errorf("import: %d %s", rec.Code, rec.Body)
+	}
+	rec = do(t, h, http.MethodGet, "/api/suppressions/export", "s3cret", "")
+	if lines := strings.Count(rec.Body.String(), "\n"); lines != 4 {
+		t.Errorf("expected header and 3 entries, got:\n%s", rec.Body)
+	}
+
+	if rec := do(t, h, http.MethodDelete, "/api/suppressions/dead@example.com", "s3cret", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("delete: %d", rec.Code)
+	}
+	if rec := do(t, h, http.MethodDelete, "/api/suppressions/dead@example.com", "s3cret", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("second delete: %d", rec.Code)
+	}
+}
+
+func TestHandler_SetToken(t *testing.T) {
+	list, _ := suppression.Open("", nil)
+	h := NewHandler("old", list, nopLogger{})
+	h.SetToken("new")
+	if rec := do(t, h, http.MethodGet, "/api/suppressions", "old", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("old token: %d", rec.Code)
+	}
+	if rec := do(t, h, http.MethodGet, "/api/suppressions", "new", ""); rec.Code != http.StatusOK {
+		t.Errorf("new token: %d", rec.Code)
+	}
+	h.SetToken("")
+	req := httptest.NewRequest(http.MethodGet, "/api/suppressions", nil)
+	req.Header.Set("Authorization", "Bearer ")
+	rec := httptest.NewRecorder()
+	if h.ServeHTTP(rec, req); rec.Code != http.StatusUnauthorized {
+		t.Errorf("empty token: %d", rec.Code)
+	}
+}
+
+func TestHandler_Authorize(t *testing.T) {
+	list, _ := suppression.Open("", nil)
+	h := NewHandler("s3cret", list, nopLogger{})
+	status := h.Authorize(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusNoContent) }))
+	if rec := do(t, status, http.MethodGet, "/api/status/em_1", "", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("without token: %d", rec.Code)
+	}
+	if rec := do(t, status, http.MethodGet, "/api/status/em_1", "s3cret", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("with token: %d", rec.Code)
+	}
+}
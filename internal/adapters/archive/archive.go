@@ -0,0 +1,162 @@
+// Package archive writes emails as .eml files, either as the only delivery
+// target (e.g. in air-gapped test environments) or alongside another sender.
+package archive
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/igorrius/resend-railway-gateway/internal/domain"
+	"github.com/igorrius/resend-railway-gateway/internal/mimebuild"
+)
+
+// Layout selects how files are arranged below the archive directory.
+type Layout string
+
+const (
+	// LayoutMaildir stores messages in a Maildir (tmp/, new/, cur/) that
+	// mail clients and IMAP servers can open directly.
+	LayoutMaildir Layout = "maildir"
+	// LayoutDated stores messages as YYYY/MM/DD/<name>.eml.
+	LayoutDated Layout = "dated"
+)
+
+// Writer implements domain.OutboundEmailSender by writing each email to a
+// uniquely named file. Files are written to a temporary name and renamed
+// into place, so readers never observe partial messages.
+//
+// The original MIME message is written when the email carries one;
+// otherwise the message is rendered from its fields.
+type Writer struct {
+	dir    string
+	layout Layout
+	gzip   bool
+	host   string
+	now    func() time.Time
+	seq    atomic.Uint64
+}
+
+// NewWriter creates dir (and the Maildir subdirectories) if needed.
+// With compress set, files are gzip compressed; dated files get a .gz suffix.
+func NewWriter(dir string, layout Layout, compress bool) (*Writer, error) {
+	switch layout {
+	case LayoutMaildir:
+		for _, sub := range []string{"tmp", "new", "cur"} {
+			if err := os.MkdirAll(filepath.Join(dir, sub), 0o750); err != nil {
+				return nil, err
+			}
+		}
+	case LayoutDated:
+		if err := os.MkdirAll(dir, 0o750); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("archive: unknown layout %q", layout)
+	}
+	host, _ := os.Hostname()
+	// '/' and ':' are not allowed in Maildir names; escape them as the spec suggests.
+	host = strings.NewReplacer("/", `\057`, ":", `\072`).Replace(host)
+	if host == "" {
+		host = "localhost"
+	}
+	return &Writer{dir: dir, layout: layout, gzip: compress, host: host, now: time.Now}, nil
+}
+
+// Send archives the email and returns the file name it was stored under.
+func (w *Writer) Send(email domain.Email) (string, error) {
+	now := w.now()
+	data := email.Raw
+	if len(data) == 0 {
+		data = mimebuild.Build(email, now)
+	}
+	// Maildir unique names: <seconds>.M<microseconds>P<pid>Q<sequence>.<host>
+	name := fmt.Sprintf("%d.M%dP%dQ%d.%s", now.Unix(), now.Nanosecond()/1000, os.Getpid(), w.seq.Add(1), w.host)
+
+	var tmpDir, dst string
+	switch w.layout {
+	case LayoutMaildir:
+		tmpDir = filepath.Join(w.dir, "tmp")
+		dst = filepath.Join(w.dir, "new", name)
+	default:
+		tmpDir = filepath.Join(w.dir, now.UTC().Format("2006/01/02"))
+		if err := os.MkdirAll(tmpDir, 0o750); err != nil {
+			return "", err
+		}
+		name += ".eml"
+		if w.gzip {
+			name += ".gz"
+		}
+		dst = filepath.Join(tmpDir, name)
+	}
+	if err := w.writeFile(tmpDir, dst, data); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// writeFile writes data to a temporary file in tmpDir, syncs it and renames it to dst.
+func (w *Writer) writeFile(tmpDir, dst string, data []byte) (err error) {
+	f, err := os.CreateTemp(tmpDir, ".archive-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	var out io.Writer = f
+	var zw *gzip.Writer
+	if w.gzip {
+		zw = gzip.NewWriter(f)
+		out = zw
+	}
+	if _, err = out.Write(data); err != nil {
+		return err
+	}
+	if zw != nil {
+		if err = zw.Close(); err != nil {
+			return err
+		}
+	}
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), dst)
+}
+
+// Tee sends every email with Primary and archives the successfully sent ones.
+// A failed archive write is logged but does not fail the send, since the
+// email has already been accepted by the provider.
+type Tee struct {
+	Primary domain.OutboundEmailSender
+	Archive *Writer
+	Logger  domain.MessageLogger
+}
+
+// Send delivers the email with Primary and then archives it.
+func (t Tee) Send(email domain.Email) (string, error) {
+	id, err := t.Primary.Send(email)
+	if err != nil {
+		return "", err
+	}
+	if _, aerr := t.Archive.Send(email); aerr != nil {
+		t.Logger.Error("archive_failed", map[string]any{"id": id, "error": aerr})
+	}
+	return id, nil
+}
+
+var (
+	_ domain.OutboundEmailSender = (*Writer)(nil)
+	_ domain.OutboundEmailSender = Tee{}
+)
@@ -0,0 +1,127 @@
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/igorrius/resend-railway-gateway/internal/domain"
+)
+
+func testEmail() domain.Email {
+	return domain.Email{
+		From:    domain.Address{Addr: "a@example.com"},
+		To:      []domain.Address{{Addr: "b@example.com"}},
+		Subject: "Archived",
+		Text:    "body",
+	}
+}
+
+func TestWriter_Maildir(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewWriter(dir, LayoutMaildir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	email := testEmail()
+	email.Raw = []byte("Subject: raw\r\n\r\nraw body\r\n")
+	first, err := w.Send(email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := w.Send(testEmail())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Fatalf("expected unique names, got %q twice", first)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "new", first))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, email.Raw) {
+		t.Errorf("expected the raw message, got %q", data)
+	}
+	data, _ = os.ReadFile(filepath.Join(dir, "new", second))
+	if !bytes.Contains(data, []byte("Subject: Archived\r\n")) {
+		t.Errorf("expected a rendered message, got %q", data)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(dir, "tmp")); len(entries) != 0 {
+		t.Errorf("expected tmp/ to be empty, got %d entries", len(entries))
+	}
+}
+
+func TestWriter_DatedGzip(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewWriter(dir, LayoutDated, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.now = func() time.Time { return time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC) }
+
+	name, err := w.Send(testEmail())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(name, ".eml.gz") {
+		t.Errorf("unexpected name %q", name)
+	}
+	f, err := os.Open(filepath.Join(dir, "2026", "03", "04", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(zr)
+	if !bytes.Contains(data, []byte("Subject: Archived\r\n")) {
+		t.Errorf("unexpected content %q", data)
+	}
+}
+
+type stubSender struct{ err error }
+
+func (s stubSender) Send(domain.Email) (string, error) { return "provider-id", s.err }
+
+type stubLogger struct{ errors []string }
+
+func (l *stubLogger) Info(string, map[string]any)        {}
+func (l *stubLogger) Error(msg string, _ map[string]any) { l.errors = append(l.errors, msg) }
+
+func TestTee(t *testing.T) {
+	dir := t.TempDir()
+	w, _ := NewWriter(dir, LayoutMaildir, false)
+
+	tee := Tee{Primary: stubSender{}, Archive: w, Logger: &stubLogger{}}
+	if id, err := tee.Send(testEmail()); err != nil || id != "provider-id" {
+		t.Fatalf("unexpected result %q, %v", id, err)
+	}
+	tee.Primary = stubSender{err: errors.New("rejected")}
+	if _, err := tee.Send(testEmail()); err == nil {
+		t.Fatal("expected the primary error")
+	}
+	if entries, _ := os.ReadDir(filepath.Join(dir, "new")); len(entries) != 1 {
+		t.Errorf("expected only the sent message to be archived, got %d", len(entries))
+	}
+
+	// Archive failures are logged, not returned.
+	log := &stubLogger{}
+	os.RemoveAll(dir)
+	tee = Tee{Primary: stubSender{}, Archive: w, Logger: log}
+	if _, err := tee.Send(testEmail()); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(log.errors) != 1 || log.errors[0] != "archive_failed" {
+		t.Errorf("expected archive_failed to be logged, got %v", log.errors)
+	}
+}
@@ -0,0 +1,234 @@
+package capture
+
+import (
+	_ "embed"
+	"encoding/json"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/igorrius/resend-railway-gateway/internal/domain"
+)
+
+//go:embed ui.html
+var uiHTML []byte
+
+// htmlPolicy sandboxes captured HTML bodies so that scripts in them cannot
+// run with the UI's origin and remote content is not fetched.
+const htmlPolicy = "sandbox; default-src 'none'; img-src data: cid:; style-src 'unsafe-inline'"
+
+// NewHandler returns an HTTP handler serving the capture UI at "/" and a JSON API:
+//
+//	GET    /api/messages                          list messages, newest first
+//	DELETE /api/messages                          delete all messages
+//	GET    /api/messages/{id}                     message details
+//	GET    /api/messages/{id}/html                HTML body (sandboxed)
+//	GET    /api/messages/{id}/text                text body
+//	GET    /api/messages/{id}/raw                 original MIME message (.eml)
+//	GET    /api/messages/{id}/attachments/{index} attachment download
+func NewHandler(store Store) http.Handler {
+	h := &handler{store: store}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", h.ui)
+	mux.HandleFunc("GET /api/messages", h.list)
+	mux.HandleFunc("DELETE /api/messages", h.clear)
+	mux.HandleFunc("GET /api/messages/{id}", h.get)
+	mux.HandleFunc("GET /api/messages/{id}/html", h.html)
+	mux.HandleFunc("GET /api/messages/{id}/text", h.text)
+	mux.HandleFunc("GET /api/messages/{id}/raw", h.raw)
+	mux.HandleFunc("GET /api/messages/{id}/attachments/{index}", h.attachment)
+	return mux
+}
+
+type handler struct {
+	store Store
+}
+
+type summaryJSON struct {
+	ID          string    `json:"id"`
+	ReceivedAt  time.Time `json:"received_at"`
+	From        string    `json:"from"`
+	To          []string  `json:"to"`
+	Subject     string    `json:"subject"`
+	Attachments int       `json:"attachments"`
+}
+
+type attachmentJSON struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Size        int    `json:"size"`
+}
+
+type messageJSON struct {
+	summaryJSON
+	Cc          []string          `json:"cc,omitempty"`
+	Bcc         []string          `json:"bcc,omitempty"`
+	ReplyTo     []string          `json:"reply_to,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	ScheduledAt string            `json:"scheduled_at,omitempty"`
+	MailFrom    string            `json:"mail_from,omitempty"`
+	User        string            `json:"user,omitempty"`
+	RemoteAddr  string            `json:"remote_addr,omitempty"`
+	HasHTML     bool              `json:"has_html"`
+	HasText     bool              `json:"has_text"`
+	HasRaw      bool              `json:"has_raw"`
+	Files       []attachmentJSON  `json:"attachment_list,omitempty"`
+}
+
+func summarize(m Message) summaryJSON {
+	return summaryJSON{
+		ID:          m.ID,
+		ReceivedAt:  m.ReceivedAt,
+		From:        m.Email.From.String(),
+		To:          domain.AddressStrings(m.Email.To),
+		Subject:     m.Email.Subject,
+		Attachments: len(m.Email.Attachments),
+	}
+}
+
+func (h *handler) ui(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write(uiHTML)
+}
+
+func (h *handler) list(w http.ResponseWriter, _ *http.Request) {
+	msgs, err := h.store.List()
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+	out := make([]summaryJSON, 0, len(msgs))
+	for _, m := range msgs {
+		out = append(out, summarize(m))
+	}
+	writeJSON(w, out)
+}
+
+func (h *handler) clear(w http.ResponseWriter, _ *http.Request) {
+	if err := h.store.Clear(); err != nil {
+		h.fail(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) get(w http.ResponseWriter, r *http.Request) {
+	m, ok := h.lookup(w, r)
+	if !ok {
+		return
+	}
+	e := m.Email
+	out := messageJSON{
+		summaryJSON: summarize(m),
+		Cc:          domain.AddressStrings(e.Cc),
+		Bcc:         domain.AddressStrings(e.Bcc),
+		ReplyTo:     domain.AddressStrings(e.ReplyTo),
+		Headers:     e.Headers,
+		ScheduledAt: e.ScheduledAt,
+		MailFrom:    e.Envelope.MailFrom,
+		User:        e.Envelope.User,
+		RemoteAddr:  e.Envelope.RemoteAddr,
+		HasHTML:     e.HTML != "",
+		HasText:     e.Text != "",
+		HasRaw:      len(e.Raw) > 0,
+	}
+	if len(e.Tags) > 0 {
+		out.Tags = make(map[string]string, len(e.Tags))
+		for _, t := range e.Tags {
+			out.Tags[t.Name] = t.Value
+		}
+	}
+	for _, a := range e.Attachments {
+		out.Files = append(out.Files, attachmentJSON{Filename: a.Filename, ContentType: contentType(a), Size: len(a.Content)})
+	}
+	writeJSON(w, out)
+}
+
+func (h *handler) html(w http.ResponseWriter, r *http.Request) {
+	m, ok := h.lookup(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Security-Policy", htmlPolicy)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write([]byte(m.Email.HTML))
+}
+
+func (h *handler) text(w http.ResponseWriter, r *http.Request) {
+	m, ok := h.lookup(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write([]byte(m.Email.Text))
+}
+
+func (h *handler) raw(w http.ResponseWriter, r *http.Request) {
+	m, ok := h.lookup(w, r)
+	if !ok {
+		return
+	}
+	if len(m.Email.Raw) == 0 {
+		http.Error(w, "raw message not available", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "message/rfc822")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": m.ID + ".eml"}))
+	_, _ = w.Write(m.Email.Raw)
+}
+
+func (h *handler) attachment(w http.ResponseWriter, r *http.Request) {
+	m, ok := h.lookup(w, r)
+	if !ok {
+		return
+	}
+	i, err := strconv.Atoi(r.PathValue("index"))
+	if err != nil || i < 0 || i >= len(m.Email.Attachments) {
+		http.NotFound(w, r)
+		return
+	}
+	a := m.Email.Attachments[i]
+	w.Header().Set("Content-Type", contentType(a))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": a.Filename}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	_, _ = w.Write(a.Content)
+}
+
+// lookup loads the message named by the {id} path value, writing a 404 or
+// 500 response when it cannot be returned.
+func (h *handler) lookup(w http.ResponseWriter, r *http.Request) (Message, bool) {
+	m, ok, err := h.store.Get(r.PathValue("id"))
+	if err != nil {
+		h.fail(w, err)
+		return Message{}, false
+	}
+	if !ok {
+		http.NotFound(w, r)
+		return Message{}, false
+	}
+	return m, true
+}
+
+func (h *handler) fail(w http.ResponseWriter, err error) {
+	slog.Error("capture_store_error", "error", err)
+	http.Error(w, "internal error", http.StatusInternalServerError)
+}
+
+func contentType(a domain.Attachment) string {
+	if a.ContentType != "" {
+		return a.ContentType
+	}
+	if t := mime.TypeByExtension(filepath.Ext(a.Filename)); t != "" {
+		return t
+	}
+	return http.DetectContentType(a.Content)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
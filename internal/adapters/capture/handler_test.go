@@ -0,0 +1,101 @@
+package capture
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/igorrius/resend-railway-gateway/internal/domain"
+)
+
+func TestHandler(t *testing.T) {
+	store := NewMemoryStore(0)
+	sender := NewSender(store)
+	_, err := sender.Send(domain.Email{
+		From:        domain.Address{Name: "Alice", Addr: "alice@example.com"},
+		To:          []domain.Address{{Addr: "bob@example.com"}},
+		Subject:     "Report",
+		HTML:        "<p>Hi</p>",
+		Text:        "Hi",
+		Attachments: []domain.Attachment{{Filename: "report.pdf", Content: []byte("%PDF-1.4")}},
+		Raw:         []byte("Subject: Report\r\n\r\nHi\r\n"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(NewHandler(store))
+	defer srv.Close()
+
+	get := func(path string) (*http.Response, string) {
+		t.Helper()
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp, string(body)
+	}
+
+	resp, body := get("/")
+	if resp.StatusCode != http.StatusOK || !strings.Contains(body, "<html") {
+		t.Fatalf("ui: status %d", resp.StatusCode)
+	}
+
+	_, body = get("/api/messages")
+	var list []summaryJSON
+	if err := json.Unmarshal([]byte(body), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Subject != "Report" || list[0].From != `"Alice" <alice@example.com>` {
+		t.Fatalf("unexpected list %+v", list)
+	}
+	id := list[0].ID
+
+	_, body = get("/api/messages/" + id)
+	var detail messageJSON
+	if err := json.Unmarshal([]byte(body), &detail); err != nil {
+		t.Fatal(err)
+	}
+	if !detail.HasHTML || !detail.HasRaw || len(detail.Files) != 1 || detail.Files[0].ContentType != "application/pdf" {
+		t.Errorf("unexpected detail %+v", detail)
+	}
+
+	resp, body = get("/api/messages/" + id + "/html")
+	if body != "<p>Hi</p>" || !strings.HasPrefix(resp.Header.Get("Content-Security-Policy"), "sandbox") {
+		t.Errorf("html: %q csp %q", body, resp.Header.Get("Content-Security-Policy"))
+	}
+	if _, body = get("/api/messages/" + id + "/text"); body != "Hi" {
+		t.Errorf("text: %q", body)
+	}
+	resp, body = get("/api/messages/" + id + "/raw")
+	if body != "Subject: Report\r\n\r\nHi\r\n" || resp.Header.Get("Content-Type") != "message/rfc822" {
+		t.Errorf("raw: %q", body)
+	}
+	resp, body = get("/api/messages/" + id + "/attachments/0")
+	if body != "%PDF-1.4" || !strings.Contains(resp.Header.Get("Content-Disposition"), "report.pdf") {
+		t.Errorf("attachment: %q %q", body, resp.Header.Get("Content-Disposition"))
+	}
+	if resp, _ = get("/api/messages/" + id + "/attachments/1"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("missing attachment: status %d", resp.StatusCode)
+	}
+	if resp, _ = get("/api/messages/nope"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("missing message: status %d", resp.StatusCode)
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, srv.URL+"/api/messages", nil)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("delete: status %d", resp.StatusCode)
+	}
+	if msgs, _ := store.List(); len(msgs) != 0 {
+		t.Errorf("expected no messages after delete, got %d", len(msgs))
+	}
+}
@@ -0,0 +1,39 @@
+// Package capture provides a development sender that stores emails instead of
+// delivering them, together with an HTTP UI and JSON API to inspect them.
+package capture
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/igorrius/resend-railway-gateway/internal/domain"
+)
+
+// Sender implements domain.OutboundEmailSender by storing every email.
+type Sender struct {
+	store Store
+	now   func() time.Time
+}
+
+// NewSender creates a Sender writing to store.
+func NewSender(store Store) *Sender {
+	return &Sender{store: store, now: time.Now}
+}
+
+// Send stores the email under a new random ID and returns that ID.
+func (s *Sender) Send(email domain.Email) (string, error) {
+	id := newID()
+	if err := s.store.Add(Message{ID: id, ReceivedAt: s.now().UTC(), Email: email}); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+var _ domain.OutboundEmailSender = (*Sender)(nil)
@@ -0,0 +1,205 @@
+package capture
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/igorrius/resend-railway-gateway/internal/domain"
+)
+
+// Message is a captured email.
+type Message struct {
+	ID         string       `json:"id"`
+	ReceivedAt time.Time    `json:"received_at"`
+	Email      domain.Email `json:"email"`
+}
+
+// Store persists captured messages. Implementations must be safe for concurrent use.
+type Store interface {
+	Add(m Message) error
+	// List returns all messages, newest first.
+	List() ([]Message, error)
+	Get(id string) (Message, bool, error)
+	Clear() error
+}
+
+// MemoryStore keeps the most recent messages in memory.
+type MemoryStore struct {
+	max int
+
+	mu       sync.RWMutex
+	messages []Message // oldest first
+}
+
+// NewMemoryStore creates a MemoryStore holding at most max messages; zero means unbounded.
+func NewMemoryStore(max int) *MemoryStore {
+	return &MemoryStore{max: max}
+}
+
+func (s *MemoryStore) Add(m Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, m)
+	if s.max > 0 && len(s.messages) > s.max {
+		s.messages = append([]Message(nil), s.messages[len(s.messages)-s.max:]...)
+	}
+	return nil
+}
+
+func (s *MemoryStore) List() ([]Message, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]Message, 0, len(s.messages))
+	for i := len(s.messages) - 1; i >= 0; i-- {
+		out = append(out, s.messages[i])
+	}
+	return out, nil
+}
+
+func (s *MemoryStore) Get(id string) (Message, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, m := range s.messages {
+		if m.ID == id {
+			return m, true, nil
+		}
+	}
+	return Message{}, false, nil
+}
+
+func (s *MemoryStore) Clear() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = nil
+	return nil
+}
+
+// DiskStore keeps each message as <id>.json with the raw MIME alongside as
+// <id>.eml, so captures survive restarts and can be opened in a mail client.
+type DiskStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewDiskStore creates dir if needed and returns a DiskStore rooted there.
+func NewDiskStore(dir string) (*DiskStore, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	return &DiskStore{dir: dir}, nil
+}
+
+func (s *DiskStore) Add(m Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	raw := m.Email.Raw
+	m.Email.Raw = nil
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if len(raw) > 0 {
+		if err := os.WriteFile(filepath.Join(s.dir, m.ID+".eml"), raw, 0o640); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(filepath.Join(s.dir, m.ID+".json"), data, 0o640)
+}
+
+func (s *DiskStore) List() ([]Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var out []Message
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok || e.IsDir() {
+			continue
+		}
+		m, err := s.read(id, false)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ReceivedAt.After(out[j].ReceivedAt) })
+	return out, nil
+}
+
+func (s *DiskStore) Get(id string) (Message, bool, error) {
+	if !validID(id) {
+		return Message{}, false, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, err := s.read(id, true)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Message{}, false, nil
+	}
+	return m, err == nil, err
+}
+
+func (s *DiskStore) read(id string, withRaw bool) (Message, error) {
+	var m Message
+	data, err := os.ReadFile(filepath.Join(s.dir, id+".json"))
+	if err != nil {
+		return m, err
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, fmt.Errorf("capture: decode %s: %w", id, err)
+	}
+	if withRaw {
+		raw, err := os.ReadFile(filepath.Join(s.dir, id+".eml"))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return m, err
+		}
+		m.Email.Raw = raw
+	}
+	return m, nil
+}
+
+func (s *DiskStore) Clear() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if name := e.Name(); strings.HasSuffix(name, ".json") || strings.HasSuffix(name, ".eml") {
+			if err := os.Remove(filepath.Join(s.dir, name)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validID guards DiskStore paths against traversal.
+func validID(id string) bool {
+	if id == "" {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'f' || r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+var (
+	_ Store = (*MemoryStore)(nil)
+	_ Store = (*DiskStore)(nil)
+)
@@ -0,0 +1,83 @@
+package capture
+
+import (
+	"testing"
+	"time"
+
+	"github.com/igorrius/resend-railway-gateway/internal/domain"
+)
+
+func message(id string, at time.Time) Message {
+	return Message{ID: id, ReceivedAt: at, Email: domain.Email{
+		From:    domain.Address{Addr: "a@example.com"},
+		To:      []domain.Address{{Addr: "b@example.com"}},
+		Subject: "hello " + id,
+		Text:    "body",
+		Raw:     []byte("Subject: hello\r\n\r\nbody\r\n"),
+	}}
+}
+
+func TestMemoryStore_BoundedNewestFirst(t *testing.T) {
+	s := NewMemoryStore(2)
+	now := time.Now()
+	for i, id := range []string{"a1", "b2", "c3"} {
+		if err := s.Add(message(id, now.Add(time.Duration(i)*time.Second))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	msgs, _ := s.List()
+	if len(msgs) != 2 || msgs[0].ID != "c3" || msgs[1].ID != "b2" {
+		t.Fatalf("unexpected messages %+v", msgs)
+	}
+	if _, ok, _ := s.Get("a1"); ok {
+		t.Error("oldest message should have been evicted")
+	}
+	_ = s.Clear()
+	if msgs, _ := s.List(); len(msgs) != 0 {
+		t.Errorf("expected empty store after Clear, got %d", len(msgs))
+	}
+}
+
+func TestDiskStore_RoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewDiskStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	if err := s.Add(message("0a", now)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add(message("0b", now.Add(time.Second))); err != nil {
+		t.Fatal(err)
+	}
+
+	// A fresh store over the same directory sees the persisted messages.
+	s, _ = NewDiskStore(dir)
+	msgs, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 2 || msgs[0].ID != "0b" {
+		t.Fatalf("unexpected messages %+v", msgs)
+	}
+	if msgs[0].Email.Raw != nil {
+		t.Error("List should not load raw messages")
+	}
+	m, ok, err := s.Get("0a")
+	if err != nil || !ok {
+		t.Fatalf("Get: ok=%v err=%v", ok, err)
+	}
+	if m.Email.Subject != "hello 0a" || string(m.Email.Raw) != "Subject: hello\r\n\r\nbody\r\n" {
+		t.Errorf("unexpected message %+v", m)
+	}
+	if _, ok, _ := s.Get("../0a"); ok {
+		t.Error("path traversal id should not be found")
+	}
+	if err := s.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	if msgs, _ := s.List(); len(msgs) != 0 {
+		t.Errorf("expected empty store after Clear, got %d", len(msgs))
+	}
+}
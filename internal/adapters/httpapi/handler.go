@@ -0,0 +1,377 @@
+// Package httpapi serves a Resend-compatible HTTP send API so that services
+// can submit emails as JSON with gateway-issued tokens instead of the Resend key.
+package httpapi
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/igorrius/resend-railway-gateway/internal/app"
+	"github.com/igorrius/resend-railway-gateway/internal/domain"
+	"github.com/igorrius/resend-railway-gateway/internal/ratelimit"
+)
+
+// maxBodyBytes bounds request bodies; Resend accepts up to 40 MB per email
+// after base64 encoding of attachments.
+const maxBodyBytes = 40 << 20
+
+// maxBatch is the largest number of emails in one batch request, as in Resend.
+const maxBatch = 100
+
+// Handler serves, for clients presenting one of the configured bearer tokens:
+//
+//	POST /emails         send an email; responds {"id": "..."}
+//	POST /emails/batch   send up to 100 emails; responds {"data": [{"id": "..."}]}
+//
+// Request bodies use the field names of the Resend API. Emails go through the
+// same application service, and so the same policies, as SMTP traffic; the
+// name of the token is the authenticated user. Errors use Resend's format
+// {"statusCode", "name", "message"}.
+type Handler struct {
+	service *app.Service
+	logger  domain.MessageLogger
+	mu      sync.RWMutex
+	// tokens maps bearer tokens to client names.
+	tokens  map[string]string
+	limiter *ratelimit.Limiter
+	mux     *http.ServeMux
+}
+
+// Option configures optional Handler behaviour.
+type Option func(*Handler)
+
+// WithRateLimits applies the message quotas shared with the SMTP server.
+func WithRateLimits(l *ratelimit.Limiter) Option {
+	return func(h *Handler) { h.limiter = l }
+}
+
+// NewHandler creates a Handler. tokens maps client names to their bearer tokens.
+func NewHandler(service *app.Service, tokens map[string]string, logger domain.MessageLogger, opts ...Option) *Handler {
+	h := &Handler{service: service, logger: logger, mux: http.NewServeMux()}
+	h.SetTokens(tokens)
+	for _, opt := range opts {
+		opt(h)
+	}
+	h.mux.HandleFunc("POST /emails", h.send)
+	h.mux.HandleFunc("POST /emails/batch", h.batch)
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || got == "" {
+		writeError(w, http.StatusUnauthorized, "missing_api_key", "Missing API key in the authorization header")
+		return
+	}
+	user, ok := h.authenticate(got)
+	if !ok {
+		h.logger.Info("http_api_unauthorized", map[string]any{"client": r.RemoteAddr})
+		writeError(w, http.StatusForbidden, "invalid_api_key", "API key is invalid")
+		return
+	}
+	h.mux.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userKey{}, user)))
+}
+
+// SetTokens replaces the bearer tokens, for example after they were rotated.
+// tokens maps client names to their bearer tokens.
+func (h *Handler) SetTokens(tokens map[string]string) {
+	byToken := make(map[string]string, len(tokens))
+	for name, token := range tokens {
+		byToken[token] = name
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.tokens = byToken
+}
+
+// authenticate returns the client name of token. Every token is compared so
+// that the time taken does not reveal which one matched.
+func (h *Handler) authenticate(token string) (string, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	var user string
+	found := false
+	for t, name := range h.tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+			user, found = name, true
+		}
+	}
+	return user, found
+}
+
+type userKey struct{}
+
+func (h *Handler) send(w http.ResponseWriter, r *http.Request) {
+	var req emailRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	email, err := req.email()
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, "validation_error", err.Error())
+		return
+	}
+	email.IdempotencyKey = r.Header.Get("Idempotency-Key")
+	id, err := h.handle(r, email)
+	if err != nil {
+		h.writeSendError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"id": id})
+}
+
+// batch validates every email before sending any, then sends them in order.
+// When some fail the response lists the others' IDs and the failures by index.
+func (h *Handler) batch(w http.ResponseWriter, r *http.Request) {
+	var reqs []emailRequest
+	if !decode(w, r, &reqs) {
+		return
+	}
+	if len(reqs) == 0 || len(reqs) > maxBatch {
+		writeError(w, http.StatusUnprocessableEntity, "validation_error", fmt.Sprintf("a batch must contain 1 to %d emails", maxBatch))
+		return
+	}
+	emails := make([]domain.Email, len(reqs))
+	for i, req := range reqs {
+		email, err := req.email()
+		if err != nil {
+			writeError(w, http.StatusUnprocessableEntity, "validation_error", fmt.Sprintf("emails[%d]: %v", i, err))
+			return
+		}
+		if key := r.Header.Get("Idempotency-Key"); key != "" {
+			email.IdempotencyKey = key + "/" + strconv.Itoa(i)
+		}
+		emails[i] = email
+	}
+	type result struct {
+		ID string `json:"id"`
+	}
+	type failure struct {
+		Index   int    `json:"index"`
+		Message string `json:"message"`
+	}
+	var resp struct {
+		Data   []result  `json:"data"`
+		Errors []failure `json:"errors,omitempty"`
+	}
+	resp.Data = []result{}
+	var firstErr error
+	for i, email := range emails {
+		id, err := h.handle(r, email)
+		if err != nil {
+			resp.Errors = append(resp.Errors, failure{Index: i, Message: err.Error()})
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		resp.Data = append(resp.Data, result{ID: id})
+	}
+	if len(resp.Data) == 0 {
+		h.writeSendError(w, firstErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handle applies the sender policy and the rate limits and sends email on
+// behalf of the request's client. Only a sent email counts against the quotas.
+func (h *Handler) handle(r *http.Request, email domain.Email) (string, error) {
+	user, _ := r.Context().Value(userKey{}).(string)
+	email.Envelope = domain.Envelope{MailFrom: email.From.Addr, User: user, RemoteAddr: r.RemoteAddr}
+	if err := h.service.CheckSender(user, email.From.Addr); err != nil {
+		return "", err
+	}
+	subjects := quotaSubjects(user, email.From.Domain(), r.RemoteAddr)
+	if err := h.checkQuota(subjects, r.RemoteAddr); err != nil {
+		return "", err
+	}
+	id, err := h.service.HandleEmailID(email)
+	if err != nil {
+		return "", err
+	}
+	h.limiter.Record(subjects)
+	return id, nil
+}
+
+// rateLimitError reports an exhausted message quota.
+type rateLimitError struct{ retryAfter int }
+
+func (e *rateLimitError) Error() string {
+	return fmt.Sprintf("message rate limit exceeded, try again in %d seconds", e.retryAfter)
+}
+
+// quotaSubjects returns the quota subjects of a message: the client, sender
+// domain and client IP.
+func quotaSubjects(user, senderDomain, remoteAddr string) map[ratelimit.Scope]string {
+	subjects := map[ratelimit.Scope]string{ratelimit.ScopeUser: user, ratelimit.ScopeSenderDomain: senderDomain}
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		subjects[ratelimit.ScopeClientIP] = host
+	}
+	return subjects
+}
+
+// checkQuota reports an exhausted quota of a message without counting it.
+func (h *Handler) checkQuota(subjects map[ratelimit.Scope]string, remoteAddr string) error {
+	retry, ok := h.limiter.Check(subjects)
+	if ok {
+		return nil
+	}
+	h.logger.Info("http_rate_limited", map[string]any{
+		"client": remoteAddr, "user": subjects[ratelimit.ScopeUser], "domain": subjects[ratelimit.ScopeSenderDomain],
+		"retry_after": retry.String(),
+	})
+	return &rateLimitError{retryAfter: int(math.Ceil(retry.Seconds()))}
+}
+
+// writeSendError maps an error of the application service to a Resend error response.
+func (h *Handler) writeSendError(w http.ResponseWriter, err error) {
+	var (
+		rlerr *rateLimitError
+		verr  *domain.ValidationError
+		rerr  *domain.RecipientRejectedError
+	)
+	switch {
+	case errors.As(err, &rlerr):
+		w.Header().Set("Retry-After", strconv.Itoa(rlerr.retryAfter))
+		writeError(w, http.StatusTooManyRequests, "rate_limit_exceeded", err.Error())
+	case errors.Is(err, domain.ErrSenderNotAllowed):
+		writeError(w, http.StatusForbidden, "validation_error", err.Error())
+	case errors.As(err, &verr),
+		errors.Is(err, domain.ErrRecipientNotAllowed),
+		errors.Is(err, domain.ErrRecipientSuppressed),
+		errors.As(err, &rerr) && rerr.Permanent:
+		writeError(w, http.StatusUnprocessableEntity, "validation_error", err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		writeError(w, http.StatusGatewayTimeout, "application_error", "timed out sending the email")
+	default:
+		writeError(w, http.StatusInternalServerError, "application_error", err.Error())
+	}
+}
+
+// decode reads the JSON request body into v, writing an error response on failure.
+func decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes)).Decode(v)
+	var tooLarge *http.MaxBytesError
+	switch {
+	case err == nil:
+		return true
+	case errors.As(err, &tooLarge):
+		writeError(w, http.StatusRequestEntityTooLarge, "validation_error", "request body too large")
+	case errors.Is(err, io.EOF):
+		writeError(w, http.StatusUnprocessableEntity, "validation_error", "request body is empty")
+	default:
+		writeError(w, http.StatusUnprocessableEntity, "validation_error", "invalid JSON body: "+err.Error())
+	}
+	return false
+}
+
+func writeError(w http.ResponseWriter, status int, name, message string) {
+	writeJSON(w, status, map[string]any{"statusCode": status, "name": name, "message": message})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// emailRequest is the body of POST /emails in the Resend API.
+type emailRequest struct {
+	From        string            `json:"from"`
+	To          stringList        `json:"to"`
+	Cc          stringList        `json:"cc"`
+	Bcc         stringList        `json:"bcc"`
+	ReplyTo     stringList        `json:"reply_to"`
+	Subject     string            `json:"subject"`
+	HTML        string            `json:"html"`
+	Text        string            `json:"text"`
+	Headers     map[string]string `json:"headers"`
+	Attachments []struct {
+		Content     string `json:"content"`
+		Filename    string `json:"filename"`
+		Path        string `json:"path"`
+		ContentType string `json:"content_type"`
+	} `json:"attachments"`
+	Tags []struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	} `json:"tags"`
+	ScheduledAt string `json:"scheduled_at"`
+}
+
+// email converts the request to a domain email. Addresses must parse; the
+// rest is checked by the application service.
+func (req emailRequest) email() (domain.Email, error) {
+	var email domain.Email
+	if req.From == "" {
+		return email, errors.New("from is required")
+	}
+	from, err := domain.ParseAddress(req.From)
+	if err != nil {
+		return email, fmt.Errorf("from: %w", err)
+	}
+	email.From = from
+	for _, list := range []struct {
+		field string
+		in    stringList
+		out   *[]domain.Address
+	}{{"to", req.To, &email.To}, {"cc", req.Cc, &email.Cc}, {"bcc", req.Bcc, &email.Bcc}, {"reply_to", req.ReplyTo, &email.ReplyTo}} {
+		for _, s := range list.in {
+			addrs, err := domain.ParseAddressList(s)
+			if err != nil {
+				return email, fmt.Errorf("%s: %w", list.field, err)
+			}
+			*list.out = append(*list.out, addrs...)
+		}
+	}
+	email.Subject, email.HTML, email.Text = req.Subject, req.HTML, req.Text
+	email.Headers = map[string]string{}
+	for k, v := range req.Headers {
+		email.Headers[k] = v
+	}
+	for i, a := range req.Attachments {
+		if a.Path != "" {
+			return email, fmt.Errorf("attachments[%d]: path is not supported, send the content", i)
+		}
+		content, err := base64.StdEncoding.DecodeString(a.Content)
+		if err != nil {
+			return email, fmt.Errorf("attachments[%d]: content must be base64: %w", i, err)
+		}
+		email.Attachments = append(email.Attachments, domain.Attachment{Filename: a.Filename, Content: content, ContentType: a.ContentType})
+	}
+	for _, t := range req.Tags {
+		email.Tags = append(email.Tags, domain.Tag{Name: t.Name, Value: t.Value})
+	}
+	email.ScheduledAt = req.ScheduledAt
+	return email, nil
+}
+
+// stringList accepts a JSON string or array of strings, like Resend's
+// address fields.
+type stringList []string
+
+func (l *stringList) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*l = stringList{s}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(b, &list); err != nil {
+		return errors.New("expected a string or an array of strings")
+	}
+	*l = list
+	return nil
+}
@@ -0,0 +1,196 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/igorrius/resend-railway-gateway/internal/app"
+	"github.com/igorrius/resend-railway-gateway/internal/domain"
+	"github.com/igorrius/resend-railway-gateway/internal/ratelimit"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Info(string, map[string]any)  {}
+func (nopLogger) Error(string, map[string]any) {}
+
+type fakeSender struct {
+	sent []domain.Email
+	fail string // recipient whose emails fail
+}
+
+func (f *fakeSender) Send(email domain.Email) (string, error) {
+	if f.fail != "" && email.To[0].Addr == f.fail {
+		return "", errors.New("provider down")
+	}
+	f.sent = append(f.sent, email)
+	return "re_" + email.To[0].Addr, nil
+}
+
+func newHandler(t *testing.T, sender *fakeSender, opts ...Option) *Handler {
+	t.Helper()
+	svc := app.NewService(sender, nopLogger{}, time.Second,
+		app.WithSenderPolicy(&app.SenderPolicy{Allowed: []string{"example.com"}}))
+	return NewHandler(svc, map[string]string{"billing": "tok_billing"}, nopLogger{}, opts...)
+}
+
+func post(h http.Handler, path, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	req.Header.Set("Idempotency-Key", "key-1")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func decodeBody(t *testing.T, rec *httptest.ResponseRecorder) map[string]any {
+	t.Helper()
+	var v map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &v); err != nil {
+		t.Fatalf("decode %q: %v", rec.Body.String(), err)
+	}
+	return v
+}
+
+func TestAuthentication(t *testing.T) {
+	h := newHandler(t, &fakeSender{})
+	body := `{"from":"a@example.com","to":"b@example.net","subject":"hi","text":"x"}`
+	if rec := post(h, "/emails", "", body); rec.Code != http.StatusUnauthorized || decodeBody(t, rec)["name"] != "missing_api_key" {
+		t.Errorf("missing token: %d %s", rec.Code, rec.Body)
+	}
+	if rec := post(h, "/emails", "re_real_key", body); rec.Code != http.StatusForbidden || decodeBody(t, rec)["name"] != "invalid_api_key" {
+		t.Errorf("wrong token: %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestSetTokens(t *testing.T) {
+	h := newHandler(t, &fakeSender{})
+	h.SetTokens(map[string]string{"billing": "tok_rotated"})
+	body := `{"from":"a@example.com","to":"b@example.net","subject":"hi","text":"x"}`
+	if rec := post(h, "/emails", "tok_billing", body); rec.Code != http.StatusForbidden {
+		t.Errorf("old token: %d %s", rec.Code, rec.Body)
+	}
+	if rec := post(h, "/emails", "tok_rotated", body); rec.Code != http.StatusOK {
+		t.Errorf("new token: %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestSend(t *testing.T) {
+	sender := &fakeSender{}
+	h := newHandler(t, sender)
+	rec := post(h, "/emails", "tok_billing", `{
+		"from": "Billing <billing@example.com>",
+		"to": ["b@example.net", "C <c@example.net>"],
+		"cc": "d@example.net",
+		"reply_to": "support@example.com",
+		"subject": "Invoice",
+		"html": "<p>Paid</p>",
+		"headers": {"X-Entity-Ref-ID": "42"},
+		"attachments": [{"filename": "invoice.txt", "content": "aGVsbG8=", "content_type": "text/plain"}],
+		"tags": [{"name": "category", "value": "invoice"}]
+	}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	if id := decodeBody(t, rec)["id"]; id != "re_b@example.net" {
+		t.Errorf("id = %v", id)
+	}
+	e := sender.sent[0]
+	if e.From.Name != "Billing" || len(e.To) != 2 || e.Cc[0].Addr != "d@example.net" || e.ReplyTo[0].Addr != "support@example.com" {
+		t.Errorf("addresses not converted: %+v", e)
+	}
+	if string(e.Attachments[0].Content) != "hello" || e.Tags[0].Value != "invoice" || e.Headers["X-Entity-Ref-ID"] != "42" {
+		t.Errorf("fields not converted: %+v", e)
+	}
+	if e.Envelope.User != "billing" || e.Envelope.MailFrom != "billing@example.com" || e.IdempotencyKey != "key-1" {
+		t.Errorf("envelope = %+v, idempotency key %q", e.Envelope, e.IdempotencyKey)
+	}
+}
+
+func TestSend_Errors(t *testing.T) {
+	h := newHandler(t, &fakeSender{})
+	cases := []struct {
+		body   string
+		status int
+	}{
+		{`{"from":`, http.StatusUnprocessableEntity},
+		{`{"from":"a@example.com","to":"not an address","subject":"hi","text":"x"}`, http.StatusUnprocessableEntity},
+		{`{"from":"a@example.com","to":"b@example.net","subject":"","text":"x"}`, http.StatusUnprocessableEntity},
+		{`{"from":"a@example.com","to":"b@example.net","subject":"hi","text":"x","attachments":[{"filename":"f","path":"https://example.com/f"}]}`, http.StatusUnprocessableEntity},
+		{`{"from":"a@other.org","to":"b@example.net","subject":"hi","text":"x"}`, http.StatusForbidden},
+	}
+	for _, c := range cases {
+		rec := post(h, "/emails", "tok_billing", c.body)
+		if rec.Code != c.status || decodeBody(t, rec)["name"] != "validation_error" {
+			t.Errorf("%s: got %d %s, want %d", c.body, rec.Code, rec.Body, c.status)
+		}
+	}
+}
+
+func TestBatch(t *testing.T) {
+	sender := &fakeSender{fail: "down@example.net"}
+	h := newHandler(t, sender)
+	rec := post(h, "/emails/batch", "tok_billing", `[
+		{"from":"a@example.com","to":"b@example.net","subject":"one","text":"x"},
+		{"from":"a@example.com","to":"down@example.net","subject":"two","text":"x"},
+		{"from":"a@example.com","to":"c@example.net","subject":"three","text":"x"}
+	]`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var resp struct {
+		Data   []struct{ ID string }
+		Errors []struct {
+			Index   int
+			Message string
+		}
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Data) != 2 || resp.Data[1].ID != "re_c@example.net" || len(resp.Errors) != 1 || resp.Errors[0].Index != 1 {
+		t.Errorf("unexpected response %s", rec.Body)
+	}
+	if sender.sent[1].IdempotencyKey != "key-1/2" {
+		t.Errorf("idempotency key = %q", sender.sent[1].IdempotencyKey)
+	}
+
+	// An invalid email rejects the whole batch before anything is sent.
+	sender.sent = nil
+	rec = post(h, "/emails/batch", "tok_billing", `[
+		{"from":"a@example.com","to":"b@example.net","subject":"one","text":"x"},
+		{"from":"","to":"b@example.net","subject":"two","text":"x"}
+	]`)
+	if rec.Code != http.StatusUnprocessableEntity || len(sender.sent) != 0 {
+		t.Errorf("invalid batch: %d %s, %d sent", rec.Code, rec.Body, len(sender.sent))
+	}
+}
+
+func TestSend_RateLimited(t *testing.T) {
+	limiter := ratelimit.New([]ratelimit.Rule{{Scope: ratelimit.ScopeUser, Window: time.Minute, Limit: 1}})
+	h := newHandler(t, &fakeSender{fail: "down@example.net"}, WithRateLimits(limiter))
+	// Emails refused by the sender policy or failing to send use no quota.
+	forbidden := `{"from":"a@evil.test","to":"b@example.net","subject":"hi","text":"x"}`
+	if rec := post(h, "/emails", "tok_billing", forbidden); rec.Code != http.StatusForbidden {
+		t.Fatalf("forbidden sender: %d %s", rec.Code, rec.Body)
+	}
+	failing := `{"from":"a@example.com","to":"down@example.net","subject":"hi","text":"x"}`
+	if rec := post(h, "/emails", "tok_billing", failing); rec.Code != http.StatusInternalServerError {
+		t.Fatalf("failing send: %d %s", rec.Code, rec.Body)
+	}
+	body := `{"from":"a@example.com","to":"b@example.net","subject":"hi","text":"x"}`
+	if rec := post(h, "/emails", "tok_billing", body); rec.Code != http.StatusOK {
+		t.Fatalf("first: %d %s", rec.Code, rec.Body)
+	}
+	rec := post(h, "/emails", "tok_billing", body)
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("second: %d %s", rec.Code, rec.Body)
+	}
+}
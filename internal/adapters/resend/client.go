@@ -1,6 +1,15 @@
 package resend
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
 	"github.com/igorrius/resend-railway-gateway/internal/domain"
 	resendgo "github.com/resend/resend-go/v2"
 )
@@ -9,41 +18,138 @@ import (
 // It wraps the Resend Go SDK and adapts it to the domain OutboundEmailSender interface.
 type Client struct {
 	client *resendgo.Client
+	http   *http.Client
+}
+
+// ClientOption configures a Client.
+type ClientOption func(*Client)
+
+// WithBaseURL sends API requests to base, such as "http://127.0.0.1:8080/",
+// instead of https://api.resend.com/.
+func WithBaseURL(base *url.URL) ClientOption {
+	return func(c *Client) { c.client.BaseURL = base }
 }
 
 // NewClient creates a new Resend client with the given API key.
-func NewClient(apiKey string) *Client {
-	return &Client{client: resendgo.NewClient(apiKey)}
+func NewClient(apiKey string, opts ...ClientOption) *Client {
+	c := &Client{client: resendgo.NewClient(apiKey), http: &http.Client{Timeout: time.Minute}}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
-// Send converts the domain Email to Resend's format and sends it via the API.
-func (c *Client) Send(email domain.Email) error {
+// APIError is an error response of the Resend API.
+type APIError struct {
+	StatusCode int    `json:"statusCode"`
+	Name       string `json:"name"`
+	Message    string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("resend: %d %s: %s", e.StatusCode, e.Name, e.Message)
+}
+
+// NewRequest converts the domain Email to the request posted to the Resend API.
+func NewRequest(email domain.Email) *resendgo.SendEmailRequest {
 	attachments := make([]*resendgo.Attachment, 0, len(email.Attachments))
 	for _, a := range email.Attachments {
 		attachments = append(attachments, &resendgo.Attachment{
-			Filename: a.Filename,
-			Content:  a.Content,
+			Filename:    a.Filename,
+			Content:     a.Content,
+			ContentType: a.ContentType,
 		})
 	}
 	tags := make([]resendgo.Tag, 0, len(email.Tags))
 	for _, t := range email.Tags {
 		tags = append(tags, resendgo.Tag{Name: t.Name, Value: t.Value})
 	}
-	request := &resendgo.SendEmailRequest{
-		From:        email.From,
-		To:          email.To,
-		Cc:          email.Cc,
-		Bcc:         email.Bcc,
-		ReplyTo:     email.ReplyTo,
+	return &resendgo.SendEmailRequest{
+		From:        email.From.String(),
+		To:          domain.AddressStrings(email.To),
+		Cc:          domain.AddressStrings(email.Cc),
+		Bcc:         domain.AddressStrings(email.Bcc),
+		ReplyTo:     strings.Join(domain.AddressStrings(email.ReplyTo), ", "),
 		Subject:     email.Subject,
 		Html:        email.HTML,
 		Text:        email.Text,
 		Attachments: attachments,
 		Tags:        tags,
 		Headers:     email.Headers,
+		ScheduledAt: email.ScheduledAt,
+	}
+}
+
+// Send converts the domain Email to Resend's format and sends it via the API.
+// It returns the ID Resend assigned to the email. Recipients the API refuses
+// are reported as a permanent domain.RecipientRejectedError, which gives them
+// their own status over LMTP and adds them to the suppression list; other
+// error responses are reported as an *APIError.
+func (c *Client) Send(email domain.Email) (string, error) {
+	// The request is built by the SDK but performed here, since the SDK
+	// reduces error responses to their message.
+	req, err := c.client.NewRequestWithOptions(context.Background(), http.MethodPost, "emails", NewRequest(email),
+		&resendgo.SendEmailOptions{IdempotencyKey: email.IdempotencyKey})
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		apiErr := &APIError{}
+		if json.Unmarshal(body, apiErr) != nil || apiErr.Message == "" {
+			apiErr.Message = strings.TrimSpace(string(body))
+		}
+		apiErr.StatusCode = resp.StatusCode
+		return "", recipientError(apiErr, email)
+	}
+	var sent resendgo.SendEmailResponse
+	if err := json.Unmarshal(body, &sent); err != nil {
+		return "", fmt.Errorf("resend: decode response: %w", err)
+	}
+	return sent.Id, nil
+}
+
+// recipientError converts a validation error of the API that is about
+// recipients of email to a permanent rejection of those recipients. Resend
+// names the address or, for a field holding a single address, the field.
+// Errors that cannot be attributed to recipients are returned unchanged so
+// that valid addresses are never rejected, and suppressed, by mistake.
+func recipientError(e *APIError, email domain.Email) error {
+	if e.StatusCode != http.StatusBadRequest && e.StatusCode != http.StatusUnprocessableEntity {
+		return e
+	}
+	msg := strings.ToLower(e.Message)
+	var rejected []string
+	fields := []struct {
+		name  string
+		addrs []domain.Address
+	}{{"to", email.To}, {"cc", email.Cc}, {"bcc", email.Bcc}}
+	for _, f := range fields {
+		for _, a := range f.addrs {
+			if strings.Contains(msg, strings.ToLower(a.Addr)) {
+				rejected = append(rejected, a.Addr)
+			}
+		}
+	}
+	if len(rejected) == 0 {
+		for _, f := range fields {
+			if len(f.addrs) == 1 && strings.Contains(msg, "`"+f.name+"`") {
+				rejected = append(rejected, f.addrs[0].Addr)
+			}
+		}
+	}
+	if len(rejected) == 0 {
+		return e
 	}
-	_, err := c.client.Emails.Send(request)
-	return err
+	return &domain.RecipientRejectedError{Recipients: rejected, Permanent: true, Message: e.Message}
 }
 
 var _ domain.OutboundEmailSender = (*Client)(nil)
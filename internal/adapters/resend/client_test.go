@@ -0,0 +1,98 @@
+package resend
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/igorrius/resend-railway-gateway/internal/domain"
+)
+
+// stubClient returns a Client whose API requests are answered with status and body.
+func stubClient(t *testing.T, status int, body string) (*Client, *http.Request) {
+	t.Helper()
+	var got http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = *r.Clone(r.Context())
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	base, _ := url.Parse(srv.URL + "/")
+	return NewClient("re_test", WithBaseURL(base)), &got
+}
+
+func testEmail(to ...string) domain.Email {
+	e := domain.Email{From: domain.Address{Addr: "a@example.com"}, Subject: "hi", Text: "x", IdempotencyKey: "key-1"}
+	for _, addr := range to {
+		e.To = append(e.To, domain.Address{Addr: addr})
+	}
+	return e
+}
+
+func TestClient_Send(t *testing.T) {
+	c, req := stubClient(t, http.StatusOK, `{"id":"re_123"}`)
+	id, err := c.Send(testEmail("b@example.net"))
+	if err != nil || id != "re_123" {
+		t.Fatalf("got %q, %v", id, err)
+	}
+	if req.URL.Path != "/emails" || req.Header.Get("Authorization") != "Bearer re_test" || req.Header.Get("Idempotency-Key") != "key-1" {
+		t.Errorf("unexpected request %s %v", req.URL.Path, req.Header)
+	}
+}
+
+func TestClient_Send_RecipientRejected(t *testing.T) {
+	cases := []struct {
+		name string
+		to   []string
+		body string
+		want []string
+	}{
+		{"address named", []string{"ok@example.net", "bad@example.net"},
+			`{"statusCode":422,"name":"validation_error","message":"The email address bad@example.net is invalid."}`,
+			[]string{"bad@example.net"}},
+		{"single address field", []string{"bad@example"},
+			"{\"statusCode\":422,\"name\":\"validation_error\",\"message\":\"Invalid `to` field. The email address needs to follow the `email@example.com` format.\"}",
+			[]string{"bad@example"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, _ := stubClient(t, http.StatusUnprocessableEntity, tc.body)
+			_, err := c.Send(testEmail(tc.to...))
+			var rerr *domain.RecipientRejectedError
+			if !errors.As(err, &rerr) || !rerr.Permanent || !reflect.DeepEqual(rerr.Recipients, tc.want) {
+				t.Errorf("got %#v", err)
+			}
+		})
+	}
+}
+
+func TestClient_Send_OtherErrors(t *testing.T) {
+	cases := []struct {
+		name   string
+		status int
+		to     []string
+		body   string
+	}{
+		{"field with several addresses", http.StatusUnprocessableEntity, []string{"a@example.net", "b@example.net"},
+			"{\"statusCode\":422,\"name\":\"validation_error\",\"message\":\"Invalid `to` field.\"}"},
+		{"testing restriction", http.StatusForbidden, []string{"b@example.net"},
+			`{"statusCode":403,"name":"validation_error","message":"You can only send testing emails to your own email address (a@example.com)."}`},
+		{"rate limit", http.StatusTooManyRequests, []string{"b@example.net"},
+			`{"statusCode":429,"name":"rate_limit_exceeded","message":"Too many requests."}`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, _ := stubClient(t, tc.status, tc.body)
+			_, err := c.Send(testEmail(tc.to...))
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != tc.status {
+				t.Errorf("got %#v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package resend
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/igorrius/resend-railway-gateway/internal/domain"
+	resendgo "github.com/resend/resend-go/v2"
+)
+
+// DryRunIDPrefix starts every ID returned by DryRun, so that synthetic IDs
+// cannot be mistaken for ones issued by Resend.
+const DryRunIDPrefix = "dry-run-"
+
+// DryRun implements domain.OutboundEmailSender without contacting Resend.
+// It records the request Client.Send would post, with attachment contents
+// replaced by their size and SHA-256, and returns a synthetic ID.
+type DryRun struct {
+	log *slog.Logger
+	dir string
+}
+
+// NewDryRun creates a DryRun sender logging requests at debug level to log.
+// When dir is not empty each request is also written to dir as <id>.json.
+func NewDryRun(log *slog.Logger, dir string) (*DryRun, error) {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o750); err != nil {
+			return nil, err
+		}
+	}
+	return &DryRun{log: log, dir: dir}, nil
+}
+
+// dryRunRequest is the serialized form of a request. Its Attachments field
+// shadows the embedded one so that contents are summarized.
+type dryRunRequest struct {
+	ID string `json:"id"`
+	*resendgo.SendEmailRequest
+	Attachments    []attachmentSummary `json:"attachments,omitempty"`
+	IdempotencyKey string              `json:"idempotency_key,omitempty"`
+}
+
+type attachmentSummary struct {
+	Filename string `json:"filename"`
+	Size     int    `json:"size"`
+	SHA256   string `json:"sha256"`
+}
+
+// Render returns the JSON form of the request that would be sent for email.
+func Render(id string, email domain.Email) ([]byte, error) {
+	req := dryRunRequest{ID: id, SendEmailRequest: NewRequest(email), IdempotencyKey: email.IdempotencyKey}
+	for _, a := range email.Attachments {
+		sum := sha256.Sum256(a.Content)
+		req.Attachments = append(req.Attachments, attachmentSummary{
+			Filename: a.Filename,
+			Size:     len(a.Content),
+			SHA256:   hex.EncodeToString(sum[:]),
+		})
+	}
+	return json.MarshalIndent(req, "", "  ")
+}
+
+// Send records the rendered request and returns a synthetic ID.
+func (d *DryRun) Send(email domain.Email) (string, error) {
+	id := newDryRunID()
+	data, err := Render(id, email)
+	if err != nil {
+		return "", err
+	}
+	d.log.LogAttrs(context.Background(), slog.LevelDebug, "dry_run_request",
+		slog.String("id", id), slog.String("request", string(data)))
+	if d.dir != "" {
+		if err := os.WriteFile(filepath.Join(d.dir, id+".json"), data, 0o640); err != nil {
+			return "", err
+		}
+	}
+	return id, nil
+}
+
+func newDryRunID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return DryRunIDPrefix + hex.EncodeToString(b)
+}
+
+// DryRunSwitch sends emails flagged with domain.Email.DryRun to DryRun and
+// everything else to Live.
+type DryRunSwitch struct {
+	Live   domain.OutboundEmailSender
+	DryRun *DryRun
+}
+
+// Send dispatches the email according to its DryRun flag.
+func (s DryRunSwitch) Send(email domain.Email) (string, error) {
+	if email.DryRun {
+		return s.DryRun.Send(email)
+	}
+	return s.Live.Send(email)
+}
+
+var (
+	_ domain.OutboundEmailSender = (*DryRun)(nil)
+	_ domain.OutboundEmailSender = DryRunSwitch{}
+)
@@ -0,0 +1,89 @@
+package resend
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/igorrius/resend-railway-gateway/internal/domain"
+)
+
+func dryRunEmail() domain.Email {
+	return domain.Email{
+		From:           domain.Address{Name: "Alice", Addr: "alice@example.com"},
+		To:             []domain.Address{{Addr: "bob@example.com"}},
+		ReplyTo:        []domain.Address{{Addr: "a@example.com"}, {Addr: "b@example.com"}},
+		Subject:        "Hi",
+		Text:           "Hello",
+		Attachments:    []domain.Attachment{{Filename: "a.txt", Content: []byte("abc")}},
+		Tags:           []domain.Tag{{Name: "k", Value: "v"}},
+		IdempotencyKey: "order-1",
+	}
+}
+
+func TestDryRun_Send(t *testing.T) {
+	var logs bytes.Buffer
+	log := slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	dir := t.TempDir()
+	d, err := NewDryRun(log, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := d.Send(dryRunEmail())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(id, DryRunIDPrefix) {
+		t.Errorf("unexpected id %q", id)
+	}
+	if !strings.Contains(logs.String(), `"msg":"dry_run_request"`) || !strings.Contains(logs.String(), id) {
+		t.Errorf("expected a debug log line, got %s", logs.String())
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, id+".json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["from"] != `"Alice" <alice@example.com>` || got["reply_to"] != "a@example.com, b@example.com" || got["idempotency_key"] != "order-1" {
+		t.Errorf("unexpected request %s", data)
+	}
+	att := got["attachments"].([]any)[0].(map[string]any)
+	// sha256("abc")
+	if att["size"] != float64(3) || att["sha256"] != "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad" {
+		t.Errorf("unexpected attachment summary %v", att)
+	}
+	if _, ok := att["content"]; ok || strings.Contains(string(data), "YWJj") {
+		t.Error("attachment content must not be serialized")
+	}
+}
+
+type stubSender struct{ calls int }
+
+func (s *stubSender) Send(domain.Email) (string, error) {
+	s.calls++
+	return "live", nil
+}
+
+func TestDryRunSwitch(t *testing.T) {
+	live := &stubSender{}
+	d, _ := NewDryRun(slog.New(slog.DiscardHandler), "")
+	s := DryRunSwitch{Live: live, DryRun: d}
+
+	if id, _ := s.Send(dryRunEmail()); id != "live" {
+		t.Errorf("expected live send, got %q", id)
+	}
+	email := dryRunEmail()
+	email.DryRun = true
+	if id, _ := s.Send(email); !strings.HasPrefix(id, DryRunIDPrefix) || live.calls != 1 {
+		t.Errorf("expected dry run, got %q with %d live calls", id, live.calls)
+	}
+}
@@ -0,0 +1,115 @@
+package resend
+
+import (
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/igorrius/resend-railway-gateway/internal/domain"
+)
+
+// MatchKind selects the email attribute a Route is matched against.
+type MatchKind string
+
+const (
+	// MatchUser matches the authenticated SMTP user.
+	MatchUser MatchKind = "user"
+	// MatchMailFrom matches the domain of the envelope sender (MAIL FROM).
+	MatchMailFrom MatchKind = "mail-from"
+	// MatchFrom matches the domain of the header From address.
+	MatchFrom MatchKind = "from"
+)
+
+// Route maps emails matching Kind and Value to a Resend API key.
+type Route struct {
+	Kind   MatchKind
+	Value  string
+	APIKey string
+}
+
+// ErrNoRoute is returned when no route matches an email and no default key is configured.
+var ErrNoRoute = errors.New("no resend api key configured for sender")
+
+// Router implements domain.OutboundEmailSender by selecting a Resend API key
+// per email. Routes are evaluated by kind (user, then MAIL FROM domain, then
+// header From domain) and fall back to the default key. Sender domains can
+// be forged, so domain routes only apply to emails of authenticated users;
+// they isolate tenants only when the sender policy limits each user to its
+// own domains. One Client is created
+// lazily per distinct key and cached for reuse.
+type Router struct {
+	mu         sync.Mutex
+	routes     []Route
+	defaultKey string
+	clients    map[string]*Client
+	opts       []ClientOption
+}
+
+// NewRouter creates a Router. defaultKey may be empty, in which case
+// unmatched emails fail with ErrNoRoute. The clients are created with opts.
+func NewRouter(routes []Route, defaultKey string, opts ...ClientOption) *Router {
+	return &Router{routes: routes, defaultKey: defaultKey, clients: map[string]*Client{}, opts: opts}
+}
+
+// SetKeys replaces the routes and the default key, for example after API
+// keys were rotated. Emails being sent keep the key they were resolved to.
+func (r *Router) SetKeys(routes []Route, defaultKey string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.routes, r.defaultKey = routes, defaultKey
+	r.clients = map[string]*Client{}
+}
+
+// Send resolves the API key for the email and sends it with the matching client.
+func (r *Router) Send(email domain.Email) (string, error) {
+	key := r.resolve(email)
+	if key == "" {
+		return "", ErrNoRoute
+	}
+	return r.client(key).Send(email)
+}
+
+func (r *Router) resolve(email domain.Email) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	mailFromDomain := ""
+	if i := strings.LastIndexByte(email.Envelope.MailFrom, '@'); i >= 0 {
+		mailFromDomain = email.Envelope.MailFrom[i+1:]
+	}
+	candidates := []struct {
+		kind  MatchKind
+		value string
+	}{
+		{MatchUser, email.Envelope.User},
+		{MatchMailFrom, mailFromDomain},
+		{MatchFrom, email.From.Domain()},
+	}
+	for _, c := range candidates {
+		if c.value == "" || (c.kind != MatchUser && email.Envelope.User == "") {
+			continue
+		}
+		for _, rt := range r.routes {
+			if rt.Kind != c.kind {
+				continue
+			}
+			if (c.kind == MatchUser && rt.Value == c.value) ||
+				(c.kind != MatchUser && strings.EqualFold(rt.Value, c.value)) {
+				return rt.APIKey
+			}
+		}
+	}
+	return r.defaultKey
+}
+
+func (r *Router) client(key string) *Client {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.clients[key]
+	if !ok {
+		c = NewClient(key, r.opts...)
+		r.clients[key] = c
+	}
+	return c
+}
+
+var _ domain.OutboundEmailSender = (*Router)(nil)
@@ -0,0 +1,68 @@
+package resend
+
+import (
+	"testing"
+
+	"github.com/igorrius/resend-railway-gateway/internal/domain"
+)
+
+func TestRouter_Resolve(t *testing.T) {
+	r := NewRouter([]Route{
+		{Kind: MatchFrom, Value: "beta.io", APIKey: "re_from"},
+		{Kind: MatchMailFrom, Value: "Acme.com", APIKey: "re_mailfrom"},
+		{Kind: MatchUser, Value: "alice", APIKey: "re_user"},
+	}, "re_default")
+
+	cases := []struct {
+		name  string
+		email domain.Email
+		want  string
+	}{
+		{"user wins", domain.Email{From: domain.Address{Addr: "x@beta.io"}, Envelope: domain.Envelope{User: "alice", MailFrom: "b@acme.com"}}, "re_user"},
+		{"mail from domain", domain.Email{From: domain.Address{Addr: "x@beta.io"}, Envelope: domain.Envelope{User: "bob", MailFrom: "b@ACME.com"}}, "re_mailfrom"},
+		{"header from domain", domain.Email{From: domain.Address{Addr: "x@beta.io"}, Envelope: domain.Envelope{User: "bob", MailFrom: "b@other.com"}}, "re_from"},
+		{"unauthenticated", domain.Email{From: domain.Address{Addr: "x@beta.io"}, Envelope: domain.Envelope{MailFrom: "b@acme.com"}}, "re_default"},
+		{"default", domain.Email{From: domain.Address{Addr: "x@other.com"}}, "re_default"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := r.resolve(c.email); got != c.want {
+				t.Errorf("expected %s, got %s", c.want, got)
+			}
+		})
+	}
+}
+
+func TestRouter_NoRoute(t *testing.T) {
+	r := NewRouter(nil, "")
+	if _, err := r.Send(domain.Email{From: domain.Address{Addr: "x@example.com"}}); err != ErrNoRoute {
+		t.Errorf("expected ErrNoRoute, got %v", err)
+	}
+}
+
+func TestRouter_CachesClientPerKey(t *testing.T) {
+	r := NewRouter(nil, "")
+	if r.client("re_a") != r.client("re_a") {
+		t.Errorf("expected the same client for the same key")
+	}
+	if r.client("re_a") == r.client("re_b") {
+		t.Errorf("expected distinct clients for distinct keys")
+	}
+}
+
+func TestRouter_SetKeys(t *testing.T) {
+	r := NewRouter([]Route{{Kind: MatchUser, Value: "alice", APIKey: "re_old_alice"}}, "re_old")
+	old := r.client("re_old")
+	r.SetKeys([]Route{{Kind: MatchUser, Value: "alice", APIKey: "re_new_alice"}}, "re_new")
+
+	alice := domain.Email{From: domain.Address{Addr: "a@example.com"}, Envelope: domain.Envelope{User: "alice"}}
+	if got := r.resolve(alice); got != "re_new_alice" {
+		t.Errorf("route key = %s", got)
+	}
+	if got := r.resolve(domain.Email{From: domain.Address{Addr: "b@example.com"}}); got != "re_new" {
+		t.Errorf("default key = %s", got)
+	}
+	if r.client("re_old") == old {
+		t.Errorf("expected clients of replaced keys to be dropped")
+	}
+}
@@ -0,0 +1,36 @@
+package smtp
+
+import (
+	"crypto/subtle"
+
+	"github.com/emersion/go-sasl"
+	goSMTP "github.com/emersion/go-smtp"
+)
+
+// AuthMechanisms advertises AUTH PLAIN when SMTP users are configured.
+func (s *Session) AuthMechanisms() []string {
+	if !s.backend.authEnabled() {
+		return nil
+	}
+	return []string{sasl.Plain}
+}
+
+// Auth authenticates the client against the configured SMTP users.
+func (s *Session) Auth(mech string) (sasl.Server, error) {
+	if mech != sasl.Plain || !s.backend.authEnabled() {
+		return nil, goSMTP.ErrAuthUnknownMechanism
+	}
+	return sasl.NewPlainServer(func(identity, username, password string) error {
+		if identity != "" && identity != username {
+			return goSMTP.ErrAuthFailed
+		}
+		want, ok := s.backend.password(username)
+		if !ok || subtle.ConstantTimeCompare([]byte(want), []byte(password)) != 1 {
+			return goSMTP.ErrAuthFailed
+		}
+		s.user = username
+		return nil
+	}), nil
+}
+
+var _ goSMTP.AuthSession = (*Session)(nil)
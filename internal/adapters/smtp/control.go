@@ -0,0 +1,87 @@
+package smtp
+
+import (
+	"bytes"
+	"net/textproto"
+	"strconv"
+	"strings"
+
+	"github.com/igorrius/resend-railway-gateway/internal/domain"
+)
+
+// Control headers let SMTP-only clients pass Resend-specific options.
+// They are consumed by the gateway and never forwarded to the provider, nor
+// kept in the raw message that is archived and returned in notifications.
+const (
+	controlHeaderPrefix = "X-Resend-"
+
+	HeaderTags           = "X-Resend-Tags"
+	HeaderScheduledAt    = "X-Resend-Scheduled-At"
+	HeaderIdempotencyKey = "X-Resend-Idempotency-Key"
+	HeaderDryRun         = "X-Resend-Dry-Run"
+)
+
+// applyControlHeaders maps X-Resend-* headers onto the email and removes every
+// header with that prefix from email.Headers and email.Raw. Values are stored
+// as given; they are checked later by email.Validate.
+func applyControlHeaders(hdr textproto.MIMEHeader, email *domain.Email) {
+	for _, v := range hdr.Values(HeaderTags) {
+		email.Tags = append(email.Tags, parseTags(v)...)
+	}
+	email.ScheduledAt = strings.TrimSpace(hdr.Get(HeaderScheduledAt))
+	email.IdempotencyKey = strings.TrimSpace(hdr.Get(HeaderIdempotencyKey))
+	email.DryRun, _ = strconv.ParseBool(strings.TrimSpace(hdr.Get(HeaderDryRun)))
+
+	for k := range email.Headers {
+		if strings.HasPrefix(textproto.CanonicalMIMEHeaderKey(k), controlHeaderPrefix) {
+			delete(email.Headers, k)
+		}
+	}
+	for k := range hdr {
+		if strings.HasPrefix(k, controlHeaderPrefix) {
+			email.Raw = stripControlHeaders(email.Raw)
+			break
+		}
+	}
+}
+
+// stripControlHeaders returns a copy of the message raw without its
+// X-Resend-* header fields, including their continuation lines.
+func stripControlHeaders(raw []byte) []byte {
+	out := make([]byte, 0, len(raw))
+	skip := false
+	for rest := raw; len(rest) > 0; {
+		line := rest
+		if i := bytes.IndexByte(rest, '\n'); i >= 0 {
+			line = rest[:i+1]
+		}
+		rest = rest[len(line):]
+		if len(bytes.TrimRight(line, "\r\n")) == 0 {
+			// The blank line ends the header; the body is kept as is.
+			return append(append(out, line...), rest...)
+		}
+		if line[0] != ' ' && line[0] != '\t' {
+			name, _, _ := bytes.Cut(line, []byte(":"))
+			skip = strings.HasPrefix(textproto.CanonicalMIMEHeaderKey(string(bytes.TrimSpace(name))), controlHeaderPrefix)
+		}
+		if !skip {
+			out = append(out, line...)
+		}
+	}
+	return out
+}
+
+// parseTags parses a comma separated list of name=value pairs.
+// A pair without '=' yields a tag with an empty value.
+func parseTags(s string) []domain.Tag {
+	var tags []domain.Tag
+	for _, p := range strings.Split(s, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(p, "=")
+		tags = append(tags, domain.Tag{Name: strings.TrimSpace(name), Value: strings.TrimSpace(value)})
+	}
+	return tags
+}
@@ -0,0 +1,50 @@
+package smtp
+
+import (
+	"errors"
+	"strings"
+
+	goSMTP "github.com/emersion/go-smtp"
+	"github.com/igorrius/resend-railway-gateway/internal/domain"
+)
+
+// smtpError maps errors returned by the application service to SMTP replies
+// so that clients get a meaningful status code instead of a generic 554.
+// Errors that are not recognized are returned unchanged.
+func smtpError(err error) error {
+	if errors.Is(err, domain.ErrSenderNotAllowed) {
+		return &goSMTP.SMTPError{Code: 553, EnhancedCode: goSMTP.EnhancedCode{5, 7, 1}, Message: capitalize(err.Error())}
+	}
+	if errors.Is(err, domain.ErrRecipientNotAllowed) {
+		return &goSMTP.SMTPError{Code: 550, EnhancedCode: goSMTP.EnhancedCode{5, 7, 1}, Message: capitalize(err.Error())}
+	}
+	if errors.Is(err, domain.ErrRecipientSuppressed) {
+		return &goSMTP.SMTPError{Code: 550, EnhancedCode: goSMTP.EnhancedCode{5, 1, 1}, Message: capitalize(err.Error())}
+	}
+	var rerr *domain.RecipientRejectedError
+	if errors.As(err, &rerr) {
+		if rerr.Permanent {
+			return &goSMTP.SMTPError{Code: 550, EnhancedCode: goSMTP.EnhancedCode{5, 1, 1}, Message: capitalize(rerr.Error())}
+		}
+		return &goSMTP.SMTPError{Code: 450, EnhancedCode: goSMTP.EnhancedCode{4, 2, 0}, Message: capitalize(rerr.Error())}
+	}
+	var verr *domain.ValidationError
+	if !errors.As(err, &verr) {
+		return err
+	}
+	switch {
+	case verr.Has(domain.ViolationTooLarge):
+		return &goSMTP.SMTPError{Code: 552, EnhancedCode: goSMTP.EnhancedCode{5, 3, 4}, Message: verr.Error()}
+	case verr.Has(domain.ViolationRecipient):
+		return &goSMTP.SMTPError{Code: 550, EnhancedCode: goSMTP.EnhancedCode{5, 1, 3}, Message: verr.Error()}
+	default:
+		return &goSMTP.SMTPError{Code: 554, EnhancedCode: goSMTP.EnhancedCode{5, 6, 0}, Message: verr.Error()}
+	}
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
@@ -0,0 +1,83 @@
+package smtp
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	goSMTP "github.com/emersion/go-smtp"
+	"github.com/igorrius/resend-railway-gateway/internal/domain"
+)
+
+func TestSMTPError_ValidationCodes(t *testing.T) {
+	big := domain.Attachment{Filename: "big.bin", Content: make([]byte, domain.MaxAttachmentBytes)}
+	cases := []struct {
+		name  string
+		email domain.Email
+		code  int
+	}{
+		{"too large", validEmail(func(e *domain.Email) { e.Attachments = []domain.Attachment{big} }), 552},
+		{"too many recipients", validEmail(func(e *domain.Email) {
+			for i := 0; i <= domain.MaxRecipients; i++ {
+				e.To = append(e.To, domain.Address{Addr: "r@example.com"})
+			}
+		}), 550},
+		{"subject newline", validEmail(func(e *domain.Email) { e.Subject = "a\r\nBcc: x@example.com" }), 554},
+		{"no body", validEmail(func(e *domain.Email) { e.Text = "" }), 554},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var serr *goSMTP.SMTPError
+			if !errors.As(smtpError(c.email.Validate()), &serr) {
+				t.Fatalf("expected *SMTPError")
+			}
+			if serr.Code != c.code {
+				t.Errorf("expected code %d, got %d (%s)", c.code, serr.Code, serr.Message)
+			}
+			if serr.Message == "" || strings.Contains(serr.Message, "\n") {
+				t.Errorf("expected single-line message, got %q", serr.Message)
+			}
+		})
+	}
+}
+
+func TestSMTPError_RecipientCodes(t *testing.T) {
+	cases := []struct {
+		name     string
+		err      error
+		code     int
+		enhanced goSMTP.EnhancedCode
+	}{
+		{"suppressed", fmt.Errorf("%w: a@example.com", domain.ErrRecipientSuppressed), 550, goSMTP.EnhancedCode{5, 1, 1}},
+		{"permanent rejection", fmt.Errorf("send failed: %w", &domain.RecipientRejectedError{Recipients: []string{"a@example.com"}, Permanent: true, Message: "no such user"}), 550, goSMTP.EnhancedCode{5, 1, 1}},
+		{"temporary rejection", &domain.RecipientRejectedError{Recipients: []string{"a@example.com"}, Message: "mailbox full"}, 450, goSMTP.EnhancedCode{4, 2, 0}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var serr *goSMTP.SMTPError
+			if !errors.As(smtpError(c.err), &serr) {
+				t.Fatalf("expected *SMTPError")
+			}
+			if serr.Code != c.code || serr.EnhancedCode != c.enhanced {
+				t.Errorf("expected %d %v, got %d %v", c.code, c.enhanced, serr.Code, serr.EnhancedCode)
+			}
+		})
+	}
+}
+
+func TestSMTPError_PassThrough(t *testing.T) {
+	err := errors.New("boom")
+	if got := smtpError(err); got != err {
+		t.Errorf("expected error to be returned unchanged, got %v", got)
+	}
+	if smtpError(nil) != nil {
+		t.Errorf("expected nil for nil error")
+	}
+}
+
+func validEmail(mutate func(*domain.Email)) domain.Email {
+	e, _ := domain.NewEmail("sender@example.com", []string{"recipient@example.com"}, "Test", "Body", "", nil)
+	mutate(&e)
+	return e
+}
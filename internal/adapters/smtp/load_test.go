@@ -0,0 +1,285 @@
+package smtp
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/textproto"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/igorrius/resend-railway-gateway/internal/domain"
+)
+
+// The load harness drives an in-process server with many concurrent SMTP
+// clients whose messages end at a provider that only counts them. Run it with
+//
+//	make loadtest
+//
+// msgs/s is the end-to-end throughput and B/msg the memory allocated per
+// message by client, server and provider together. TestLoadBudget runs with
+// the other tests and fails when memory per message exceeds its budget.
+
+type countingSender struct{ n atomic.Int64 }
+
+func (c *countingSender) Send(domain.Email) (string, error) {
+	c.n.Add(1)
+	return "id", nil
+}
+
+// bdatChunk is the chunk size used by the BDAT load client.
+const bdatChunk = 64 << 10
+
+// loadClient is a minimal pipelining client: go-smtp's client supports
+// neither PIPELINING nor BDAT.
+type loadClient struct {
+	text *textproto.Conn
+}
+
+func dialLoad(addr string) (*loadClient, error) {
+	text, err := textproto.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &loadClient{text: text}
+	if _, _, err := text.ReadResponse(220); err != nil {
+		text.Close()
+		return nil, err
+	}
+	if _, err := text.Cmd("EHLO load.example.com"); err != nil {
+		text.Close()
+		return nil, err
+	}
+	if _, _, err := text.ReadResponse(250); err != nil {
+		text.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// send pipelines one transaction: MAIL, RCPT and either DATA or the message
+// as BDAT chunks, then reads all replies.
+func (c *loadClient) send(msg []byte, bdat bool) error {
+	w := c.text.W
+	fmt.Fprintf(w, "MAIL FROM:<sender@example.com> SIZE=%d\r\nRCPT TO:<recipient@example.com>\r\n", len(msg))
+	replies := []int{250, 250}
+	if bdat {
+		for off := 0; off < len(msg); off += bdatChunk {
+			chunk := msg[off:min(off+bdatChunk, len(msg))]
+			last := ""
+			if off+bdatChunk >= len(msg) {
+				last = " LAST"
+			}
+			fmt.Fprintf(w, "BDAT %d%s\r\n", len(chunk), last)
+			w.Write(chunk)
+			replies = append(replies, 250)
+		}
+	} else {
+		w.WriteString("DATA\r\n")
+		replies = append(replies, 354)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	for _, code := range replies {
+		if _, _, err := c.text.ReadResponse(code); err != nil {
+			return err
+		}
+	}
+	if bdat {
+		return nil
+	}
+	w.Write(msg)
+	w.WriteString(".\r\n")
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	_, _, err := c.text.ReadResponse(250)
+	return err
+}
+
+// loadMessage builds a multipart message with an attachment of the given
+// size; base64 lines never start with a dot, so it needs no dot-stuffing.
+func loadMessage(attachment int) []byte {
+	var b bytes.Buffer
+	b.WriteString("From: sender@example.com\r\nTo: recipient@example.com\r\nSubject: Load test\r\n")
+	b.WriteString("MIME-Version: 1.0\r\nContent-Type: multipart/mixed; boundary=load\r\n\r\n")
+	b.WriteString("--load\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.Repeat("The quick brown fox jumps over the lazy dog.\r\n", 20))
+	if attachment > 0 {
+		data := make([]byte, attachment)
+		rand.Read(data)
+		enc := base64.StdEncoding.EncodeToString(data)
+		b.WriteString("--load\r\nContent-Type: application/octet-stream\r\n")
+		b.WriteString("Content-Disposition: attachment; filename=\"data.bin\"\r\nContent-Transfer-Encoding: base64\r\n\r\n")
+		for len(enc) > 76 {
+			b.WriteString(enc[:76] + "\r\n")
+			enc = enc[76:]
+		}
+		b.WriteString(enc + "\r\n")
+	}
+	b.WriteString("--load--\r\n")
+	return b.Bytes()
+}
+
+func TestLoadClient(t *testing.T) {
+	rec := &recordingSender{}
+	addr := startServer(t, rec)
+	c, err := dialLoad(addr)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer c.text.Close()
+
+	msg := loadMessage(200 << 10)
+	for _, bdat := range []bool{false, true} {
+		if err := c.send(msg, bdat); err != nil {
+			t.Fatalf("send (bdat=%v): %v", bdat, err)
+		}
+	}
+	sent := rec.emails()
+	if len(sent) != 2 {
+		t.Fatalf("expected 2 emails, got %d", len(sent))
+	}
+	for i, email := range sent {
+		if !bytes.Equal(email.Raw, msg) {
+			t.Errorf("email %d: raw message differs (%d bytes, want %d)", i, len(email.Raw), len(msg))
+		}
+		if len(email.Attachments) != 1 || len(email.Attachments[0].Content) != 200<<10 {
+			t.Errorf("email %d: unexpected attachments", i)
+		}
+	}
+}
+
+func BenchmarkLoad(b *testing.B) {
+	for _, size := range []struct {
+		name       string
+		attachment int
+	}{
+		{"small", 0},
+		{"1MiB", 1 << 20},
+		{"10MiB", 10 << 20},
+	} {
+		msg := loadMessage(size.attachment)
+		for _, bdat := range []bool{false, true} {
+			name := size.name + "/DATA"
+			if bdat {
+				name = size.name + "/BDAT"
+			}
+			b.Run(name, func(b *testing.B) { runLoad(b, msg, bdat) })
+		}
+	}
+}
+
+// runLoad sends b.N messages over GOMAXPROCS*4 concurrent connections.
+func runLoad(b *testing.B, msg []byte, bdat bool) {
+	b.SetBytes(int64(len(msg)))
+	res := driveLoad(b, msg, bdat, b.N, runtime.GOMAXPROCS(0)*4)
+	b.ReportMetric(res.msgsPerSec, "msgs/s")
+	b.ReportMetric(res.bytesPerMsg, "B/msg")
+}
+
+// loadResult is the throughput and memory measured by driveLoad.
+type loadResult struct {
+	msgsPerSec  float64
+	bytesPerMsg float64
+}
+
+// driveLoad sends n messages over the given number of concurrent connections
+// to a fresh server and measures the run.
+func driveLoad(tb testing.TB, msg []byte, bdat bool, n, clients int) loadResult {
+	sender := &countingSender{}
+	addr := startServer(tb, sender)
+	conns := make([]*loadClient, clients)
+	for i := range conns {
+		c, err := dialLoad(addr)
+		if err != nil {
+			tb.Fatalf("dial: %v", err)
+		}
+		defer c.text.Close()
+		conns[i] = c
+	}
+	work := make(chan struct{}, n)
+	for i := 0; i < n; i++ {
+		work <- struct{}{}
+	}
+	close(work)
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	if b, ok := tb.(*testing.B); ok {
+		b.ResetTimer()
+	}
+	start := time.Now()
+	var wg sync.WaitGroup
+	for _, c := range conns {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range work {
+				if err := c.send(msg, bdat); err != nil {
+					tb.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+	if b, ok := tb.(*testing.B); ok {
+		b.StopTimer()
+	}
+	runtime.ReadMemStats(&after)
+
+	if got := sender.n.Load(); got != int64(n) {
+		tb.Fatalf("provider received %d of %d messages", got, n)
+	}
+	return loadResult{
+		msgsPerSec:  float64(n) / elapsed.Seconds(),
+		bytesPerMsg: float64(after.TotalAlloc-before.TotalAlloc) / float64(n),
+	}
+}
+
+// maxAllocFactor is the memory budget of TestLoadBudget: what client, server
+// and provider may allocate per message, as a multiple of the message size.
+// The message itself, its decoded attachment and the client's copy account
+// for about three.
+const maxAllocFactor = 4
+
+// TestLoadBudget fails when a change makes relaying a large message allocate
+// more than maxAllocFactor times its size, or, when LOADTEST_MIN_MSGS_PER_SEC
+// is set for a known machine, when throughput of 1 MiB messages drops below it.
+func TestLoadBudget(t *testing.T) {
+	if testing.Short() {
+		t.Skip("load test skipped in short mode")
+	}
+	if raceEnabled {
+		t.Skip("load budget does not apply under the race detector")
+	}
+	var minRate float64
+	if v := os.Getenv("LOADTEST_MIN_MSGS_PER_SEC"); v != "" {
+		var err error
+		if minRate, err = strconv.ParseFloat(v, 64); err != nil {
+			t.Fatalf("LOADTEST_MIN_MSGS_PER_SEC: %v", err)
+		}
+	}
+	msg := loadMessage(1 << 20)
+	for _, bdat := range []bool{false, true} {
+		res := driveLoad(t, msg, bdat, 40, 8)
+		t.Logf("bdat=%v: %.1f msgs/s, %.0f B/msg (%.2fx message size)", bdat, res.msgsPerSec, res.bytesPerMsg, res.bytesPerMsg/float64(len(msg)))
+		if limit := float64(maxAllocFactor * len(msg)); res.bytesPerMsg > limit {
+			t.Errorf("bdat=%v: %.0f B/msg exceeds the budget of %.0f", bdat, res.bytesPerMsg, limit)
+		}
+		if res.msgsPerSec < minRate {
+			t.Errorf("bdat=%v: %.1f msgs/s is below LOADTEST_MIN_MSGS_PER_SEC=%v", bdat, res.msgsPerSec, minRate)
+		}
+	}
+}
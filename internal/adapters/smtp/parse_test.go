@@ -1,7 +1,10 @@
 package smtp
 
 import (
+	"strings"
 	"testing"
+
+	"github.com/igorrius/resend-railway-gateway/internal/domain"
 )
 
 func TestParseMIMEMessage_SimpleText(t *testing.T) {
@@ -15,7 +18,7 @@ func TestParseMIMEMessage_SimpleText(t *testing.T) {
 	if email.Text != "Hello, World!" {
 		t.Errorf("expected text 'Hello, World!', got '%s'", email.Text)
 	}
-	if email.From != "sender@example.com" {
+	if email.From.Addr != "sender@example.com" {
 		t.Errorf("expected from 'sender@example.com', got '%s'", email.From)
 	}
 }
@@ -133,14 +136,14 @@ Body text
 
 	email := ParseMIMEMessage("sender@example.com", []string{"recipient@example.com"}, raw)
 
-	if len(email.Cc) != 1 || email.Cc[0] != "cc@example.com" {
+	if len(email.Cc) != 1 || email.Cc[0].Addr != "cc@example.com" {
 		t.Errorf("expected CC 'cc@example.com', got %v", email.Cc)
 	}
-	if len(email.Bcc) != 1 || email.Bcc[0] != "bcc@example.com" {
+	if len(email.Bcc) != 1 || email.Bcc[0].Addr != "bcc@example.com" {
 		t.Errorf("expected BCC 'bcc@example.com', got %v", email.Bcc)
 	}
-	if email.ReplyTo != "reply@example.com" {
-		t.Errorf("expected Reply-To 'reply@example.com', got '%s'", email.ReplyTo)
+	if len(email.ReplyTo) != 1 || email.ReplyTo[0].Addr != "reply@example.com" {
+		t.Errorf("expected Reply-To 'reply@example.com', got %v", email.ReplyTo)
 	}
 }
 
@@ -325,3 +328,170 @@ Attachment content
 		t.Errorf("expected default filename 'attachment', got '%s'", email.Attachments[0].Filename)
 	}
 }
+
+func TestParseMIMEMessage_ControlHeaders(t *testing.T) {
+	raw := []byte(`Subject: Test
+From: sender@example.com
+X-Resend-Tags: category=welcome,
+ tenant_id=acme-1
+X-Resend-Scheduled-At: 2030-01-02T15:04:05Z
+X-Resend-Idempotency-Key: order-42
+X-Resend-Dry-Run: true
+X-Resend-Unknown: ignored
+X-Custom-Header: kept
+
+Body text
+`)
+
+	email := ParseMIMEMessage("sender@example.com", []string{"recipient@example.com"}, raw)
+
+	if len(email.Tags) != 2 {
+		t.Fatalf("expected 2 tags, got %v", email.Tags)
+	}
+	if email.Tags[0] != (domain.Tag{Name: "category", Value: "welcome"}) {
+		t.Errorf("unexpected first tag %v", email.Tags[0])
+	}
+	if email.Tags[1] != (domain.Tag{Name: "tenant_id", Value: "acme-1"}) {
+		t.Errorf("unexpected second tag %v", email.Tags[1])
+	}
+	if email.ScheduledAt != "2030-01-02T15:04:05Z" {
+		t.Errorf("expected scheduled_at '2030-01-02T15:04:05Z', got '%s'", email.ScheduledAt)
+	}
+	if email.IdempotencyKey != "order-42" {
+		t.Errorf("expected idempotency key 'order-42', got '%s'", email.IdempotencyKey)
+	}
+	if !email.DryRun {
+		t.Error("expected dry run to be requested")
+	}
+	for k := range email.Headers {
+		if strings.HasPrefix(k, "X-Resend-") {
+			t.Errorf("control header %s should be stripped", k)
+		}
+	}
+	if email.Headers["X-Custom-Header"] != "kept" {
+		t.Errorf("expected X-Custom-Header to be kept, got '%s'", email.Headers["X-Custom-Header"])
+	}
+	if want := "Subject: Test\nFrom: sender@example.com\nX-Custom-Header: kept\n\nBody text\n"; string(email.Raw) != want {
+		t.Errorf("control headers should be stripped from the raw message, got %q", email.Raw)
+	}
+	if err := email.Validate(); err != nil {
+		t.Errorf("expected valid email, got %v", err)
+	}
+}
+
+func TestParseMIMEMessage_InvalidControlHeaders(t *testing.T) {
+	cases := map[string]string{
+		"tag characters": "X-Resend-Tags: bad tag=value",
+		"tag value":      "X-Resend-Tags: novalue",
+		"scheduled_at":   "X-Resend-Scheduled-At: tomorrow-ish",
+	}
+	for name, header := range cases {
+		t.Run(name, func(t *testing.T) {
+			raw := []byte("Subject: Test\nFrom: sender@example.com\n" + header + "\n\nBody")
+			email := ParseMIMEMessage("sender@example.com", []string{"recipient@example.com"}, raw)
+			if err := email.Validate(); err == nil {
+				t.Errorf("expected validation error for %q", header)
+			}
+		})
+	}
+}
+
+func TestParseMIMEMessage_DisplayNamesAndGroups(t *testing.T) {
+	raw := []byte(`Subject: Test
+From: "Sender, Team" <sender@example.com>
+Cc: "Doe, John" <john@example.com>, team: a@example.com, b@example.com;
+Reply-To: support@example.com, "Sales" <sales@example.com>
+
+Body text
+`)
+
+	email := ParseMIMEMessage("bounce@example.com", []string{"recipient@example.com"}, raw)
+
+	if email.From != (domain.Address{Name: "Sender, Team", Addr: "sender@example.com"}) {
+		t.Errorf("expected header From with display name, got %v", email.From)
+	}
+	want := []domain.Address{
+		{Name: "Doe, John", Addr: "john@example.com"},
+		{Addr: "a@example.com"},
+		{Addr: "b@example.com"},
+	}
+	if len(email.Cc) != len(want) {
+		t.Fatalf("expected %d cc addresses, got %v", len(want), email.Cc)
+	}
+	for i := range want {
+		if email.Cc[i] != want[i] {
+			t.Errorf("cc[%d]: expected %v, got %v", i, want[i], email.Cc[i])
+		}
+	}
+	if len(email.ReplyTo) != 2 || email.ReplyTo[1].Name != "Sales" {
+		t.Errorf("expected two Reply-To addresses, got %v", email.ReplyTo)
+	}
+	if got := email.Cc[0].String(); got != `"Doe, John" <john@example.com>` {
+		t.Errorf("unexpected rendering %s", got)
+	}
+	if err := email.Validate(); err != nil {
+		t.Errorf("expected valid email, got %v", err)
+	}
+}
+
+func TestParseMIMEMessage_InvalidAddressRejected(t *testing.T) {
+	raw := []byte("Subject: Test\nFrom: sender@example.com\nCc: not an address\n\nBody")
+
+	email := ParseMIMEMessage("sender@example.com", []string{"recipient@example.com"}, raw)
+
+	if err := email.Validate(); err == nil {
+		t.Errorf("expected validation error for malformed Cc, got cc=%v", email.Cc)
+	}
+}
+
+func TestParseMIMEMessage_MixedScripts(t *testing.T) {
+	raw := []byte("From: =?UTF-8?B?0JjQstCw0L0=?= <иван@пример.рф>\r\n" +
+		"To: 田中 <tanaka@例え.jp>\r\n" +
+		"Cc: Müller <müller@bücher.de>\r\n" +
+		"Subject: =?ISO-2022-JP?B?GyRCRnxLXDhsGyhC?= / =?KOI8-R?Q?=F0=D2=C9=D7=C5=D4?= / Grüße\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: multipart/mixed; boundary=b\r\n" +
+		"\r\n" +
+		"--b\r\n" +
+		"Content-Type: text/plain; charset=koi8-r\r\n" +
+		"Content-Transfer-Encoding: quoted-printable\r\n" +
+		"\r\n" +
+		"=F0=D2=C9=D7=C5=D4, world\r\n" +
+		"--b\r\n" +
+		"Content-Type: text/html; charset=utf-8\r\n" +
+		"\r\n" +
+		"<p>日本語 العربية</p>\r\n" +
+		"--b\r\n" +
+		"Content-Type: text/plain\r\n" +
+		"Content-Disposition: attachment; filename=\"=?UTF-8?Q?r=C3=A9sum=C3=A9.txt?=\"\r\n" +
+		"\r\n" +
+		"cv\r\n" +
+		"--b--\r\n")
+
+	email := ParseMIMEMessage("иван@пример.рф", []string{"tanaka@例え.jp"}, raw)
+
+	if want := "日本語 / Привет / Grüße"; email.Subject != want {
+		t.Errorf("subject = %q, want %q", email.Subject, want)
+	}
+	if email.From != (domain.Address{Name: "Иван", Addr: "иван@xn--e1afmkfd.xn--p1ai"}) {
+		t.Errorf("unexpected From %+v", email.From)
+	}
+	if len(email.To) != 1 || email.To[0].Addr != "tanaka@xn--r8jz45g.jp" {
+		t.Errorf("unexpected To %+v", email.To)
+	}
+	if len(email.Cc) != 1 || email.Cc[0] != (domain.Address{Name: "Müller", Addr: "müller@xn--bcher-kva.de"}) {
+		t.Errorf("unexpected Cc %+v", email.Cc)
+	}
+	if !strings.HasPrefix(email.Text, "Привет, world") {
+		t.Errorf("text not converted from koi8-r: %q", email.Text)
+	}
+	if !strings.Contains(email.HTML, "日本語 العربية") {
+		t.Errorf("unexpected HTML %q", email.HTML)
+	}
+	if len(email.Attachments) != 1 || email.Attachments[0].Filename != "résumé.txt" {
+		t.Errorf("unexpected attachments %+v", email.Attachments)
+	}
+	if err := email.Validate(); err != nil {
+		t.Errorf("expected valid email, got %v", err)
+	}
+}
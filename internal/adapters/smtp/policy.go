@@ -0,0 +1,118 @@
+package smtp
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+
+	goSMTP "github.com/emersion/go-smtp"
+)
+
+// networkShortcuts expands symbolic names accepted in network lists.
+var networkShortcuts = map[string][]string{
+	"any":        {"0.0.0.0/0", "::/0"},
+	"loopback":   {"127.0.0.0/8", "::1/128"},
+	"private":    {"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "100.64.0.0/10", "fc00::/7"},
+	"link-local": {"169.254.0.0/16", "fe80::/10"},
+}
+
+// ParseNetworks parses CIDR prefixes, bare IP addresses and the shortcuts
+// "any", "loopback", "private" and "link-local".
+func ParseNetworks(entries []string) ([]netip.Prefix, error) {
+	var out []netip.Prefix
+	for _, e := range entries {
+		e = strings.TrimSpace(e)
+		if e == "" {
+			continue
+		}
+		if expanded, ok := networkShortcuts[strings.ToLower(e)]; ok {
+			for _, cidr := range expanded {
+				out = append(out, netip.MustParsePrefix(cidr))
+			}
+			continue
+		}
+		if p, err := netip.ParsePrefix(e); err == nil {
+			out = append(out, p.Masked())
+			continue
+		}
+		a, err := netip.ParseAddr(e)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q", e)
+		}
+		out = append(out, netip.PrefixFrom(a.Unmap(), a.Unmap().BitLen()))
+	}
+	return out, nil
+}
+
+// ConnPolicy decides which clients may connect and whether they may relay
+// without authenticating.
+type ConnPolicy struct {
+	// Allow lists the networks allowed to connect; empty allows everyone.
+	Allow []netip.Prefix
+	// Deny lists networks that are always refused, even if allowed above.
+	Deny []netip.Prefix
+	// Trusted lists networks that may relay without AUTH when RequireAuth is set.
+	Trusted []netip.Prefix
+	// RequireAuth refuses MAIL FROM from unauthenticated clients outside Trusted.
+	RequireAuth bool
+}
+
+// AllowsConn reports whether a client at addr may open a session.
+// Clients without an IP address (e.g. Unix sockets) are always allowed.
+func (p ConnPolicy) AllowsConn(addr netip.Addr) bool {
+	if !addr.IsValid() {
+		return true
+	}
+	if contains(p.Deny, addr) {
+		return false
+	}
+	return len(p.Allow) == 0 || contains(p.Allow, addr)
+}
+
+// AllowsRelay reports whether a client may submit mail, given whether it has
+// authenticated.
+func (p ConnPolicy) AllowsRelay(addr netip.Addr, authenticated bool) bool {
+	if !p.RequireAuth || authenticated || !addr.IsValid() {
+		return true
+	}
+	return contains(p.Trusted, addr)
+}
+
+func contains(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, p := range prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteIP extracts the client IP from a connection address.
+// It returns the zero Addr for non-IP transports.
+func remoteIP(addr net.Addr) netip.Addr {
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		return a.AddrPort().Addr().Unmap()
+	case nil:
+		return netip.Addr{}
+	}
+	ap, err := netip.ParseAddrPort(addr.String())
+	if err != nil {
+		return netip.Addr{}
+	}
+	return ap.Addr().Unmap()
+}
+
+var (
+	errAccessDenied = &goSMTP.SMTPError{
+		Code:         554,
+		EnhancedCode: goSMTP.EnhancedCode{5, 7, 1},
+		Message:      "Access denied",
+	}
+	errRelayAuthRequired = &goSMTP.SMTPError{
+		Code:         530,
+		EnhancedCode: goSMTP.EnhancedCode{5, 7, 0},
+		Message:      "Authentication required",
+	}
+)
@@ -0,0 +1,90 @@
+package smtp
+
+import (
+	"errors"
+	"net/netip"
+	"strings"
+	"testing"
+
+	goSMTP "github.com/emersion/go-smtp"
+)
+
+func TestParseNetworks(t *testing.T) {
+	got, err := ParseNetworks([]string{"private", "203.0.113.7", "2001:db8::/32", " "})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != len(networkShortcuts["private"])+2 {
+		t.Fatalf("unexpected prefixes %v", got)
+	}
+	if got[len(got)-2] != netip.MustParsePrefix("203.0.113.7/32") {
+		t.Errorf("expected bare IP to become a /32, got %v", got[len(got)-2])
+	}
+	if _, err := ParseNetworks([]string{"10.0.0.0/33"}); err == nil {
+		t.Errorf("expected error for invalid prefix")
+	}
+}
+
+func TestConnPolicy(t *testing.T) {
+	allow, _ := ParseNetworks([]string{"10.0.0.0/8"})
+	deny, _ := ParseNetworks([]string{"10.6.6.0/24"})
+	trusted, _ := ParseNetworks([]string{"10.1.0.0/16"})
+	p := ConnPolicy{Allow: allow, Deny: deny, Trusted: trusted, RequireAuth: true}
+
+	cases := []struct {
+		ip        string
+		conn      bool
+		anonRelay bool
+	}{
+		{"10.1.2.3", true, true},
+		{"10.2.2.3", true, false},
+		{"10.6.6.6", false, false},
+		{"192.0.2.1", false, false},
+	}
+	for _, c := range cases {
+		addr := netip.MustParseAddr(c.ip)
+		if got := p.AllowsConn(addr); got != c.conn {
+			t.Errorf("%s: AllowsConn = %v, want %v", c.ip, got, c.conn)
+		}
+		if got := p.AllowsRelay(addr, false); got != c.anonRelay {
+			t.Errorf("%s: AllowsRelay(anonymous) = %v, want %v", c.ip, got, c.anonRelay)
+		}
+		if !p.AllowsRelay(addr, true) {
+			t.Errorf("%s: authenticated clients must always relay", c.ip)
+		}
+	}
+	if !p.AllowsConn(netip.Addr{}) {
+		t.Errorf("clients without an IP address must be allowed")
+	}
+}
+
+func TestServer_DeniedClientRejected(t *testing.T) {
+	deny, _ := ParseNetworks([]string{"loopback"})
+	addr := startServer(t, &recordingSender{}, WithConnPolicy(ConnPolicy{Deny: deny}))
+
+	c, err := goSMTP.Dial(addr)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer c.Close()
+	err = c.Hello("client.example.com")
+	var serr *goSMTP.SMTPError
+	if !errors.As(err, &serr) || serr.Code != 554 {
+		t.Fatalf("expected 554, got %v", err)
+	}
+}
+
+func TestServer_RelayRequiresAuthOutsideTrustedNetworks(t *testing.T) {
+	addr := startServer(t, &recordingSender{}, WithConnPolicy(ConnPolicy{RequireAuth: true}))
+
+	c, err := goSMTP.Dial(addr)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer c.Close()
+	err = c.SendMail("sender@example.com", []string{"recipient@example.com"}, strings.NewReader(testMessage))
+	var serr *goSMTP.SMTPError
+	if !errors.As(err, &serr) || serr.Code != 530 {
+		t.Fatalf("expected 530, got %v", err)
+	}
+}
@@ -0,0 +1,7 @@
+//go:build race
+
+package smtp
+
+// raceEnabled reports whether the tests run with the race detector, which
+// multiplies allocations and slows every message down.
+const raceEnabled = true
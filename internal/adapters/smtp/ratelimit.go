@@ -0,0 +1,62 @@
+package smtp
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	goSMTP "github.com/emersion/go-smtp"
+	"github.com/igorrius/resend-railway-gateway/internal/ratelimit"
+)
+
+var errTooManyConnections = &goSMTP.SMTPError{
+	Code:         421,
+	EnhancedCode: goSMTP.EnhancedCode{4, 7, 0},
+	Message:      "Too many connections from your address, try again later",
+}
+
+// WithRateLimits enables message quotas and per-client connection limits.
+// Either argument may be nil.
+func WithRateLimits(messages *ratelimit.Limiter, conns *ratelimit.ConnLimiter) Option {
+	return func(b *Backend) {
+		b.limiter = messages
+		b.conns = conns
+	}
+}
+
+// quotaSubjects returns the quota subjects of a message from the session:
+// its user, sender domain and client IP.
+func (s *Session) quotaSubjects(from string) map[ratelimit.Scope]string {
+	subjects := map[ratelimit.Scope]string{ratelimit.ScopeUser: s.user}
+	if i := strings.LastIndexByte(from, '@'); i >= 0 {
+		subjects[ratelimit.ScopeSenderDomain] = from[i+1:]
+	}
+	if s.remote.IsValid() {
+		subjects[ratelimit.ScopeClientIP] = s.remote.String()
+	}
+	return subjects
+}
+
+// checkQuota returns a 451 reply with a retry hint when a quota of a message
+// from the session is exhausted. The message is not counted until it is
+// accepted (see recordQuota), so transactions that are reset or fail do not
+// use up quota.
+func (s *Session) checkQuota(from string) error {
+	retry, ok := s.backend.limiter.Check(s.quotaSubjects(from))
+	if ok {
+		return nil
+	}
+	s.backend.logger.Info("smtp_rate_limited", map[string]any{
+		"client": s.remoteAddr, "user": s.user, "from": from, "retry_after": retry.String(),
+	})
+	return &goSMTP.SMTPError{
+		Code:         451,
+		EnhancedCode: goSMTP.EnhancedCode{4, 7, 1},
+		Message:      fmt.Sprintf("Message rate limit exceeded, try again in %d seconds", int(math.Ceil(retry.Seconds()))),
+	}
+}
+
+// recordQuota counts the accepted message of the current transaction.
+func (s *Session) recordQuota() {
+	s.backend.limiter.Record(s.quotaSubjects(s.mailFrom))
+}
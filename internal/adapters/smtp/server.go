@@ -8,65 +8,303 @@ import (
 	"mime"
 	"mime/multipart"
 	"mime/quotedprintable"
+	"net/netip"
 	"net/textproto"
 	"strings"
+	"sync"
 
 	goSMTP "github.com/emersion/go-smtp"
 	"github.com/igorrius/resend-railway-gateway/internal/app"
+	"github.com/igorrius/resend-railway-gateway/internal/charset"
 	"github.com/igorrius/resend-railway-gateway/internal/domain"
+	"github.com/igorrius/resend-railway-gateway/internal/listener"
+	"github.com/igorrius/resend-railway-gateway/internal/ratelimit"
 )
 
 // Session implements go-smtp's Session interface to handle SMTP protocol operations.
 // It collects email data during the SMTP conversation and sends it through the service.
 type Session struct {
-	service  *app.Service
-	mailFrom string
-	rcpts    []string
-	data     bytes.Buffer
+	backend    *Backend
+	remote     netip.Addr
+	remoteAddr string
+	user       string
+	mailFrom   string
+	rcpts      []string
+	// DSN parameters of the current transaction (RFC 3461).
+	ret      domain.DSNReturn
+	envID    string
+	dsnRcpts []domain.EnvelopeRecipient
 }
 
-func (s *Session) Reset()        { s.mailFrom = ""; s.rcpts = nil; s.data.Reset() }
-func (s *Session) Logout() error { return nil }
+func (s *Session) Reset() {
+	s.mailFrom = ""
+	s.rcpts = nil
+	s.ret, s.envID, s.dsnRcpts = "", "", nil
+}
+
+func (s *Session) Logout() error {
+	if s.remote.IsValid() {
+		s.backend.conns.Release(s.remote.String())
+	}
+	return nil
+}
 
-func (s *Session) Mail(from string, _ *goSMTP.MailOptions) error {
+func (s *Session) Mail(from string, opts *goSMTP.MailOptions) error {
+	if !s.backend.policy.AllowsRelay(s.remote, s.user != "") {
+		return errRelayAuthRequired
+	}
+	if err := s.backend.service.CheckSender(s.user, from); err != nil {
+		return smtpError(err)
+	}
+	if err := s.checkQuota(from); err != nil {
+		return err
+	}
 	s.mailFrom = from
+	if opts != nil {
+		s.ret = domain.DSNReturn(opts.Return)
+		s.envID = opts.EnvelopeID
+	}
 	return nil
 }
 
-func (s *Session) Rcpt(to string, _ *goSMTP.RcptOptions) error {
+func (s *Session) Rcpt(to string, opts *goSMTP.RcptOptions) error {
+	if err := s.backend.service.CheckRecipient(to); err != nil {
+		return smtpError(err)
+	}
 	s.rcpts = append(s.rcpts, to)
+	s.dsnRcpts = append(s.dsnRcpts, envelopeRecipient(to, opts))
 	return nil
 }
 
+func envelopeRecipient(to string, opts *goSMTP.RcptOptions) domain.EnvelopeRecipient {
+	r := domain.EnvelopeRecipient{Addr: to}
+	if opts == nil {
+		return r
+	}
+	for _, n := range opts.Notify {
+		r.Notify = append(r.Notify, domain.DSNNotify(n))
+	}
+	if opts.OriginalRecipient != "" {
+		r.Original = string(opts.OriginalRecipientType) + ";" + opts.OriginalRecipient
+	}
+	return r
+}
+
+// Data parses the message and hands it to the service.
 func (s *Session) Data(r io.Reader) error {
-	s.data.Reset()
-	_, err := io.Copy(&s.data, r)
+	email, err := s.readEmail(r)
+	if err != nil {
+		return err
+	}
+	if err := s.backend.service.HandleEmail(email); err != nil {
+		return smtpError(err)
+	}
+	s.recordQuota()
+	return nil
+}
+
+// LMTPData is Data for LMTP: it reports a separate status for every RCPT TO.
+func (s *Session) LMTPData(r io.Reader, status goSMTP.StatusCollector) error {
+	email, err := s.readEmail(r)
 	if err != nil {
 		return err
 	}
-	email := ParseMIMEMessage(s.mailFrom, s.rcpts, s.data.Bytes())
-	return s.service.HandleEmail(email)
+	results := s.backend.service.HandleEmailRecipients(email, s.rcpts)
+	accepted := false
+	for _, rcpt := range s.rcpts {
+		status.SetStatus(rcpt, smtpError(results[rcpt]))
+		accepted = accepted || results[rcpt] == nil
+	}
+	if accepted {
+		s.recordQuota()
+	}
+	return nil
+}
+
+// readEmail reads the message of the current transaction and parses it once
+// it is complete. The whole message is held in memory: the email keeps it as
+// Raw for archiving and notifications, and the provider API takes the
+// attachments in a single request. With CHUNKING, BDAT chunks are appended as
+// they arrive rather than collected by go-smtp first, and chunks beyond
+// MaxMessageBytes are refused before they are read. Memory follows the data
+// received (see readMessage); the SIZE a client announces is not trusted
+// for allocating it.
+func (s *Session) readEmail(r io.Reader) (domain.Email, error) {
+	raw, err := readMessage(r)
+	if err != nil {
+		return domain.Email{}, err
+	}
+	email := ParseMIMEMessage(s.mailFrom, s.rcpts, raw)
+	email.Envelope = domain.Envelope{
+		MailFrom:   s.mailFrom,
+		User:       s.user,
+		RemoteAddr: s.remoteAddr,
+		Return:     s.ret,
+		EnvelopeID: s.envID,
+		Recipients: s.dsnRcpts,
+	}
+	return email, nil
+}
+
+// messageBlock is the unit in which readMessage receives a message.
+const messageBlock = 64 << 10
+
+var messageBlocks = sync.Pool{New: func() any { return new([messageBlock]byte) }}
+
+// readMessage reads r into pooled blocks and copies them into a single
+// slice of the exact size once r is exhausted, so that a message costs one
+// allocation of its size instead of the repeated doubling of a growing
+// buffer.
+func readMessage(r io.Reader) ([]byte, error) {
+	var blocks []*[messageBlock]byte
+	defer func() {
+		for _, b := range blocks {
+			messageBlocks.Put(b)
+		}
+	}()
+	size, n := 0, messageBlock
+	for {
+		if n == messageBlock {
+			blocks = append(blocks, messageBlocks.Get().(*[messageBlock]byte))
+			n = 0
+		}
+		m, err := r.Read(blocks[len(blocks)-1][n:])
+		n += m
+		size += m
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	raw := make([]byte, 0, size)
+	for _, b := range blocks {
+		raw = append(raw, b[:min(messageBlock, size-len(raw))]...)
+	}
+	return raw, nil
 }
 
 // Backend implements go-smtp Backend to provide SMTP server functionality.
-type Backend struct{ service *app.Service }
+type Backend struct {
+	service *app.Service
+	logger  domain.MessageLogger
+	mu      sync.RWMutex
+	users   map[string]string
+	policy  ConnPolicy
+	limiter *ratelimit.Limiter
+	conns   *ratelimit.ConnLimiter
+	maxSize int64
+}
+
+// NewSession checks the client address against the connection policy and
+// refuses denied clients with 554 before any mail transaction starts.
+func (b *Backend) NewSession(c *goSMTP.Conn) (goSMTP.Session, error) {
+	addr := c.Conn().RemoteAddr()
+	remote := remoteIP(addr)
+	if !b.policy.AllowsConn(remote) {
+		b.logger.Info("smtp_conn_denied", map[string]any{"client": addr.String()})
+		return nil, errAccessDenied
+	}
+	if remote.IsValid() && !b.conns.Acquire(remote.String()) {
+		b.logger.Info("smtp_conn_limited", map[string]any{"client": addr.String()})
+		return nil, errTooManyConnections
+	}
+	return &Session{backend: b, remote: remote, remoteAddr: addr.String()}, nil
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Info(string, map[string]any)  {}
+func (nopLogger) Error(string, map[string]any) {}
+
+// Option configures optional Backend behaviour.
+type Option func(*Backend)
+
+// WithLogger sets the logger used for connection level events.
+func WithLogger(l domain.MessageLogger) Option {
+	return func(b *Backend) { b.logger = l }
+}
+
+// WithUsers enables AUTH PLAIN for the given username to password map.
+func WithUsers(users map[string]string) Option {
+	return func(b *Backend) { b.users = users }
+}
+
+// SetUsers replaces the SMTP users, for example after their passwords were
+// rotated. Sessions already authenticated keep their user; AUTH PLAIN is
+// advertised to new sessions while there are users.
+func (b *Backend) SetUsers(users map[string]string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.users = users
+}
+
+// password returns the password of the SMTP user name.
+func (b *Backend) password(name string) (string, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	p, ok := b.users[name]
+	return p, ok
+}
 
-func (b *Backend) NewSession(_ *goSMTP.Conn) (goSMTP.Session, error) {
-	return &Session{service: b.service}, nil
+// authEnabled reports whether SMTP users are configured.
+func (b *Backend) authEnabled() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.users) > 0
+}
+
+// WithMaxMessageBytes limits the size of messages; larger ones are refused
+// with 552 as soon as the limit is exceeded. Zero means unlimited.
+func WithMaxMessageBytes(n int64) Option {
+	return func(b *Backend) { b.maxSize = n }
+}
+
+// WithConnPolicy restricts which clients may connect and relay.
+func WithConnPolicy(p ConnPolicy) Option {
+	return func(b *Backend) { b.policy = p }
 }
 
 // NewServer creates and configures a new SMTP server.
-// - addr: Listen address (e.g., ":2525")
+// - addr: Listen address for ListenAndServe, e.g. ":2525" or a Unix socket
+// ("unix:/run/gateway/smtp.sock"); servers started with Serve on listeners
+// opened elsewhere may leave it empty
 // - service: Application service for handling emails
-func NewServer(addr string, service *app.Service) *goSMTP.Server {
-	backend := &Backend{service: service}
+// - opts: Optional backend behaviour such as authentication
+func NewServer(addr string, service *app.Service, opts ...Option) *goSMTP.Server {
+	backend := &Backend{service: service, logger: nopLogger{}}
+	for _, opt := range opts {
+		opt(backend)
+	}
 	s := goSMTP.NewServer(backend)
 	s.Addr = addr
+	if path, ok := listener.UnixPath(addr); ok {
+		s.Network, s.Addr = "unix", path
+	}
 	s.Domain = "localhost"
 	s.AllowInsecureAuth = true
+	s.EnableDSN = true
+	// 8BITMIME is always advertised; SMTPUTF8 lets clients use UTF-8 in
+	// envelope addresses and headers (RFC 6531, RFC 6532).
+	s.EnableSMTPUTF8 = true
+	// CHUNKING (BDAT) is always advertised; BINARYMIME requires it and lets
+	// clients skip base64 for binary content.
+	s.EnableBINARYMIME = true
+	s.MaxMessageBytes = backend.maxSize
 	return s
 }
 
+// NewLMTPServer is NewServer for LMTP (RFC 2033), typically on a Unix socket
+// or a private network: after DATA every recipient gets its own reply.
+func NewLMTPServer(addr string, service *app.Service, opts ...Option) *goSMTP.Server {
+	s := NewServer(addr, service, opts...)
+	s.LMTP = true
+	return s
+}
+
+var _ goSMTP.LMTPSession = (*Session)(nil)
+
 // ParseMIMEMessage performs a lightweight parse of headers and common MIME structures.
 // It supports:
 // - Simple text/plain and text/html messages
@@ -74,14 +312,17 @@ func NewServer(addr string, service *app.Service) *goSMTP.Server {
 // - Nested multipart messages
 // - Base64 and quoted-printable encoding
 // - Attachments (inline and regular)
+// - RFC 2047 encoded subjects and text in legacy charsets, converted to UTF-8
+// - X-Resend-* control headers (tags, scheduling, idempotency key)
 func ParseMIMEMessage(from string, rcpts []string, raw []byte) domain.Email {
 	headers := map[string]string{}
 	subject := ""
 	textBody := ""
 	htmlBody := ""
-	var cc []string
-	var bcc []string
-	replyTo := ""
+	var cc []domain.Address
+	var bcc []domain.Address
+	var replyTo []domain.Address
+	var headerFrom []domain.Address
 	attachments := make([]domain.Attachment, 0, 4)
 
 	mr := textproto.NewReader(bufio.NewReader(bytes.NewReader(raw)))
@@ -93,16 +334,13 @@ func ParseMIMEMessage(from string, rcpts []string, raw []byte) domain.Email {
 			}
 			headers[k] = v[0]
 		}
-		subject = hdr.Get("Subject")
-		if v := hdr.Get("Cc"); v != "" {
-			cc = splitAddrs(v)
-		}
-		if v := hdr.Get("Bcc"); v != "" {
-			bcc = splitAddrs(v)
-		}
-		if v := hdr.Get("Reply-To"); v != "" {
-			replyTo = v
+		subject = charset.DecodeHeader(hdr.Get("Subject"))
+		if v := hdr.Get("From"); v != "" {
+			headerFrom, _ = domain.ParseAddressList(v)
 		}
+		cc = domain.ParseAddressListLenient(hdr.Get("Cc"))
+		bcc = domain.ParseAddressListLenient(hdr.Get("Bcc"))
+		replyTo = domain.ParseAddressListLenient(hdr.Get("Reply-To"))
 
 		ct := hdr.Get("Content-Type")
 		mediatype, params, err := mime.ParseMediaType(ct)
@@ -133,22 +371,33 @@ func ParseMIMEMessage(from string, rcpts []string, raw []byte) domain.Email {
 					reader = quotedprintable.NewReader(bytes.NewReader(bodyData))
 				}
 				slurp, _ := io.ReadAll(reader)
+				body := charset.Decode(slurp, params["charset"])
 				if strings.HasPrefix(strings.ToLower(mediatype), "text/plain") {
-					textBody = string(slurp)
+					textBody = body
 				} else if strings.HasPrefix(strings.ToLower(mediatype), "text/html") {
-					htmlBody = string(slurp)
+					htmlBody = body
 				} else {
 					// Default to text if content type is not specified
-					textBody = string(slurp)
+					textBody = body
 				}
 			}
 		}
 	}
 	email, _ := domain.NewEmail(from, append([]string(nil), rcpts...), subject, textBody, htmlBody, headers)
+	// Prefer the header From so the display name survives; the envelope
+	// sender is only a fallback when the header is missing or unparsable.
+	if len(headerFrom) > 0 {
+		email.From = headerFrom[0]
+	}
 	email.Cc = cc
 	email.Bcc = bcc
 	email.ReplyTo = replyTo
+	email.NormalizeAddresses()
 	email.Attachments = attachments
+	email.Raw = raw
+	if hdr != nil {
+		applyControlHeaders(hdr, &email)
+	}
 	return email
 }
 
@@ -182,11 +431,12 @@ func parseMultipartBody(bodyData []byte, boundary string, attachments []domain.A
 
 		// Check if this is an attachment
 		if strings.HasPrefix(lowerDisp, "attachment") || (strings.HasPrefix(lowerDisp, "inline") && part.FileName() != "") {
-			filename := part.FileName()
+			filename := charset.DecodeHeader(part.FileName())
 			if filename == "" {
 				filename = "attachment"
 			}
-			attachments = append(attachments, domain.Attachment{Filename: filename, Content: slurp})
+			mediatype, _, _ := mime.ParseMediaType(pctype)
+			attachments = append(attachments, domain.Attachment{Filename: filename, Content: slurp, ContentType: mediatype})
 		} else {
 			// Parse content type to determine if this is text/plain, text/html, or nested multipart
 			mediatype, params, err := mime.ParseMediaType(pctype)
@@ -202,24 +452,12 @@ func parseMultipartBody(bodyData []byte, boundary string, attachments []domain.A
 					htmlBody = nestedHtml
 				}
 			} else if strings.HasPrefix(strings.ToLower(pctype), "text/plain") {
-				textBody = string(slurp)
+				textBody = charset.Decode(slurp, params["charset"])
 			} else if strings.HasPrefix(strings.ToLower(pctype), "text/html") {
-				htmlBody = string(slurp)
+				htmlBody = charset.Decode(slurp, params["charset"])
 			}
 		}
 	}
 
 	return textBody, htmlBody, attachments
 }
-
-func splitAddrs(s string) []string {
-	parts := strings.Split(s, ",")
-	out := make([]string, 0, len(parts))
-	for _, p := range parts {
-		p = strings.TrimSpace(p)
-		if p != "" {
-			out = append(out, p)
-		}
-	}
-	return out
-}
@@ -0,0 +1,457 @@
+package smtp
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-sasl"
+	goSMTP "github.com/emersion/go-smtp"
+	resendclient "github.com/igorrius/resend-railway-gateway/internal/adapters/resend"
+	"github.com/igorrius/resend-railway-gateway/internal/app"
+	"github.com/igorrius/resend-railway-gateway/internal/domain"
+	"github.com/igorrius/resend-railway-gateway/internal/proxyproto"
+	"github.com/igorrius/resend-railway-gateway/internal/ratelimit"
+	"github.com/igorrius/resend-railway-gateway/internal/suppression"
+)
+
+type recordingSender struct {
+	mu   sync.Mutex
+	sent []domain.Email
+}
+
+func (r *recordingSender) Send(email domain.Email) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sent = append(r.sent, email)
+	return "id", nil
+}
+
+func (r *recordingSender) emails() []domain.Email {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]domain.Email(nil), r.sent...)
+}
+
+// startServer runs an SMTP server on a random loopback port and returns its address.
+func startServer(t testing.TB, sender domain.OutboundEmailSender, opts ...Option) string {
+	t.Helper()
+	return startService(t, app.NewService(sender, nopLogger{}, time.Second), opts...)
+}
+
+// startService is like startServer for a preconfigured application service.
+func startService(t testing.TB, svc *app.Service, opts ...Option) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	return serve(t, l, svc, opts...)
+}
+
+// serve runs an SMTP server on l and returns the listener address.
+func serve(t testing.TB, l net.Listener, svc *app.Service, opts ...Option) string {
+	t.Helper()
+	s := NewServer(l.Addr().String(), svc, opts...)
+	go func() { _ = s.Serve(l) }()
+	t.Cleanup(func() { _ = s.Close() })
+	return l.Addr().String()
+}
+
+const testMessage = "From: sender@example.com\r\nTo: recipient@example.com\r\nSubject: Test\r\n\r\nHello\r\n"
+
+func TestServer_AuthenticatedUserOnEnvelope(t *testing.T) {
+	rec := &recordingSender{}
+	addr := startServer(t, rec, WithUsers(map[string]string{"alice": "secret"}))
+
+	c, err := goSMTP.Dial(addr)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer c.Close()
+	if err := c.Auth(sasl.NewPlainClient("", "alice", "secret")); err != nil {
+		t.Fatalf("auth: %v", err)
+	}
+	if err := c.SendMail("bounce@example.com", []string{"recipient@example.com"}, strings.NewReader(testMessage)); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	sent := rec.emails()
+	if len(sent) != 1 {
+		t.Fatalf("expected 1 email, got %d", len(sent))
+	}
+	if got := sent[0].Envelope; got.User != "alice" || got.MailFrom != "bounce@example.com" {
+		t.Errorf("unexpected envelope %+v", got)
+	}
+}
+
+func TestServer_AuthRejectsBadPassword(t *testing.T) {
+	addr := startServer(t, &recordingSender{}, WithUsers(map[string]string{"alice": "secret"}))
+
+	c, err := goSMTP.Dial(addr)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer c.Close()
+	if err := c.Auth(sasl.NewPlainClient("", "alice", "wrong")); err == nil {
+		t.Fatalf("expected authentication failure")
+	}
+}
+
+func TestServer_SetUsers(t *testing.T) {
+	var backend *Backend
+	addr := startServer(t, &recordingSender{}, WithUsers(map[string]string{"alice": "old"}), func(b *Backend) { backend = b })
+	backend.SetUsers(map[string]string{"alice": "new"})
+
+	for password, ok := range map[string]bool{"old": false, "new": true} {
+		c, err := goSMTP.Dial(addr)
+		if err != nil {
+			t.Fatalf("dial: %v", err)
+		}
+		if err := c.Auth(sasl.NewPlainClient("", "alice", password)); (err == nil) != ok {
+			t.Errorf("password %q: got %v", password, err)
+		}
+		c.Close()
+	}
+}
+
+func TestServer_ProxyProtocolClientAddress(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	trusted, _ := ParseNetworks([]string{"loopback"})
+	deny, _ := ParseNetworks([]string{"198.51.100.0/24"})
+	rec := &recordingSender{}
+	svc := app.NewService(rec, nopLogger{}, time.Second)
+	addr := serve(t, &proxyproto.Listener{Listener: inner, Trusted: trusted}, svc, WithConnPolicy(ConnPolicy{Deny: deny}))
+
+	dial := func(clientIP string) *goSMTP.Client {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatalf("dial: %v", err)
+		}
+		if _, err := conn.Write([]byte("PROXY TCP4 " + clientIP + " 127.0.0.1 40000 25\r\n")); err != nil {
+			t.Fatalf("write header: %v", err)
+		}
+		c := goSMTP.NewClient(conn)
+		t.Cleanup(func() { _ = c.Close() })
+		return c
+	}
+
+	if err := dial("203.0.113.5").SendMail("sender@example.com", []string{"recipient@example.com"}, strings.NewReader(testMessage)); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if sent := rec.emails(); len(sent) != 1 || sent[0].Envelope.RemoteAddr != "203.0.113.5:40000" {
+		t.Fatalf("expected real client address on envelope, got %+v", sent)
+	}
+	if err := dial("198.51.100.7").Hello("client.example.com"); err == nil {
+		t.Fatalf("expected policy to apply to the real client address")
+	}
+}
+
+func TestServer_RateLimits(t *testing.T) {
+	limiter := ratelimit.New([]ratelimit.Rule{{Scope: ratelimit.ScopeSenderDomain, Window: time.Hour, Limit: 1}})
+	addr := startServer(t, &recordingSender{}, WithRateLimits(limiter, ratelimit.NewConnLimiter(1)))
+
+	c, err := goSMTP.Dial(addr)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer c.Close()
+	if err := c.SendMail("sender@example.com", []string{"recipient@example.com"}, strings.NewReader(testMessage)); err != nil {
+		t.Fatalf("first message: %v", err)
+	}
+	err = c.SendMail("other@example.com", []string{"recipient@example.com"}, strings.NewReader(testMessage))
+	var serr *goSMTP.SMTPError
+	if !errors.As(err, &serr) || serr.Code != 451 || !strings.Contains(serr.Message, "seconds") {
+		t.Fatalf("expected 451 with retry hint, got %v", err)
+	}
+
+	second, err := goSMTP.Dial(addr)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer second.Close()
+	if err := second.Hello("client.example.com"); !errors.As(err, &serr) || serr.Code != 421 {
+		t.Fatalf("expected 421 for a second concurrent connection, got %v", err)
+	}
+}
+
+func TestServer_RateLimitsCountAcceptedMessages(t *testing.T) {
+	limiter := ratelimit.New([]ratelimit.Rule{{Scope: ratelimit.ScopeSenderDomain, Window: time.Hour, Limit: 1}})
+	svc := app.NewService(&recordingSender{}, nopLogger{}, time.Second,
+		app.WithRecipientPolicy(&app.RecipientPolicy{Action: app.SandboxReject, Domains: []string{"example.com"}}))
+	addr := startService(t, svc, WithRateLimits(limiter, nil))
+
+	c, err := goSMTP.Dial(addr)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer c.Close()
+	// A reset transaction and one whose recipient is refused use no quota.
+	if err := c.Mail("sender@example.com", nil); err != nil {
+		t.Fatalf("mail: %v", err)
+	}
+	if err := c.Reset(); err != nil {
+		t.Fatalf("rset: %v", err)
+	}
+	if err := c.SendMail("sender@example.com", []string{"customer@gmail.com"}, strings.NewReader(testMessage)); err == nil {
+		t.Fatal("expected the recipient to be refused")
+	}
+	c.Reset()
+	if err := c.SendMail("sender@example.com", []string{"recipient@example.com"}, strings.NewReader(testMessage)); err != nil {
+		t.Fatalf("first accepted message: %v", err)
+	}
+	var serr *goSMTP.SMTPError
+	if err := c.SendMail("sender@example.com", []string{"recipient@example.com"}, strings.NewReader(testMessage)); !errors.As(err, &serr) || serr.Code != 451 {
+		t.Fatalf("expected 451 once a message was accepted, got %v", err)
+	}
+}
+
+func TestServer_SenderNotAllowed(t *testing.T) {
+	svc := app.NewService(&recordingSender{}, nopLogger{}, time.Second,
+		app.WithSenderPolicy(&app.SenderPolicy{Allowed: []string{"example.com"}}))
+	addr := startService(t, svc)
+
+	c, err := goSMTP.Dial(addr)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer c.Close()
+	err = c.SendMail("spoofer@evil.test", []string{"recipient@example.com"}, strings.NewReader(testMessage))
+	var serr *goSMTP.SMTPError
+	if !errors.As(err, &serr) || serr.Code != 553 {
+		t.Fatalf("expected 553, got %v", err)
+	}
+}
+
+func TestServer_SandboxRejectsRecipient(t *testing.T) {
+	svc := app.NewService(&recordingSender{}, nopLogger{}, time.Second,
+		app.WithRecipientPolicy(&app.RecipientPolicy{Action: app.SandboxReject, Domains: []string{"example.com"}}))
+	addr := startService(t, svc)
+
+	c, err := goSMTP.Dial(addr)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer c.Close()
+	err = c.SendMail("sender@example.com", []string{"customer@gmail.com"}, strings.NewReader(testMessage))
+	var serr *goSMTP.SMTPError
+	if !errors.As(err, &serr) || serr.Code != 550 {
+		t.Fatalf("expected 550, got %v", err)
+	}
+}
+
+func TestServer_SuppressedRecipient(t *testing.T) {
+	list, _ := suppression.Open("", nil)
+	_ = list.Add(suppression.Entry{Address: "dead@example.com", Reason: suppression.ReasonBounce})
+	addr := startService(t, app.NewService(&recordingSender{}, nopLogger{}, time.Second, app.WithSuppressions(list)))
+
+	c, err := goSMTP.Dial(addr)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer c.Close()
+	err = c.SendMail("sender@example.com", []string{"dead@example.com"}, strings.NewReader(testMessage))
+	var serr *goSMTP.SMTPError
+	if !errors.As(err, &serr) || serr.Code != 550 || serr.EnhancedCode != (goSMTP.EnhancedCode{5, 1, 1}) {
+		t.Fatalf("expected 550 5.1.1, got %v", err)
+	}
+}
+
+func TestServer_DSNParametersOnEnvelope(t *testing.T) {
+	rec := &recordingSender{}
+	addr := startServer(t, rec)
+
+	c, err := goSMTP.Dial(addr)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("DSN"); !ok {
+		t.Fatal("DSN extension not advertised")
+	}
+	if err := c.Mail("sender@example.com", &goSMTP.MailOptions{Return: goSMTP.DSNReturnFull, EnvelopeID: "batch+42"}); err != nil {
+		t.Fatalf("mail: %v", err)
+	}
+	notify := &goSMTP.RcptOptions{
+		Notify:                []goSMTP.DSNNotify{goSMTP.DSNNotifySuccess, goSMTP.DSNNotifyFailure},
+		OriginalRecipientType: goSMTP.DSNAddressTypeRFC822,
+		OriginalRecipient:     "alias@example.com",
+	}
+	if err := c.Rcpt("recipient@example.com", notify); err != nil {
+		t.Fatalf("rcpt: %v", err)
+	}
+	if err := c.Rcpt("other@example.com", nil); err != nil {
+		t.Fatalf("rcpt: %v", err)
+	}
+	w, err := c.Data()
+	if err != nil {
+		t.Fatalf("data: %v", err)
+	}
+	w.Write([]byte(testMessage))
+	if err := w.Close(); err != nil {
+		t.Fatalf("data close: %v", err)
+	}
+
+	sent := rec.emails()
+	if len(sent) != 1 {
+		t.Fatalf("expected 1 email, got %d", len(sent))
+	}
+	env := sent[0].Envelope
+	if env.Return != domain.DSNReturnFull || env.EnvelopeID != "batch+42" {
+		t.Errorf("unexpected MAIL parameters %q %q", env.Return, env.EnvelopeID)
+	}
+	want := []domain.EnvelopeRecipient{
+		{Addr: "recipient@example.com", Notify: []domain.DSNNotify{domain.DSNNotifySuccess, domain.DSNNotifyFailure}, Original: "RFC822;alias@example.com"},
+		{Addr: "other@example.com"},
+	}
+	if !reflect.DeepEqual(env.Recipients, want) {
+		t.Errorf("recipients = %+v, want %+v", env.Recipients, want)
+	}
+}
+
+func TestServer_SMTPUTF8Envelope(t *testing.T) {
+	rec := &recordingSender{}
+	addr := startServer(t, rec)
+
+	c, err := goSMTP.Dial(addr)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer c.Close()
+	for _, ext := range []string{"SMTPUTF8", "8BITMIME"} {
+		if ok, _ := c.Extension(ext); !ok {
+			t.Errorf("%s not advertised", ext)
+		}
+	}
+	msg := "From: José <josé@bücher.de>\r\nTo: 用户@例子.广告\r\nSubject: Ünïcödé ✓\r\n\r\nHallo\r\n"
+	if err := c.SendMail("josé@bücher.de", []string{"用户@例子.广告"}, strings.NewReader(msg)); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	sent := rec.emails()
+	if len(sent) != 1 {
+		t.Fatalf("expected 1 email, got %d", len(sent))
+	}
+	got := sent[0]
+	if got.From.Addr != "josé@xn--bcher-kva.de" || got.To[0].Addr != "用户@xn--fsqu00a.xn--4rr70v" {
+		t.Errorf("addresses not normalized: from=%v to=%v", got.From, got.To)
+	}
+	if got.Subject != "Ünïcödé ✓" {
+		t.Errorf("unexpected subject %q", got.Subject)
+	}
+}
+
+func TestServer_BDATMaxMessageBytes(t *testing.T) {
+	rec := &recordingSender{}
+	addr := startServer(t, rec, WithMaxMessageBytes(64<<10))
+	c, err := dialLoad(addr)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer c.text.Close()
+
+	err = c.send(loadMessage(100<<10), true)
+	var terr *textproto.Error
+	if !errors.As(err, &terr) || terr.Code != 552 {
+		t.Fatalf("expected 552 for an oversized BDAT message, got %v", err)
+	}
+	if len(rec.emails()) != 0 {
+		t.Error("oversized message was delivered")
+	}
+}
+
+// resendStub serves the Resend send API, refusing emails to rejected with
+// the validation error the API returns for an invalid address.
+func resendStub(t *testing.T, rejected string) *url.URL {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		if strings.Contains(string(body), rejected) {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			w.Write([]byte(`{"statusCode":422,"name":"validation_error","message":"The email address ` + rejected + ` is invalid."}`))
+			return
+		}
+		w.Write([]byte(`{"id":"re_123"}`))
+	}))
+	t.Cleanup(srv.Close)
+	base, _ := url.Parse(srv.URL + "/")
+	return base
+}
+
+// lmtpSend sends a message to rcpts over the LMTP socket and returns the
+// per-recipient result of DATA.
+func lmtpSend(t *testing.T, sock string, rcpts ...string) error {
+	t.Helper()
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	c := goSMTP.NewClientLMTP(conn)
+	defer c.Close()
+	if err := c.Hello("localhost"); err != nil {
+		t.Fatalf("lhlo: %v", err)
+	}
+	if err := c.Mail("sender@example.com", nil); err != nil {
+		t.Fatalf("mail: %v", err)
+	}
+	for _, rcpt := range rcpts {
+		if err := c.Rcpt(rcpt, nil); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		t.Fatalf("data: %v", err)
+	}
+	w.Write([]byte("From: sender@example.com\r\nTo: " + strings.Join(rcpts, ", ") + "\r\nSubject: Test\r\n\r\nHello\r\n"))
+	_, err = w.CloseWithLMTPResponse()
+	return err
+}
+
+func TestLMTPServer_PerRecipientStatus(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "lmtp.sock")
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	list, _ := suppression.Open("", nil)
+	router := resendclient.NewRouter(nil, "re_test", resendclient.WithBaseURL(resendStub(t, "gone@example.com")))
+	s := NewLMTPServer(sock, app.NewService(router, nopLogger{}, time.Second, app.WithSuppressions(list)))
+	go func() { _ = s.Serve(l) }()
+	t.Cleanup(func() { _ = s.Close() })
+
+	err = lmtpSend(t, sock, "gone@example.com", "other@example.com")
+	var lerr goSMTP.LMTPDataError
+	if !errors.As(err, &lerr) {
+		t.Fatalf("expected per-recipient errors, got %v", err)
+	}
+	if got := lerr["gone@example.com"]; got == nil || got.Code != 550 {
+		t.Errorf("gone@example.com: expected 550, got %v", got)
+	}
+	if got := lerr["other@example.com"]; got == nil || got.Code != 450 {
+		t.Errorf("other@example.com: expected 450, got %v", got)
+	}
+
+	// The client retries the others, and the rejected address is now suppressed.
+	if err := lmtpSend(t, sock, "other@example.com"); err != nil {
+		t.Errorf("retry: %v", err)
+	}
+	var serr *goSMTP.SMTPError
+	if err := lmtpSend(t, sock, "gone@example.com"); !errors.As(err, &serr) || serr.Code != 550 {
+		t.Errorf("expected the rejected recipient to be suppressed, got %v", err)
+	}
+}
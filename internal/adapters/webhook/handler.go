@@ -0,0 +1,171 @@
+// Package webhook receives Resend webhook events and exposes the resulting
+// delivery status of relayed emails.
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/igorrius/resend-railway-gateway/internal/delivery"
+	"github.com/igorrius/resend-railway-gateway/internal/domain"
+)
+
+// maxBodyBytes bounds webhook payloads; Resend events are a few kilobytes.
+const maxBodyBytes = 1 << 20
+
+// Svix redelivers a message with the same svix-id when it did not see the
+// acknowledgement, for up to about a day. The IDs of handled deliveries are
+// kept for seenTTL, at most maxSeen of them, so that a redelivery does not
+// run the listeners, and send notifications, again.
+const (
+	seenTTL = 24 * time.Hour
+	maxSeen = 10000
+)
+
+// payload is the JSON body of a Resend webhook.
+type payload struct {
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      struct {
+		EmailID string   `json:"email_id"`
+		To      []string `json:"to"`
+		Bounce  *struct {
+			Message string `json:"message"`
+			Type    string `json:"type"`
+			SubType string `json:"subType"`
+		} `json:"bounce"`
+		Failed *struct {
+			Reason string `json:"reason"`
+		} `json:"failed"`
+	} `json:"data"`
+}
+
+// Handler serves:
+//
+//	POST /webhooks/resend   Resend webhook deliveries (Svix signed)
+//	GET  /api/status/{id}   delivery status of an email by provider ID
+//
+// The status API is not authenticated by the Handler; mount it behind an
+// authorizing handler.
+type Handler struct {
+	verifier  *Verifier
+	tracker   *delivery.Tracker
+	logger    domain.MessageLogger
+	listeners []Listener
+	mux       *http.ServeMux
+	now       func() time.Time
+
+	mu       sync.Mutex
+	seen     map[string]time.Time // svix-id to the time it was handled
+	seenList []string             // seen in the order handled
+}
+
+// Listener is notified of every verified email event after it was recorded.
+type Listener func(ev delivery.Event, rec delivery.Record)
+
+// Option configures optional Handler behaviour.
+type Option func(*Handler)
+
+// WithListener registers a function called for every verified email event.
+func WithListener(l Listener) Option {
+	return func(h *Handler) { h.listeners = append(h.listeners, l) }
+}
+
+// NewHandler creates a Handler recording events in tracker.
+func NewHandler(verifier *Verifier, tracker *delivery.Tracker, logger domain.MessageLogger, opts ...Option) *Handler {
+	h := &Handler{verifier: verifier, tracker: tracker, logger: logger, mux: http.NewServeMux(), now: time.Now, seen: map[string]time.Time{}}
+	for _, opt := range opts {
+		opt(h)
+	}
+	h.mux.HandleFunc("POST /webhooks/resend", h.receive)
+	h.mux.HandleFunc("GET /api/status/{id}", h.status)
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) { h.mux.ServeHTTP(w, r) }
+
+func (h *Handler) receive(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	if err != nil {
+		http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err := h.verifier.Verify(r.Header, body); err != nil {
+		h.logger.Info("webhook_rejected", map[string]any{"error": err, "client": r.RemoteAddr})
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+	var p payload
+	if err := json.Unmarshal(body, &p); err != nil || p.Type == "" {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+	if id := r.Header.Get("svix-id"); !h.firstDelivery(id) {
+		h.logger.Info("webhook_duplicate", map[string]any{"svix_id": id, "id": p.Data.EmailID, "type": p.Type})
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if p.Data.EmailID == "" {
+		// Not an email event (e.g. contact or domain updates); acknowledge it.
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	ev := delivery.Event{Type: p.Type, EmailID: p.Data.EmailID, CreatedAt: p.CreatedAt, To: p.Data.To}
+	if b := p.Data.Bounce; b != nil {
+		ev.Detail, ev.BounceType = b.Message, b.Type
+	}
+	if f := p.Data.Failed; f != nil {
+		ev.Detail = f.Reason
+	}
+	rec, known := h.tracker.Apply(ev)
+
+	fields := map[string]any{"id": ev.EmailID, "type": ev.Type, "to": ev.To, "status": rec.Status, "known": known}
+	if ev.Detail != "" {
+		fields["detail"] = ev.Detail
+	}
+	switch ev.Status() {
+	case delivery.StatusBounced, delivery.StatusComplained, delivery.StatusFailed:
+		h.logger.Error("webhook_event", fields)
+	default:
+		h.logger.Info("webhook_event", fields)
+	}
+	for _, l := range h.listeners {
+		l(ev, rec)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// firstDelivery records the delivery id and reports whether it was not seen
+// within seenTTL.
+func (h *Handler) firstDelivery(id string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := h.now()
+	for len(h.seenList) > 0 {
+		oldest := h.seenList[0]
+		if len(h.seenList) < maxSeen && now.Sub(h.seen[oldest]) < seenTTL {
+			break
+		}
+		delete(h.seen, oldest)
+		h.seenList = h.seenList[1:]
+	}
+	if _, ok := h.seen[id]; ok {
+		return false
+	}
+	h.seen[id] = now
+	h.seenList = append(h.seenList, id)
+	return true
+}
+
+func (h *Handler) status(w http.ResponseWriter, r *http.Request) {
+	rec, ok := h.tracker.Get(r.PathValue("id"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(rec)
+}
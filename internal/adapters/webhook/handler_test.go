@@ -0,0 +1,109 @@
+package webhook
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/igorrius/resend-railway-gateway/internal/delivery"
+	"github.com/igorrius/resend-railway-gateway/internal/domain"
+)
+
+type recordingLogger struct{ msgs []string }
+
+func (l *recordingLogger) Info(msg string, _ map[string]any)  { l.msgs = append(l.msgs, msg) }
+func (l *recordingLogger) Error(msg string, _ map[string]any) { l.msgs = append(l.msgs, msg) }
+
+func signedRequest(t *testing.T, v *Verifier, body string) *http.Request {
+	t.Helper()
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	sig := base64.StdEncoding.EncodeToString(v.sign("msg_1", ts, []byte(body)))
+	req := httptest.NewRequest(http.MethodPost, "/webhooks/resend", strings.NewReader(body))
+	req.Header = svixHeaders("msg_1", ts, "v1,"+sig)
+	return req
+}
+
+func TestHandler_BounceUpdatesStatus(t *testing.T) {
+	v, _ := NewVerifier(testSecret)
+	tracker := delivery.NewTracker(0)
+	tracker.Sent("em_1", domain.Email{From: domain.Address{Addr: "a@example.com"}, To: []domain.Address{{Addr: "b@example.com"}}, Subject: "Hi"})
+	log := &recordingLogger{}
+	h := NewHandler(v, tracker, log)
+
+	body := `{"type":"email.bounced","created_at":"2030-01-01T00:00:00Z","data":{"email_id":"em_1","to":["b@example.com"],
+		"bounce":{"message":"mailbox does not exist","type":"Permanent","subType":"General"}}}`
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, signedRequest(t, v, body))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/status/em_1", nil))
+	var got delivery.Record
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != delivery.StatusBounced || got.Subject != "Hi" || len(got.Events) != 1 || got.Events[0].BounceType != "Permanent" {
+		t.Errorf("unexpected record %+v", got)
+	}
+	if len(log.msgs) != 1 || log.msgs[0] != "webhook_event" {
+		t.Errorf("unexpected log lines %v", log.msgs)
+	}
+}
+
+func TestHandler_RejectsBadSignature(t *testing.T) {
+	v, _ := NewVerifier(testSecret)
+	tracker := delivery.NewTracker(0)
+	h := NewHandler(v, tracker, &recordingLogger{})
+
+	req := signedRequest(t, v, `{"type":"email.delivered","data":{"email_id":"em_1"}}`)
+	req.Header.Set("svix-signature", testSignature)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401, got %d", rec.Code)
+	}
+	if _, ok := tracker.Get("em_1"); ok {
+		t.Error("unverified event must not be recorded")
+	}
+}
+
+func TestHandler_UnknownStatus(t *testing.T) {
+	v, _ := NewVerifier(testSecret)
+	h := NewHandler(v, delivery.NewTracker(0), &recordingLogger{})
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/status/nope", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", rec.Code)
+	}
+}
+
+func TestHandler_IgnoresRedeliveries(t *testing.T) {
+	v, _ := NewVerifier(testSecret)
+	calls := 0
+	h := NewHandler(v, delivery.NewTracker(0), &recordingLogger{}, WithListener(func(delivery.Event, delivery.Record) { calls++ }))
+	now := time.Now()
+	h.now = func() time.Time { return now }
+
+	body := `{"type":"email.bounced","data":{"email_id":"em_1","to":["b@example.com"]}}`
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		if h.ServeHTTP(rec, signedRequest(t, v, body)); rec.Code != http.StatusNoContent {
+			t.Fatalf("delivery %d: %d", i, rec.Code)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("listener called %d times for a redelivered message", calls)
+	}
+	now = now.Add(seenTTL)
+	h.ServeHTTP(httptest.NewRecorder(), signedRequest(t, v, body))
+	if calls != 2 {
+		t.Errorf("a delivery ID should be forgotten after seenTTL, listener called %d times", calls)
+	}
+}
@@ -0,0 +1,82 @@
+package webhook
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// Test vector from the Svix documentation.
+const (
+	testSecret    = "whsec_MfKQ9r8GKYqrTwjUPD8ILPZIo2LaLaSw"
+	testID        = "msg_p5jXN8AQM9LWM0D4loKWxJek"
+	testTimestamp = "1614265330"
+	testBody      = `{"test": 2432232314}`
+	testSignature = "v1,g0hM9SsE+OTPJTGt/tmIKtSyZlE3uFJELVlNIOLJ1OE="
+)
+
+func svixHeaders(id, ts, sig string) http.Header {
+	h := http.Header{}
+	h.Set("svix-id", id)
+	h.Set("svix-timestamp", ts)
+	h.Set("svix-signature", sig)
+	return h
+}
+
+func TestVerifier(t *testing.T) {
+	v, err := NewVerifier(testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.now = func() time.Time { return time.Unix(1614265330, 0).Add(time.Minute) }
+
+	cases := []struct {
+		name string
+		h    http.Header
+		body string
+		want error
+	}{
+		{"valid", svixHeaders(testID, testTimestamp, testSignature), testBody, nil},
+		{"one of several", svixHeaders(testID, testTimestamp, "v1,Zm9v "+testSignature), testBody, nil},
+		{"tampered body", svixHeaders(testID, testTimestamp, testSignature), `{"test": 1}`, ErrInvalidSignature},
+		{"other id", svixHeaders("msg_other", testTimestamp, testSignature), testBody, ErrInvalidSignature},
+		{"unknown version", svixHeaders(testID, testTimestamp, "v2,"+testSignature[3:]), testBody, ErrInvalidSignature},
+		{"old timestamp", svixHeaders(testID, "1614260000", testSignature), testBody, ErrTimestamp},
+		{"missing headers", http.Header{}, testBody, ErrMissingHeaders},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if err := v.Verify(c.h, []byte(c.body)); !errors.Is(err, c.want) {
+				t.Errorf("expected %v, got %v", c.want, err)
+			}
+		})
+	}
+}
+
+func TestVerifier_SetSecret(t *testing.T) {
+	v, err := NewVerifier("whsec_b2xk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.now = func() time.Time { return time.Unix(1614265330, 0) }
+	h := svixHeaders(testID, testTimestamp, testSignature)
+	if err := v.Verify(h, []byte(testBody)); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected the old secret to fail, got %v", err)
+	}
+	if err := v.SetSecret("whsec_not base64!"); err == nil {
+		t.Error("expected an error for a malformed secret")
+	}
+	if err := v.SetSecret(testSecret); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Verify(h, []byte(testBody)); err != nil {
+		t.Errorf("rotated secret: %v", err)
+	}
+}
+
+func TestNewVerifier_InvalidSecret(t *testing.T) {
+	if _, err := NewVerifier("whsec_not base64!"); err == nil {
+		t.Error("expected an error for a malformed secret")
+	}
+}
@@ -0,0 +1,94 @@
+package app
+
+import (
+	"time"
+
+	"github.com/igorrius/resend-railway-gateway/internal/delivery"
+	"github.com/igorrius/resend-railway-gateway/internal/domain"
+	"github.com/igorrius/resend-railway-gateway/internal/dsn"
+)
+
+// Bouncer sends delivery status notifications to the envelope sender when the
+// provider reports the outcome of a message the gateway already accepted.
+// Notifications go through Service.HandleEmail, so sandbox, suppression and
+// sender policies apply to them like to any other email.
+type Bouncer struct {
+	service      *Service
+	logger       domain.MessageLogger
+	from         domain.Address
+	reportingMTA string
+	now          func() time.Time
+}
+
+// NewBouncer creates a Bouncer sending notifications from `from`.
+// reportingMTA identifies the gateway in the machine readable report.
+func NewBouncer(service *Service, logger domain.MessageLogger, from domain.Address, reportingMTA string) *Bouncer {
+	return &Bouncer{service: service, logger: logger, from: from, reportingMTA: reportingMTA, now: time.Now}
+}
+
+// Notify reports ev to the envelope sender of rec when the recipients asked
+// for it: failures by default, successes and delays only when requested with
+// the NOTIFY parameter. It has the signature of a webhook listener.
+func (b *Bouncer) Notify(ev delivery.Event, rec delivery.Record) {
+	var (
+		action dsn.Action
+		notify domain.DSNNotify
+		status string
+	)
+	switch ev.Status() {
+	case delivery.StatusBounced, delivery.StatusFailed:
+		action, notify, status = dsn.ActionFailed, domain.DSNNotifyFailure, dsn.StatusFromDiagnostic(ev.Detail, "5.0.0")
+	case delivery.StatusDeliveryDelayed:
+		action, notify, status = dsn.ActionDelayed, domain.DSNNotifyDelay, dsn.StatusFromDiagnostic(ev.Detail, "4.0.0")
+	case delivery.StatusDelivered:
+		action, notify, status = dsn.ActionDelivered, domain.DSNNotifySuccess, "2.0.0"
+	default:
+		return
+	}
+	if !dsn.ShouldReport(rec.MailFrom, rec.Header) {
+		return
+	}
+	to := ev.To
+	if len(to) == 0 {
+		to = rec.To
+	}
+	report := dsn.Report{
+		ReportingMTA: b.reportingMTA,
+		EnvelopeID:   rec.Envelope.EnvelopeID,
+		Arrival:      rec.SentAt,
+		Original:     rec.Header,
+		HeadersOnly:  true,
+		Subject:      rec.Subject,
+	}
+	if rec.Envelope.Return == domain.DSNReturnFull && len(rec.Message) > 0 {
+		report.Original, report.HeadersOnly = rec.Message, false
+	}
+	for _, addr := range to {
+		rcpt := rec.Envelope.Recipient(addr)
+		if !rcpt.Wants(notify) {
+			continue
+		}
+		report.Recipients = append(report.Recipients, dsn.Recipient{
+			Final:      addr,
+			Original:   rcpt.Original,
+			Action:     action,
+			Status:     status,
+			Diagnostic: ev.Detail,
+		})
+	}
+	if len(report.Recipients) == 0 {
+		return
+	}
+	b.send(rec, report)
+}
+
+func (b *Bouncer) send(rec delivery.Record, report dsn.Report) {
+	email := dsn.Compose(report, b.from, rec.MailFrom, b.now())
+	fields := map[string]any{"id": rec.ID, "to": rec.MailFrom}
+	if err := b.service.HandleEmail(email); err != nil {
+		fields["error"] = err
+		b.logger.Error("dsn_failed", fields)
+		return
+	}
+	b.logger.Info("dsn_sent", fields)
+}
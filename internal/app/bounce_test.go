@@ -0,0 +1,106 @@
+package app
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/igorrius/resend-railway-gateway/internal/delivery"
+	"github.com/igorrius/resend-railway-gateway/internal/domain"
+)
+
+func TestBouncer_NotifiesEnvelopeSender(t *testing.T) {
+	rec := &recordingSender{}
+	b := NewBouncer(NewService(rec, nopLogger{}, time.Second), nopLogger{}, domain.Address{Addr: "mailer-daemon@example.com"}, "gw.example.com")
+
+	original := delivery.Record{
+		ID:       "em_1",
+		To:       []string{"dead@example.org"},
+		Subject:  "Invoice",
+		MailFrom: "app@example.com",
+		Header:   []byte("From: app@example.com\r\nSubject: Invoice\r\n\r\n"),
+	}
+	b.Notify(delivery.Event{Type: "email.delivered", EmailID: "em_1"}, original)
+	if len(rec.sent) != 0 {
+		t.Fatalf("no DSN expected for a delivery, got %d", len(rec.sent))
+	}
+
+	b.Notify(delivery.Event{Type: "email.bounced", EmailID: "em_1", Detail: "550 5.1.1 user unknown", BounceType: "Permanent"}, original)
+	if len(rec.sent) != 1 {
+		t.Fatalf("expected one DSN, got %d", len(rec.sent))
+	}
+	got := rec.sent[0]
+	if got.To[0].Addr != "app@example.com" || got.Envelope.MailFrom != "" {
+		t.Errorf("unexpected DSN envelope to=%v mail_from=%q", got.To, got.Envelope.MailFrom)
+	}
+	if !strings.Contains(string(got.Raw), "Status: 5.1.1") || !strings.Contains(string(got.Raw), "Subject: Invoice") {
+		t.Errorf("DSN lacks status or original headers:\n%s", got.Raw)
+	}
+
+	original.MailFrom = ""
+	b.Notify(delivery.Event{Type: "email.bounced", EmailID: "em_1"}, original)
+	if len(rec.sent) != 1 {
+		t.Error("no DSN may be sent for a null sender")
+	}
+}
+
+func TestBouncer_HonorsDSNParameters(t *testing.T) {
+	rec := &recordingSender{}
+	b := NewBouncer(NewService(rec, nopLogger{}, time.Second), nopLogger{}, domain.Address{Addr: "mailer-daemon@example.com"}, "gw.example.com")
+
+	original := delivery.Record{
+		ID:       "em_1",
+		To:       []string{"a@example.org", "b@example.org", "c@example.org"},
+		MailFrom: "app@example.com",
+		Header:   []byte("From: app@example.com\r\nSubject: Invoice\r\n\r\n"),
+		Message:  []byte("From: app@example.com\r\nSubject: Invoice\r\n\r\nfull body\r\n"),
+		Envelope: domain.Envelope{
+			MailFrom:   "app@example.com",
+			Return:     domain.DSNReturnFull,
+			EnvelopeID: "batch-42",
+			Recipients: []domain.EnvelopeRecipient{
+				{Addr: "a@example.org", Notify: []domain.DSNNotify{domain.DSNNotifySuccess, domain.DSNNotifyDelay}, Original: "rfc822;alias@example.org"},
+				{Addr: "b@example.org", Notify: []domain.DSNNotify{domain.DSNNotifyNever}},
+			},
+		},
+	}
+
+	b.Notify(delivery.Event{Type: "email.delivered", EmailID: "em_1"}, original)
+	if len(rec.sent) != 1 {
+		t.Fatalf("expected a success DSN for NOTIFY=SUCCESS, got %d", len(rec.sent))
+	}
+	raw := string(rec.sent[0].Raw)
+	for _, want := range []string{
+		"Original-Envelope-Id: batch-42",
+		"Original-Recipient: rfc822; alias@example.org",
+		"Final-Recipient: rfc822; a@example.org",
+		"Action: delivered",
+		"Content-Type: message/rfc822",
+		"full body",
+	} {
+		if !strings.Contains(raw, want) {
+			t.Errorf("success DSN lacks %q:\n%s", want, raw)
+		}
+	}
+	if strings.Contains(raw, "b@example.org") || strings.Contains(raw, "c@example.org") {
+		t.Errorf("success DSN reports recipients that did not ask for it:\n%s", raw)
+	}
+
+	b.Notify(delivery.Event{Type: "email.delivery_delayed", EmailID: "em_1", To: []string{"a@example.org"}}, original)
+	if len(rec.sent) != 2 || !strings.Contains(string(rec.sent[1].Raw), "Status: 4.0.0") {
+		t.Fatalf("expected a delay DSN for NOTIFY=DELAY, got %d", len(rec.sent))
+	}
+
+	b.Notify(delivery.Event{Type: "email.bounced", EmailID: "em_1", To: []string{"b@example.org"}}, original)
+	if len(rec.sent) != 2 {
+		t.Fatal("no DSN may be sent for NOTIFY=NEVER")
+	}
+	b.Notify(delivery.Event{Type: "email.delivery_delayed", EmailID: "em_1", To: []string{"c@example.org"}}, original)
+	if len(rec.sent) != 2 {
+		t.Fatal("delays are not reported without NOTIFY=DELAY")
+	}
+	b.Notify(delivery.Event{Type: "email.bounced", EmailID: "em_1", To: []string{"c@example.org"}}, original)
+	if len(rec.sent) != 3 {
+		t.Fatal("failures are reported without a NOTIFY parameter")
+	}
+}
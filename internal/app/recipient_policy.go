@@ -0,0 +1,140 @@
+package app
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/igorrius/resend-railway-gateway/internal/domain"
+)
+
+// SandboxAction selects what happens to recipients outside the sandbox allowlist.
+type SandboxAction string
+
+const (
+	// SandboxReject refuses disallowed recipients (550 at RCPT TO).
+	SandboxReject SandboxAction = "reject"
+	// SandboxDrop silently removes disallowed recipients from the email.
+	SandboxDrop SandboxAction = "drop"
+	// SandboxRedirect replaces disallowed recipients with a catch-all address.
+	SandboxRedirect SandboxAction = "redirect"
+)
+
+// HeaderOriginalRecipients records the recipients replaced by SandboxRedirect.
+const HeaderOriginalRecipients = "X-Sandbox-Original-Recipients"
+
+// RecipientPolicy implements sandbox mode for staging environments: only
+// recipients matching Domains, Addresses or Patterns receive mail. A policy
+// without an Action is disabled and allows every recipient.
+type RecipientPolicy struct {
+	Action    SandboxAction
+	Domains   []string
+	Addresses []string
+	// Patterns are matched against the lower-cased address.
+	Patterns []*regexp.Regexp
+	// CatchAll receives redirected mail when Action is SandboxRedirect.
+	CatchAll domain.Address
+}
+
+func (p *RecipientPolicy) enabled() bool {
+	return p != nil && p.Action != ""
+}
+
+// Allows reports whether addr may receive mail.
+func (p *RecipientPolicy) Allows(addr string) bool {
+	if !p.enabled() {
+		return true
+	}
+	addr = strings.ToLower(domain.NormalizeAddr(addr))
+	for _, a := range p.Addresses {
+		if strings.ToLower(domain.NormalizeAddr(a)) == addr {
+			return true
+		}
+	}
+	if at := strings.LastIndexByte(addr, '@'); at >= 0 {
+		for _, d := range p.Domains {
+			if strings.ToLower(domain.NormalizeDomain(d)) == addr[at+1:] {
+				return true
+			}
+		}
+	}
+	for _, re := range p.Patterns {
+		if re.MatchString(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckRecipient validates an envelope recipient (SMTP RCPT TO). Only the
+// reject action refuses recipients up front; the others act in Apply.
+func (p *RecipientPolicy) CheckRecipient(addr string) error {
+	if p.enabled() && p.Action == SandboxReject && !p.Allows(addr) {
+		return fmt.Errorf("%w: %s", domain.ErrRecipientNotAllowed, addr)
+	}
+	return nil
+}
+
+// Apply enforces the policy on every recipient list of email. It reports
+// deliver=false when all recipients were dropped and nothing should be sent.
+func (p *RecipientPolicy) Apply(email *domain.Email) (deliver bool, err error) {
+	if !p.enabled() {
+		return true, nil
+	}
+	var removed []domain.Address
+	for _, list := range []*[]domain.Address{&email.To, &email.Cc, &email.Bcc} {
+		var kept []domain.Address
+		for _, a := range *list {
+			if p.Allows(a.Addr) {
+				kept = append(kept, a)
+				continue
+			}
+			if p.Action == SandboxReject {
+				return false, fmt.Errorf("%w: %s", domain.ErrRecipientNotAllowed, a.Addr)
+			}
+			removed = append(removed, a)
+		}
+		*list = kept
+	}
+	if len(removed) == 0 {
+		return true, nil
+	}
+	if p.Action == SandboxRedirect {
+		if !containsAddr(email.To, p.CatchAll.Addr) {
+			email.To = append(email.To, p.CatchAll)
+		}
+		if email.Headers == nil {
+			email.Headers = map[string]string{}
+		}
+		email.Headers[HeaderOriginalRecipients] = strings.Join(domain.AddressStrings(removed), ", ")
+		return true, nil
+	}
+	ensureTo(email, p.CatchAll)
+	return len(email.To) > 0, nil
+}
+
+// ensureTo gives email the To recipient the provider requires after
+// recipients were removed from it. Cc recipients, who were visible to every
+// recipient anyway, are promoted. Bcc recipients never are, since that would
+// disclose them: they go to catchAll instead when it is set, and are dropped
+// otherwise.
+func ensureTo(email *domain.Email, catchAll domain.Address) {
+	switch {
+	case len(email.To) > 0:
+	case len(email.Cc) > 0:
+		email.To, email.Cc = email.Cc, nil
+	case len(email.Bcc) > 0 && catchAll.Addr != "":
+		email.To = []domain.Address{catchAll}
+	default:
+		email.Bcc = nil
+	}
+}
+
+func containsAddr(list []domain.Address, addr string) bool {
+	for _, a := range list {
+		if strings.EqualFold(a.Addr, addr) {
+			return true
+		}
+	}
+	return false
+}
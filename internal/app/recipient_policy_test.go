@@ -0,0 +1,103 @@
+package app
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/igorrius/resend-railway-gateway/internal/domain"
+)
+
+func sandbox(action SandboxAction) *RecipientPolicy {
+	return &RecipientPolicy{
+		Action:    action,
+		Domains:   []string{"example.com"},
+		Addresses: []string{"qa@partner.test"},
+		Patterns:  []*regexp.Regexp{regexp.MustCompile(`^test\+.*@gmail\.com$`)},
+		CatchAll:  domain.Address{Addr: "catchall@example.com"},
+	}
+}
+
+func TestRecipientPolicy_Allows(t *testing.T) {
+	p := sandbox(SandboxReject)
+	for addr, want := range map[string]bool{
+		"dev@Example.com":     true,
+		"QA@partner.test":     true,
+		"test+123@gmail.com":  true,
+		"customer@gmail.com":  false,
+		"dev@sub.example.com": false,
+	} {
+		if got := p.Allows(addr); got != want {
+			t.Errorf("Allows(%q) = %v, want %v", addr, got, want)
+		}
+	}
+	if err := p.CheckRecipient("customer@gmail.com"); !errors.Is(err, domain.ErrRecipientNotAllowed) {
+		t.Errorf("expected ErrRecipientNotAllowed, got %v", err)
+	}
+	if err := sandbox(SandboxDrop).CheckRecipient("customer@gmail.com"); err != nil {
+		t.Errorf("drop mode must accept recipients at RCPT time, got %v", err)
+	}
+}
+
+func TestHandleEmail_SandboxDrop(t *testing.T) {
+	rec := &recordingSender{}
+	svc := NewService(rec, nopLogger{}, time.Second, WithRecipientPolicy(sandbox(SandboxDrop)))
+
+	email, _ := domain.NewEmail("a@example.com", []string{"customer@gmail.com", "dev@example.com"}, "hi", "text", "", nil)
+	if err := svc.HandleEmail(email); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	if to := rec.sent[0].To; len(to) != 1 || to[0].Addr != "dev@example.com" {
+		t.Errorf("expected only the allowed recipient, got %v", to)
+	}
+
+	email, _ = domain.NewEmail("a@example.com", []string{"customer@gmail.com"}, "hi", "text", "", nil)
+	if err := svc.HandleEmail(email); err != nil {
+		t.Fatalf("expected silent drop, got %v", err)
+	}
+	if len(rec.sent) != 1 {
+		t.Errorf("email without allowed recipients must not be sent")
+	}
+}
+
+func TestHandleEmail_SandboxDropKeepsBccHidden(t *testing.T) {
+	rec := &recordingSender{}
+	policy := sandbox(SandboxDrop)
+	svc := NewService(rec, nopLogger{}, time.Second, WithRecipientPolicy(policy))
+
+	email, _ := domain.NewEmail("a@example.com", []string{"customer@gmail.com"}, "hi", "text", "", nil)
+	email.Bcc = []domain.Address{{Addr: "audit@example.com"}}
+	if err := svc.HandleEmail(email); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if got := rec.sent[0]; len(got.To) != 1 || got.To[0].Addr != "catchall@example.com" || len(got.Bcc) != 1 {
+		t.Errorf("expected the catch-all in To and the Bcc kept, got to=%v bcc=%v", got.To, got.Bcc)
+	}
+
+	policy.CatchAll = domain.Address{}
+	if err := svc.HandleEmail(email); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(rec.sent) != 1 {
+		t.Errorf("a Bcc recipient must not be moved to To, got to=%v", rec.sent[len(rec.sent)-1].To)
+	}
+}
+
+func TestHandleEmail_SandboxRedirect(t *testing.T) {
+	rec := &recordingSender{}
+	svc := NewService(rec, nopLogger{}, time.Second, WithRecipientPolicy(sandbox(SandboxRedirect)))
+
+	email, _ := domain.NewEmail("a@example.com", []string{"customer@gmail.com", "dev@example.com"}, "hi", "text", "", nil)
+	email.Cc = []domain.Address{{Addr: "boss@corp.test"}}
+	if err := svc.HandleEmail(email); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	got := rec.sent[0]
+	if len(got.To) != 2 || got.To[1].Addr != "catchall@example.com" || len(got.Cc) != 0 {
+		t.Errorf("expected allowed recipient plus catch-all, got to=%v cc=%v", got.To, got.Cc)
+	}
+	if h := got.Headers[HeaderOriginalRecipients]; h != "customer@gmail.com, boss@corp.test" {
+		t.Errorf("expected original recipients header, got %q", h)
+	}
+}
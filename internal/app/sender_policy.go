@@ -0,0 +1,80 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/igorrius/resend-railway-gateway/internal/domain"
+)
+
+// SenderPolicy restricts the addresses clients may send as. Entries are
+// either full addresses ("noreply@example.com") or domains ("example.com"),
+// compared case-insensitively and with internationalized domains in ASCII
+// form. A policy without entries allows every sender.
+type SenderPolicy struct {
+	// Allowed applies to every client, including unauthenticated ones.
+	Allowed []string
+	// PerUser replaces Allowed for the listed authenticated users.
+	PerUser map[string][]string
+	// RewriteTo, when set, replaces a disallowed header From instead of
+	// rejecting the email; the original From is moved to Reply-To.
+	RewriteTo domain.Address
+}
+
+func (p *SenderPolicy) enabled() bool {
+	return p != nil && (len(p.Allowed) > 0 || len(p.PerUser) > 0)
+}
+
+// Allows reports whether user may send as addr.
+func (p *SenderPolicy) Allows(user, addr string) bool {
+	if !p.enabled() {
+		return true
+	}
+	entries, ok := p.PerUser[user]
+	if !ok || user == "" {
+		entries = p.Allowed
+	}
+	addr = strings.ToLower(domain.NormalizeAddr(addr))
+	at := strings.LastIndexByte(addr, '@')
+	for _, e := range entries {
+		e = strings.ToLower(domain.NormalizeAddr(e))
+		if strings.Contains(e, "@") {
+			if e == addr {
+				return true
+			}
+		} else if at >= 0 && addr[at+1:] == e {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckEnvelope validates the envelope sender (SMTP MAIL FROM).
+// The null sender used for bounces is always accepted.
+func (p *SenderPolicy) CheckEnvelope(user, mailFrom string) error {
+	if mailFrom == "" || p.Allows(user, mailFrom) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", domain.ErrSenderNotAllowed, mailFrom)
+}
+
+// Apply validates the header From address of email. A disallowed address is
+// rewritten to RewriteTo when configured, keeping the original display name
+// and moving the original address to Reply-To unless one is already set.
+func (p *SenderPolicy) Apply(email *domain.Email) error {
+	if p.Allows(email.Envelope.User, email.From.Addr) {
+		return nil
+	}
+	if p.RewriteTo.Addr == "" {
+		return fmt.Errorf("%w: %s", domain.ErrSenderNotAllowed, email.From.Addr)
+	}
+	original := email.From
+	email.From = p.RewriteTo
+	if email.From.Name == "" {
+		email.From.Name = original.Name
+	}
+	if len(email.ReplyTo) == 0 {
+		email.ReplyTo = []domain.Address{original}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package app
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/igorrius/resend-railway-gateway/internal/domain"
+)
+
+func TestSenderPolicy_Allows(t *testing.T) {
+	p := &SenderPolicy{
+		Allowed: []string{"example.com", "noreply@acme.com"},
+		PerUser: map[string][]string{"alice": {"alice.io"}},
+	}
+	cases := []struct {
+		user, addr string
+		want       bool
+	}{
+		{"", "Someone@Example.com", true},
+		{"", "noreply@acme.com", true},
+		{"", "sales@acme.com", false},
+		{"", "x@sub.example.com", false},
+		{"bob", "x@example.com", true},
+		{"alice", "x@alice.io", true},
+		{"alice", "x@example.com", false},
+	}
+	for _, c := range cases {
+		if got := p.Allows(c.user, c.addr); got != c.want {
+			t.Errorf("Allows(%q, %q) = %v, want %v", c.user, c.addr, got, c.want)
+		}
+	}
+	var none *SenderPolicy
+	if !none.Allows("", "anyone@anywhere.test") {
+		t.Errorf("a nil policy must allow every sender")
+	}
+	if err := p.CheckEnvelope("", ""); err != nil {
+		t.Errorf("null sender must be accepted, got %v", err)
+	}
+}
+
+func TestHandleEmail_SenderPolicy(t *testing.T) {
+	rec := &recordingSender{}
+	svc := NewService(rec, nopLogger{}, time.Second, WithSenderPolicy(&SenderPolicy{Allowed: []string{"example.com"}}))
+	email, _ := domain.NewEmail("a@other.com", []string{"b@example.com"}, "hi", "text", "", nil)
+	if err := svc.HandleEmail(email); !errors.Is(err, domain.ErrSenderNotAllowed) {
+		t.Fatalf("expected ErrSenderNotAllowed, got %v", err)
+	}
+	if len(rec.sent) != 0 {
+		t.Fatalf("rejected email must not be sent")
+	}
+}
+
+func TestHandleEmail_SenderPolicyRewrite(t *testing.T) {
+	rec := &recordingSender{}
+	svc := NewService(rec, nopLogger{}, time.Second, WithSenderPolicy(&SenderPolicy{
+		Allowed:   []string{"example.com"},
+		RewriteTo: domain.Address{Addr: "noreply@example.com"},
+	}))
+	email, _ := domain.NewEmail("Jane <jane@other.com>", []string{"b@example.com"}, "hi", "text", "", nil)
+	if err := svc.HandleEmail(email); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	got := rec.sent[0]
+	if got.From != (domain.Address{Name: "Jane", Addr: "noreply@example.com"}) {
+		t.Errorf("expected rewritten From, got %v", got.From)
+	}
+	if len(got.ReplyTo) != 1 || got.ReplyTo[0].Addr != "jane@other.com" {
+		t.Errorf("expected original From in Reply-To, got %v", got.ReplyTo)
+	}
+}
@@ -2,53 +2,226 @@ package app
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/igorrius/resend-railway-gateway/internal/domain"
+	"github.com/igorrius/resend-railway-gateway/internal/suppression"
 )
 
 // Service orchestrates handling incoming email messages and delegating to the email provider.
 // It handles validation, timeout management, and error logging.
 type Service struct {
-	sender  domain.OutboundEmailSender
-	logger  domain.MessageLogger
-	timeout time.Duration
+	sender domain.OutboundEmailSender
+	logger domain.MessageLogger
+	mu     sync.RWMutex // guards policies
+	policies
+	suppressions *suppression.List
+}
+
+// policies are the settings Reconfigure replaces while emails are handled.
+type policies struct {
+	timeout    time.Duration
+	transforms []Transform
+	senders    *SenderPolicy
+	recipients *RecipientPolicy
+}
+
+// Option configures optional Service behaviour.
+type Option func(*Service)
+
+// WithTransforms registers transformation steps that are applied, in order,
+// to every email before validation.
+func WithTransforms(transforms ...Transform) Option {
+	return func(s *Service) { s.transforms = append(s.transforms, transforms...) }
+}
+
+// WithSenderPolicy restricts the envelope and header From addresses clients may use.
+func WithSenderPolicy(p *SenderPolicy) Option {
+	return func(s *Service) { s.senders = p }
+}
+
+// WithRecipientPolicy enables sandbox mode restricting who may receive mail.
+func WithRecipientPolicy(p *RecipientPolicy) Option {
+	return func(s *Service) { s.recipients = p }
 }
 
 // NewService creates a new Service instance with the given dependencies.
 // - sender: Implementation of the email sender
 // - logger: Logger for structured logging
 // - timeout: Maximum duration to wait for email delivery
-func NewService(sender domain.OutboundEmailSender, logger domain.MessageLogger, timeout time.Duration) *Service {
-	return &Service{sender: sender, logger: logger, timeout: timeout}
+// - opts: Optional behaviour such as transformation steps
+func NewService(sender domain.OutboundEmailSender, logger domain.MessageLogger, timeout time.Duration, opts ...Option) *Service {
+	s := &Service{sender: sender, logger: logger, policies: policies{timeout: timeout}}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Reconfigure replaces the timeout and the transformation steps and policies
+// of a running service, e.g. after the configuration was reloaded, as if it
+// had been created with them. The sender, logger and suppression list are
+// kept. Emails already being handled finish with the previous settings.
+func (s *Service) Reconfigure(timeout time.Duration, opts ...Option) {
+	n := &Service{policies: policies{timeout: timeout}}
+	for _, opt := range opts {
+		opt(n)
+	}
+	s.mu.Lock()
+	s.policies = n.policies
+	s.mu.Unlock()
+}
+
+// current returns the policies in effect.
+func (s *Service) current() policies {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.policies
 }
 
 // HandleEmail validates and sends the email with context timeout.
 // It performs the following steps:
-// 1. Validates the email structure
-// 2. Creates a context with timeout
-// 3. Sends the email asynchronously
-// 4. Returns an error if validation fails, send fails, or timeout occurs
+// 1. Applies the configured transformation steps
+// 2. Enforces the sender policy on the header From
+// 3. Enforces the sandbox recipient policy, possibly discarding the email
+// 4. Removes suppressed recipients
+// 5. Validates the email structure
+// 6. Creates a context with timeout
+// 7. Sends the email asynchronously
+// 8. Returns an error if a policy or validation fails, send fails, or timeout occurs
 func (s *Service) HandleEmail(email domain.Email) error {
+	_, _, err := s.handle(email)
+	return err
+}
+
+// HandleEmailID is HandleEmail for adapters that report the provider's ID of
+// the sent email to the client, such as the HTTP send API. The ID is empty
+// when the sandbox discarded the email.
+func (s *Service) HandleEmailID(email domain.Email) (string, error) {
+	id, _, err := s.handle(email)
+	return id, err
+}
+
+// HandleEmailRecipients is HandleEmail for protocols that report a status per
+// envelope recipient, such as LMTP. It returns the outcome for each of rcpts;
+// nil means the recipient was accepted. Suppressed recipients fail on their
+// own while the email is sent to the others. When the provider rejects some
+// recipients, the others get a temporary error so that the client retries
+// them without the rejected ones. Other errors apply to every recipient.
+func (s *Service) HandleEmailRecipients(email domain.Email, rcpts []string) map[string]error {
+	_, suppressed, err := s.handle(email)
+	var rejected *domain.RecipientRejectedError
+	errors.As(err, &rejected)
+	results := make(map[string]error, len(rcpts))
+	for _, r := range rcpts {
+		addr := domain.NormalizeAddr(r)
+		switch {
+		case containsFold(suppressed, addr):
+			results[r] = fmt.Errorf("%w: %s", domain.ErrRecipientSuppressed, r)
+		case rejected != nil && len(rejected.Recipients) > 0 && !containsFold(rejected.Recipients, addr):
+			results[r] = &domain.RecipientRejectedError{
+				Recipients: []string{r},
+				Message:    "not sent because other recipients were rejected, try again",
+			}
+		default:
+			results[r] = err
+		}
+	}
+	return results
+}
+
+// handle runs the pipeline of HandleEmail and also returns the provider ID
+// and the recipients removed because they are suppressed.
+func (s *Service) handle(email domain.Email) (id string, suppressed []string, err error) {
+	p := s.current()
+	email.NormalizeAddresses()
+	for _, t := range p.transforms {
+		t(&email)
+	}
+	if err := p.senders.Apply(&email); err != nil {
+		fields := logFields(email)
+		fields["error"] = err
+		s.logger.Info("sender_rejected", fields)
+		return "", nil, err
+	}
+	deliver, err := p.recipients.Apply(&email)
+	if err != nil {
+		fields := logFields(email)
+		fields["error"] = err
+		s.logger.Info("recipient_rejected", fields)
+		return "", nil, err
+	}
+	if !deliver {
+		s.logger.Info("sandbox_dropped", logFields(email))
+		return "", nil, nil
+	}
+	suppressed = s.removeSuppressed(&email)
+	if len(suppressed) > 0 {
+		fields := logFields(email)
+		fields["suppressed"] = suppressed
+		s.logger.Info("suppressed_recipients_removed", fields)
+		if len(email.To) == 0 {
+			return "", suppressed, fmt.Errorf("%w: %s", domain.ErrRecipientSuppressed, strings.Join(suppressed, ", "))
+		}
+	}
 	if err := email.Validate(); err != nil {
-		return err
+		return "", suppressed, err
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
 	defer cancel()
-	done := make(chan error, 1)
-	go func() { done <- s.sender.Send(email) }()
+	type result struct {
+		id  string
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		id, err := s.sender.Send(email)
+		done <- result{id, err}
+	}()
 
 	select {
-	case err := <-done:
-		if err != nil {
-			s.logger.Error("send_failed", map[string]any{"error": err})
-			return fmt.Errorf("send failed: %w", err)
+	case res := <-done:
+		if res.err != nil {
+			fields := logFields(email)
+			fields["error"] = res.err
+			s.logger.Error("send_failed", fields)
+			s.suppressRejected(res.err)
+			return "", suppressed, fmt.Errorf("send failed: %w", res.err)
 		}
-		s.logger.Info("send_ok", map[string]any{"to": email.To})
-		return nil
+		fields := logFields(email)
+		fields["id"] = res.id
+		s.logger.Info("send_ok", fields)
+		return res.id, suppressed, nil
 	case <-ctx.Done():
-		s.logger.Error("send_timeout", map[string]any{"to": email.To})
-		return ctx.Err()
+		s.logger.Error("send_timeout", logFields(email))
+		return "", suppressed, ctx.Err()
+	}
+}
+
+// CheckSender reports whether user may use mailFrom as the envelope sender.
+// It lets protocol adapters reject a sender before the message is transferred.
+func (s *Service) CheckSender(user, mailFrom string) error {
+	return s.current().senders.CheckEnvelope(user, domain.NormalizeAddr(mailFrom))
+}
+
+// CheckRecipient reports whether addr may be accepted as an envelope recipient.
+func (s *Service) CheckRecipient(addr string) error {
+	addr = domain.NormalizeAddr(addr)
+	if err := s.current().recipients.CheckRecipient(addr); err != nil {
+		return err
+	}
+	return s.checkSuppressed(addr)
+}
+
+// logFields returns the structured fields shared by per-message log lines.
+func logFields(email domain.Email) map[string]any {
+	fields := map[string]any{"to": domain.AddressStrings(email.To)}
+	if email.Envelope.RemoteAddr != "" {
+		fields["client"] = email.Envelope.RemoteAddr
 	}
+	return fields
 }
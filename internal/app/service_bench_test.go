@@ -9,7 +9,7 @@ import (
 
 type benchSender struct{}
 
-func (benchSender) Send(_ domain.Email) error { return nil }
+func (benchSender) Send(_ domain.Email) (string, error) { return "id", nil }
 
 type benchLogger struct{}
 
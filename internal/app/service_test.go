@@ -10,7 +10,7 @@ import (
 
 type fakeSender struct{ err error }
 
-func (f fakeSender) Send(_ domain.Email) error { return f.err }
+func (f fakeSender) Send(_ domain.Email) (string, error) { return "id", f.err }
 
 type nopLogger struct{}
 
@@ -25,6 +25,30 @@ func TestHandleEmail_OK(t *testing.T) {
 	}
 }
 
+func TestHandleEmailID(t *testing.T) {
+	svc := NewService(fakeSender{}, nopLogger{}, time.Second)
+	email, _ := domain.NewEmail("a@example.com", []string{"b@example.com"}, "hi", "text", "", nil)
+	id, err := svc.HandleEmailID(email)
+	if err != nil || id != "id" {
+		t.Fatalf("expected id, got %q, %v", id, err)
+	}
+}
+
+func TestReconfigure(t *testing.T) {
+	svc := NewService(fakeSender{}, nopLogger{}, time.Second)
+	email, _ := domain.NewEmail("a@other.org", []string{"b@example.com"}, "hi", "text", "", nil)
+	if err := svc.HandleEmail(email); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	svc.Reconfigure(time.Second, WithSenderPolicy(&SenderPolicy{Allowed: []string{"example.com"}}))
+	if err := svc.HandleEmail(email); !errors.Is(err, domain.ErrSenderNotAllowed) {
+		t.Fatalf("expected sender to be rejected after reconfiguring, got %v", err)
+	}
+	if err := svc.CheckSender("", "a@other.org"); !errors.Is(err, domain.ErrSenderNotAllowed) {
+		t.Fatalf("expected envelope sender to be rejected, got %v", err)
+	}
+}
+
 func TestHandleEmail_Error(t *testing.T) {
 	svc := NewService(fakeSender{err: errors.New("boom")}, nopLogger{}, time.Second)
 	email, _ := domain.NewEmail("a@example.com", []string{"b@example.com"}, "hi", "text", "", nil)
@@ -32,3 +56,34 @@ func TestHandleEmail_Error(t *testing.T) {
 		t.Fatalf("expected error")
 	}
 }
+
+type recordingSender struct{ sent []domain.Email }
+
+func (r *recordingSender) Send(email domain.Email) (string, error) {
+	r.sent = append(r.sent, email)
+	return "id", nil
+}
+
+func TestHandleEmail_GeneratePlainText(t *testing.T) {
+	rec := &recordingSender{}
+	svc := NewService(rec, nopLogger{}, time.Second, WithTransforms(GeneratePlainText()))
+	email, _ := domain.NewEmail("a@example.com", []string{"b@example.com"}, "hi", "", "<p>Hello <a href=\"https://example.com/x\">there</a></p>", nil)
+	if err := svc.HandleEmail(email); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	if got, want := rec.sent[0].Text, "Hello there [1]\n\n[1] https://example.com/x"; got != want {
+		t.Errorf("expected generated text %q, got %q", want, got)
+	}
+}
+
+func TestHandleEmail_GeneratePlainTextKeepsExistingText(t *testing.T) {
+	rec := &recordingSender{}
+	svc := NewService(rec, nopLogger{}, time.Second, WithTransforms(GeneratePlainText()))
+	email, _ := domain.NewEmail("a@example.com", []string{"b@example.com"}, "hi", "original", "<p>html</p>", nil)
+	if err := svc.HandleEmail(email); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	if rec.sent[0].Text != "original" {
+		t.Errorf("expected text part to be kept, got %q", rec.sent[0].Text)
+	}
+}
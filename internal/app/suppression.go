@@ -0,0 +1,79 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/igorrius/resend-railway-gateway/internal/domain"
+	"github.com/igorrius/resend-railway-gateway/internal/suppression"
+)
+
+// WithSuppressions refuses recipients on the suppression list and adds
+// recipients the provider permanently rejected to it.
+func WithSuppressions(list *suppression.List) Option {
+	return func(s *Service) { s.suppressions = list }
+}
+
+// checkSuppressed returns ErrRecipientSuppressed when addr is on the list.
+func (s *Service) checkSuppressed(addr string) error {
+	if s.suppressions == nil {
+		return nil
+	}
+	if _, ok := s.suppressions.Get(addr); ok {
+		return fmt.Errorf("%w: %s", domain.ErrRecipientSuppressed, addr)
+	}
+	return nil
+}
+
+// removeSuppressed drops suppressed addresses from every recipient list and
+// returns them. When To ends up empty it is refilled by ensureTo, which never
+// promotes Bcc recipients.
+func (s *Service) removeSuppressed(email *domain.Email) []string {
+	if s.suppressions == nil {
+		return nil
+	}
+	var removed []string
+	for _, list := range []*[]domain.Address{&email.To, &email.Cc, &email.Bcc} {
+		var kept []domain.Address
+		for _, a := range *list {
+			if _, ok := s.suppressions.Get(a.Addr); ok {
+				removed = append(removed, a.Addr)
+				continue
+			}
+			kept = append(kept, a)
+		}
+		*list = kept
+	}
+	if len(removed) > 0 {
+		ensureTo(email, domain.Address{})
+	}
+	return removed
+}
+
+// suppressRejected adds the recipients of a permanent provider rejection to
+// the suppression list.
+func (s *Service) suppressRejected(err error) {
+	var rerr *domain.RecipientRejectedError
+	if s.suppressions == nil || !errors.As(err, &rerr) || !rerr.Permanent {
+		return
+	}
+	entries := make([]suppression.Entry, 0, len(rerr.Recipients))
+	for _, r := range rerr.Recipients {
+		entries = append(entries, suppression.Entry{Address: r, Reason: suppression.ReasonRejected, Detail: rerr.Message})
+	}
+	if err := s.suppressions.AddAll(entries); err != nil {
+		s.logger.Error("suppression_update_failed", map[string]any{"error": err})
+		return
+	}
+	s.logger.Info("recipients_suppressed", map[string]any{"to": rerr.Recipients, "reason": strings.TrimSpace(rerr.Message)})
+}
+
+func containsFold(list []string, addr string) bool {
+	for _, a := range list {
+		if strings.EqualFold(domain.NormalizeAddr(a), addr) {
+			return true
+		}
+	}
+	return false
+}
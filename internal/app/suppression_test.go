@@ -0,0 +1,116 @@
+package app
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/igorrius/resend-railway-gateway/internal/domain"
+	"github.com/igorrius/resend-railway-gateway/internal/suppression"
+)
+
+func suppressionList(t *testing.T, addrs ...string) *suppression.List {
+	t.Helper()
+	l, _ := suppression.Open("", nil)
+	for _, a := range addrs {
+		if err := l.Add(suppression.Entry{Address: a, Reason: suppression.ReasonBounce}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return l
+}
+
+func TestCheckRecipient_Suppressed(t *testing.T) {
+	svc := NewService(fakeSender{}, nopLogger{}, time.Second, WithSuppressions(suppressionList(t, "dead@example.com")))
+	if err := svc.CheckRecipient("Dead@example.com"); !errors.Is(err, domain.ErrRecipientSuppressed) {
+		t.Errorf("expected ErrRecipientSuppressed, got %v", err)
+	}
+	if err := svc.CheckRecipient("alive@example.com"); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestHandleEmail_RemovesSuppressed(t *testing.T) {
+	rec := &recordingSender{}
+	svc := NewService(rec, nopLogger{}, time.Second, WithSuppressions(suppressionList(t, "dead@example.com")))
+
+	email, _ := domain.NewEmail("a@example.com", []string{"dead@example.com"}, "hi", "text", "", nil)
+	email.Cc = []domain.Address{{Addr: "cc@example.com"}}
+	if err := svc.HandleEmail(email); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if got := rec.sent[0]; len(got.To) != 1 || got.To[0].Addr != "cc@example.com" || len(got.Cc) != 0 {
+		t.Errorf("expected cc to be promoted, got to=%v cc=%v", got.To, got.Cc)
+	}
+
+	email, _ = domain.NewEmail("a@example.com", []string{"dead@example.com"}, "hi", "text", "", nil)
+	if err := svc.HandleEmail(email); !errors.Is(err, domain.ErrRecipientSuppressed) {
+		t.Errorf("expected ErrRecipientSuppressed, got %v", err)
+	}
+
+	email, _ = domain.NewEmail("a@example.com", []string{"dead@example.com"}, "hi", "text", "", nil)
+	email.Bcc = []domain.Address{{Addr: "hidden@example.com"}}
+	if err := svc.HandleEmail(email); !errors.Is(err, domain.ErrRecipientSuppressed) {
+		t.Errorf("expected ErrRecipientSuppressed, got %v", err)
+	}
+	if len(rec.sent) != 1 {
+		t.Errorf("a Bcc recipient must not be moved to To, got to=%v", rec.sent[len(rec.sent)-1].To)
+	}
+}
+
+func TestHandleEmail_SuppressesPermanentRejections(t *testing.T) {
+	list := suppressionList(t)
+	rejection := &domain.RecipientRejectedError{Recipients: []string{"gone@example.com"}, Permanent: true, Message: "550 5.1.1 no such user"}
+	svc := NewService(fakeSender{err: rejection}, nopLogger{}, time.Second, WithSuppressions(list))
+
+	email, _ := domain.NewEmail("a@example.com", []string{"gone@example.com"}, "hi", "text", "", nil)
+	if err := svc.HandleEmail(email); err == nil {
+		t.Fatal("expected send error")
+	}
+	if e, ok := list.Get("gone@example.com"); !ok || e.Reason != suppression.ReasonRejected {
+		t.Errorf("expected gone@example.com to be suppressed, got %+v", e)
+	}
+
+	temporary := &domain.RecipientRejectedError{Recipients: []string{"busy@example.com"}, Message: "mailbox full"}
+	svc = NewService(fakeSender{err: temporary}, nopLogger{}, time.Second, WithSuppressions(list))
+	email, _ = domain.NewEmail("a@example.com", []string{"busy@example.com"}, "hi", "text", "", nil)
+	_ = svc.HandleEmail(email)
+	if _, ok := list.Get("busy@example.com"); ok {
+		t.Error("temporary rejections must not be suppressed")
+	}
+}
+
+func TestHandleEmailRecipients(t *testing.T) {
+	rec := &recordingSender{}
+	svc := NewService(rec, nopLogger{}, time.Second, WithSuppressions(suppressionList(t, "dead@example.com")))
+
+	email, _ := domain.NewEmail("a@example.com", []string{"dead@example.com", "alive@example.com"}, "hi", "text", "", nil)
+	results := svc.HandleEmailRecipients(email, []string{"Dead@example.com", "alive@example.com"})
+	if !errors.Is(results["Dead@example.com"], domain.ErrRecipientSuppressed) {
+		t.Errorf("expected the suppressed recipient to fail, got %v", results["Dead@example.com"])
+	}
+	if results["alive@example.com"] != nil {
+		t.Errorf("expected alive@example.com to be accepted, got %v", results["alive@example.com"])
+	}
+	if len(rec.sent) != 1 || len(rec.sent[0].To) != 1 {
+		t.Fatalf("expected one email to the remaining recipient, got %+v", rec.sent)
+	}
+
+	rejection := &domain.RecipientRejectedError{Recipients: []string{"gone@example.com"}, Permanent: true, Message: "no such user"}
+	svc = NewService(fakeSender{err: rejection}, nopLogger{}, time.Second)
+	email, _ = domain.NewEmail("a@example.com", []string{"gone@example.com", "other@example.com"}, "hi", "text", "", nil)
+	results = svc.HandleEmailRecipients(email, []string{"gone@example.com", "other@example.com"})
+	var rerr *domain.RecipientRejectedError
+	if !errors.As(results["gone@example.com"], &rerr) || !rerr.Permanent {
+		t.Errorf("expected a permanent rejection, got %v", results["gone@example.com"])
+	}
+	if !errors.As(results["other@example.com"], &rerr) || rerr.Permanent {
+		t.Errorf("expected a temporary failure for the other recipient, got %v", results["other@example.com"])
+	}
+
+	svc = NewService(fakeSender{err: errors.New("boom")}, nopLogger{}, time.Second)
+	results = svc.HandleEmailRecipients(email, []string{"gone@example.com", "other@example.com"})
+	if results["gone@example.com"] == nil || results["other@example.com"] == nil {
+		t.Errorf("expected a send failure for every recipient, got %v", results)
+	}
+}
@@ -0,0 +1,22 @@
+package app
+
+import (
+	"strings"
+
+	"github.com/igorrius/resend-railway-gateway/internal/domain"
+	"github.com/igorrius/resend-railway-gateway/internal/htmltext"
+)
+
+// Transform modifies an email before it is validated and sent.
+type Transform func(email *domain.Email)
+
+// GeneratePlainText returns a Transform that renders a text/plain alternative
+// from the HTML body when the message has no text part of its own.
+func GeneratePlainText() Transform {
+	return func(email *domain.Email) {
+		if strings.TrimSpace(email.Text) != "" || strings.TrimSpace(email.HTML) == "" {
+			return
+		}
+		email.Text = htmltext.Render(email.HTML)
+	}
+}
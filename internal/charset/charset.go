@@ -0,0 +1,52 @@
+// Package charset converts text in the character sets found in email to UTF-8.
+package charset
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// Reader returns a reader converting input from the named charset to UTF-8.
+// It has the signature of mime.WordDecoder's CharsetReader.
+func Reader(name string, input io.Reader) (io.Reader, error) {
+	enc, err := htmlindex.Get(strings.TrimSpace(name))
+	if err != nil {
+		return nil, fmt.Errorf("unsupported charset %q", name)
+	}
+	return enc.NewDecoder().Reader(input), nil
+}
+
+// Decode converts b from the named charset to UTF-8. Text without a charset
+// or in an unknown one is returned unchanged.
+func Decode(b []byte, name string) string {
+	if name == "" {
+		return string(b)
+	}
+	enc, err := htmlindex.Get(strings.TrimSpace(name))
+	if err != nil {
+		return string(b)
+	}
+	out, err := enc.NewDecoder().Bytes(b)
+	if err != nil {
+		return string(b)
+	}
+	return string(out)
+}
+
+// WordDecoder decodes RFC 2047 encoded words in any supported charset.
+var WordDecoder = &mime.WordDecoder{CharsetReader: Reader}
+
+// DecodeHeader decodes the RFC 2047 encoded words in a header value such as
+// "=?ISO-2022-JP?B?...?=". Values that cannot be decoded are returned as is;
+// raw UTF-8 (RFC 6532) needs no decoding.
+func DecodeHeader(s string) string {
+	out, err := WordDecoder.DecodeHeader(s)
+	if err != nil {
+		return s
+	}
+	return out
+}
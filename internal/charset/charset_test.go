@@ -0,0 +1,31 @@
+package charset
+
+import "testing"
+
+func TestDecodeHeader(t *testing.T) {
+	cases := map[string]string{
+		"=?UTF-8?B?0J/RgNC40LLQtdGC?= world":                  "Привет world",
+		"=?ISO-2022-JP?B?GyRCRnxLXDhsGyhC?=":                  "日本語",
+		"=?KOI8-R?Q?=F0=D2=C9=D7=C5=D4?=":                     "Привет",
+		"=?iso-8859-1?Q?B=FCcher?= und =?utf-8?Q?=E2=82=AC?=": "Bücher und €",
+		"Grüße, raw UTF-8":                                    "Grüße, raw UTF-8",
+		"=?x-unknown?Q?abc?=":                                 "=?x-unknown?Q?abc?=",
+	}
+	for in, want := range cases {
+		if got := DecodeHeader(in); got != want {
+			t.Errorf("DecodeHeader(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	if got := Decode([]byte{0xf0, 0xd2, 0xc9, 0xd7, 0xc5, 0xd4}, "koi8-r"); got != "Привет" {
+		t.Errorf("koi8-r: got %q", got)
+	}
+	if got := Decode([]byte("B\xfccher"), "windows-1252"); got != "Bücher" {
+		t.Errorf("windows-1252: got %q", got)
+	}
+	if got := Decode([]byte("plain"), ""); got != "plain" {
+		t.Errorf("no charset: got %q", got)
+	}
+}
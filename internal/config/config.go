@@ -2,41 +2,576 @@ package config
 
 import (
 	"fmt"
+	"io/fs"
 	"os"
+	"reflect"
 	"strconv"
+	"strings"
 	"time"
 )
 
 // Config holds all configuration for the SMTP gateway service.
 type Config struct {
-	ResendAPIKey   string
+	ResendAPIKey string
+	// SMTPListerAddr is a comma separated list of SMTP listen addresses: TCP
+	// addresses and Unix sockets ("unix:/path" or an absolute path).
 	SMTPListerAddr string
 	SendTimeout    time.Duration
+	// GenerateText enables rendering a text/plain alternative for HTML-only emails.
+	GenerateText bool
+	// Routes select a Resend API key per sender; ResendAPIKey is the fallback.
+	Routes []Route
+	// SMTPUsers maps SMTP AUTH usernames to passwords.
+	SMTPUsers map[string]string
+	// APITokens maps client names to the bearer tokens of the HTTP send API;
+	// setting it enables the API. The name acts as the authenticated user.
+	APITokens map[string]string
+	// LMTPListenAddr enables LMTP listeners; same format as SMTPListerAddr.
+	LMTPListenAddr string
+	// SocketMode is the permission mode of Unix sockets.
+	SocketMode fs.FileMode
+	// SMTPMaxMessageBytes is the largest message accepted over SMTP, advertised
+	// with the SIZE extension; zero means unlimited.
+	SMTPMaxMessageBytes int64
+	// AllowNetworks, DenyNetworks and TrustedNetworks are CIDR prefixes, IPs
+	// or shortcuts such as "private" controlling who may connect and relay.
+	AllowNetworks   []string
+	DenyNetworks    []string
+	TrustedNetworks []string
+	// RequireAuth refuses unauthenticated relay from outside TrustedNetworks.
+	RequireAuth bool
+	// ProxyNetworks enables PROXY protocol parsing for connections from these networks.
+	ProxyNetworks []string
+	// RateLimits are message quotas per user, sender domain or client IP.
+	RateLimits []RateLimit
+	// MaxConnsPerIP caps concurrent SMTP connections per client IP; zero disables it.
+	MaxConnsPerIP int
+	// RateLimitStateFile persists quota counters across restarts when set.
+	RateLimitStateFile string
+	// AllowedSenders lists sender domains or addresses every client may use.
+	AllowedSenders []string
+	// AllowedSendersByUser overrides AllowedSenders per authenticated SMTP user.
+	AllowedSendersByUser map[string][]string
+	// SenderRewriteFrom replaces a disallowed header From instead of rejecting the email.
+	SenderRewriteFrom string
+	// SandboxMode is "", "reject", "drop" or "redirect"; empty disables sandbox mode.
+	SandboxMode string
+	// SandboxDomains, SandboxAddresses and SandboxPatterns list allowed recipients.
+	SandboxDomains   []string
+	SandboxAddresses []string
+	SandboxPatterns  []string
+	// SandboxCatchAll receives redirected mail in "redirect" mode, and in
+	// "drop" mode mail left with Bcc recipients only.
+	SandboxCatchAll string
+	// Provider is "resend", "capture" or "file"; capture stores emails for the
+	// web UI and file only writes them to ArchiveDir.
+	Provider string
+	// ProviderImplicit reports that PROVIDER is unset and Provider was chosen
+	// from the configured keys, e.g. capture because no API key is set.
+	ProviderImplicit bool
+	// CaptureDir persists captured emails on disk; empty keeps them in memory.
+	CaptureDir string
+	// CaptureMaxMessages bounds the in-memory capture store.
+	CaptureMaxMessages int
+	// HTTPListenAddr is the address of the HTTP server exposing the capture UI,
+	// webhook receiver, status API and HTTP send API.
+	HTTPListenAddr string
+	// DryRun renders and logs every Resend request instead of sending it.
+	DryRun bool
+	// DryRunDir additionally writes dry-run requests to this directory.
+	DryRunDir string
+	// ArchiveDir receives a .eml copy of every sent email when set.
+	ArchiveDir string
+	// ArchiveLayout is "maildir" or "dated".
+	ArchiveLayout string
+	// ArchiveGzip compresses archived emails.
+	ArchiveGzip bool
+	// WebhookSecret is the Svix signing secret ("whsec_...") of the Resend webhook;
+	// setting it enables the webhook receiver.
+	WebhookSecret string
+	// DeliveryTrackMax bounds the number of emails whose delivery status is kept.
+	DeliveryTrackMax int
+	// SuppressionFile persists the suppression list; empty keeps it in memory.
+	SuppressionFile string
+	// SuppressionTTL is how long suppressions last per reason ("bounce",
+	// "complaint", "rejected", "manual"); missing reasons never expire.
+	SuppressionTTL map[string]time.Duration
+	// AdminToken enables the admin HTTP API for bearer tokens matching it.
+	AdminToken string
+	// DSNFrom is the header From of delivery status notifications; setting it
+	// enables notifying envelope senders of bounces reported by webhooks.
+	DSNFrom string
+	// DSNReportingMTA names the gateway in notifications; defaults to the host name.
+	DSNReportingMTA string
+	// ConfigFile is the configuration file read, if any.
+	ConfigFile string
+	// WatchInterval is how often ConfigFile is checked for changes; zero
+	// disables watching, leaving SIGHUP to trigger reloads.
+	WatchInterval time.Duration
+	// Secrets names the credential settings read from files or a secret
+	// manager, such as RESEND_API_KEY from RESEND_API_KEY_FILE.
+	Secrets []string
+	// SecretsRefresh is how often Secrets are read again so that rotated
+	// credentials are picked up; zero disables refreshing.
+	SecretsRefresh time.Duration
 }
 
-func getenv(key, def string) string {
-	if v := os.Getenv(key); v != "" {
+// Providers accepted in PROVIDER.
+const (
+	ProviderResend  = "resend"
+	ProviderCapture = "capture"
+	ProviderFile    = "file"
+)
+
+// RateLimit allows Limit messages per Window for each distinct Scope value.
+// Scope is one of "user", "domain" or "ip".
+type RateLimit struct {
+	Scope  string
+	Window time.Duration
+	Limit  int
+}
+
+// Route maps a sender attribute to a Resend API key.
+// Match is one of "user", "mail-from" or "from".
+type Route struct {
+	Match  string
+	Value  string
+	APIKey string
+}
+
+func (l loader) getenv(key, def string) string {
+	if v := l.get(key); v != "" {
 		return v
 	}
 	return def
 }
 
-// Load reads configuration from environment variables and returns a Config struct.
-// Returns an error if RESEND_API_KEY is not set or if timeout configuration is invalid.
+// getenvList splits a comma separated variable into trimmed, non-empty items.
+func (l loader) getenvList(key string) []string {
+	var out []string
+	for _, item := range strings.Split(l.get(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+func (l loader) getenvBool(key string, def bool) (bool, error) {
+	v := l.get(key)
+	if v == "" {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("%s must be a boolean, got %q", key, v)
+	}
+	return b, nil
+}
+
+// parseRoutes parses RESEND_ROUTES entries of the form "match:value=api_key"
+// separated by commas, e.g. "user:alice=re_1,mail-from:acme.com=re_2,from:beta.io=re_3".
+func parseRoutes(s string) ([]Route, error) {
+	var routes []Route
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		selector, key, ok := strings.Cut(entry, "=")
+		match, value, ok2 := strings.Cut(selector, ":")
+		match, value, key = strings.TrimSpace(match), strings.TrimSpace(value), strings.TrimSpace(key)
+		if !ok || !ok2 || value == "" || key == "" {
+			return nil, fmt.Errorf("RESEND_ROUTES: invalid entry %q, expected match:value=api_key", entry)
+		}
+		switch match {
+		case "user", "mail-from", "from":
+		default:
+			return nil, fmt.Errorf("RESEND_ROUTES: unknown match %q in %q, expected user, mail-from or from", match, entry)
+		}
+		routes = append(routes, Route{Match: match, Value: value, APIKey: key})
+	}
+	return routes, nil
+}
+
+var rateWindows = map[string]time.Duration{
+	"minute": time.Minute,
+	"hour":   time.Hour,
+	"day":    24 * time.Hour,
+}
+
+// parseRateLimits parses RATE_LIMITS entries of the form "scope:window=limit"
+// separated by commas, e.g. "user:minute=60,domain:day=5000,ip:hour=500".
+func parseRateLimits(s string) ([]RateLimit, error) {
+	var limits []RateLimit
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		selector, limitStr, ok := strings.Cut(entry, "=")
+		scope, window, ok2 := strings.Cut(selector, ":")
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("RATE_LIMITS: invalid entry %q, expected scope:window=limit", entry)
+		}
+		scope, window = strings.TrimSpace(scope), strings.TrimSpace(window)
+		switch scope {
+		case "user", "domain", "ip":
+		default:
+			return nil, fmt.Errorf("RATE_LIMITS: unknown scope %q in %q, expected user, domain or ip", scope, entry)
+		}
+		d, ok := rateWindows[window]
+		if !ok {
+			return nil, fmt.Errorf("RATE_LIMITS: unknown window %q in %q, expected minute, hour or day", window, entry)
+		}
+		limit, err := strconv.Atoi(strings.TrimSpace(limitStr))
+		if err != nil || limit <= 0 {
+			return nil, fmt.Errorf("RATE_LIMITS: limit in %q must be a positive integer", entry)
+		}
+		limits = append(limits, RateLimit{Scope: scope, Window: d, Limit: limit})
+	}
+	return limits, nil
+}
+
+// parseSendersByUser parses ALLOWED_SENDERS_BY_USER entries of the form
+// "user=entry|entry" separated by semicolons, e.g. "alice=acme.com|noreply@beta.io;bob=bob.dev".
+func parseSendersByUser(s string) (map[string][]string, error) {
+	out := map[string][]string{}
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		user, list, ok := strings.Cut(entry, "=")
+		user = strings.TrimSpace(user)
+		if !ok || user == "" {
+			return nil, fmt.Errorf("ALLOWED_SENDERS_BY_USER: invalid entry %q, expected user=domain|address", entry)
+		}
+		for _, item := range strings.Split(list, "|") {
+			if item = strings.TrimSpace(item); item != "" {
+				out[user] = append(out[user], item)
+			}
+		}
+		if len(out[user]) == 0 {
+			return nil, fmt.Errorf("ALLOWED_SENDERS_BY_USER: no senders listed for %q", user)
+		}
+	}
+	return out, nil
+}
+
+func hostname() string {
+	h, err := os.Hostname()
+	if err != nil {
+		return "localhost"
+	}
+	return h
+}
+
+// parseTTL parses a duration such as "720h" or "30d"; empty means zero.
+func (l loader) parseTTL(key string) (time.Duration, error) {
+	v := strings.TrimSpace(l.get(key))
+	if v == "" {
+		return 0, nil
+	}
+	if days, ok := strings.CutSuffix(v, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("%s must be a duration such as 720h or 30d, got %q", key, v)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%s must be a duration such as 720h or 30d, got %q", key, v)
+	}
+	return d, nil
+}
+
+// parseUsers parses entries of the form "username:password" separated by
+// commas, as in SMTP_USERS and HTTP_API_TOKENS; key names the variable in errors.
+func parseUsers(key, s string) (map[string]string, error) {
+	users := map[string]string{}
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		user, pass, ok := strings.Cut(entry, ":")
+		if !ok || user == "" || pass == "" {
+			return nil, fmt.Errorf("%s: invalid entry for %q, expected username:password", key, user)
+		}
+		users[user] = pass
+	}
+	return users, nil
+}
+
+// Load reads the configuration from environment variables and, when
+// CONFIG_FILE names one, a YAML configuration file (see readFile). Variables
+// set in the environment take precedence over the file.
+// Without RESEND_API_KEY and RESEND_ROUTES the capture provider is selected.
+// Returns an error if PROVIDER=resend lacks an API key or if a value is invalid.
 func Load() (Config, error) {
-	key := os.Getenv("RESEND_API_KEY")
-	if key == "" {
-		return Config{}, fmt.Errorf("RESEND_API_KEY is required")
+	l := loader{path: os.Getenv("CONFIG_FILE")}
+	if l.path != "" {
+		file, err := readFile(l.path)
+		if err != nil {
+			return Config{}, err
+		}
+		l.file = file
+	}
+	return l.load()
+}
+
+// loader looks settings up in the environment, then in the configuration file.
+type loader struct {
+	path string
+	file map[string]string
+}
+
+// get returns the setting key. A variable set in the environment wins over
+// the file even when it is empty, which clears a value of the file.
+func (l loader) get(key string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	return l.file[key]
+}
+
+func (l loader) load() (Config, error) {
+	secretValues := map[string]string{}
+	var sourced []string
+	sources := l.secretSources()
+	for _, s := range secretSettings {
+		v, ok, err := l.secret(s.name, sources)
+		if err != nil {
+			return Config{}, err
+		}
+		secretValues[s.name] = v
+		if ok {
+			sourced = append(sourced, s.name)
+		}
+	}
+	refresh, err := time.ParseDuration(l.getenv("SECRETS_REFRESH_INTERVAL", "5m"))
+	if err != nil || refresh < 0 {
+		return Config{}, fmt.Errorf("SECRETS_REFRESH_INTERVAL must be a duration such as 5m, or 0 to disable refreshing")
+	}
+	key := secretValues["RESEND_API_KEY"]
+	routes, err := parseRoutes(secretValues["RESEND_ROUTES"])
+	if err != nil {
+		return Config{}, err
+	}
+	// A deployment-wide dry run never contacts Resend, so it needs no API key.
+	dryRun, err := l.getenvBool("DRY_RUN", false)
+	if err != nil {
+		return Config{}, err
+	}
+	provider := strings.ToLower(l.get("PROVIDER"))
+	implicit := provider == ""
+	switch provider {
+	case "":
+		provider = ProviderResend
+		if key == "" && len(routes) == 0 && !dryRun {
+			provider = ProviderCapture
+		}
+	case ProviderResend:
+		if key == "" && len(routes) == 0 && !dryRun {
+			return Config{}, fmt.Errorf("RESEND_API_KEY is required")
+		}
+	case ProviderCapture:
+	case ProviderFile:
+		if l.get("ARCHIVE_DIR") == "" {
+			return Config{}, fmt.Errorf("ARCHIVE_DIR is required when PROVIDER is file")
+		}
+	default:
+		return Config{}, fmt.Errorf("PROVIDER must be resend, capture or file, got %q", provider)
+	}
+	archiveLayout := strings.ToLower(l.getenv("ARCHIVE_LAYOUT", "maildir"))
+	if archiveLayout != "maildir" && archiveLayout != "dated" {
+		return Config{}, fmt.Errorf("ARCHIVE_LAYOUT must be maildir or dated, got %q", archiveLayout)
 	}
-	addr := getenv("SMTP_LISTEN_ADDR", ":2525")
-	timeoutStr := getenv("SEND_TIMEOUT_SECONDS", "15")
-	tSec, err := strconv.Atoi(timeoutStr)
+	archiveGzip, err := l.getenvBool("ARCHIVE_GZIP", false)
+	if err != nil {
+		return Config{}, err
+	}
+	// The capture UI is unauthenticated, so it is only reachable locally
+	// unless an address is configured.
+	httpAddr := ":8025"
+	if provider == ProviderCapture {
+		httpAddr = "127.0.0.1:8025"
+	}
+	trackMax, err := strconv.Atoi(l.getenv("DELIVERY_TRACK_MAX", "10000"))
+	if err != nil || trackMax < 0 {
+		return Config{}, fmt.Errorf("DELIVERY_TRACK_MAX must be a non-negative integer")
+	}
+	suppressionTTL := map[string]time.Duration{}
+	for _, reason := range []string{"bounce", "complaint", "rejected", "manual"} {
+		d, err := l.parseTTL("SUPPRESSION_TTL_" + strings.ToUpper(reason))
+		if err != nil {
+			return Config{}, err
+		}
+		if d > 0 {
+			suppressionTTL[reason] = d
+		}
+	}
+	captureMax, err := strconv.Atoi(l.getenv("CAPTURE_MAX_MESSAGES", "1000"))
+	if err != nil || captureMax < 0 {
+		return Config{}, fmt.Errorf("CAPTURE_MAX_MESSAGES must be a non-negative integer")
+	}
+	users, err := parseUsers("SMTP_USERS", secretValues["SMTP_USERS"])
+	if err != nil {
+		return Config{}, err
+	}
+	apiTokens, err := parseUsers("HTTP_API_TOKENS", secretValues["HTTP_API_TOKENS"])
+	if err != nil {
+		return Config{}, err
+	}
+	addr := l.getenv("SMTP_LISTEN_ADDR", ":2525")
+	socketMode, err := strconv.ParseUint(l.getenv("SOCKET_MODE", "0660"), 8, 32)
+	if err != nil || socketMode > 0o777 {
+		return Config{}, fmt.Errorf("SOCKET_MODE must be an octal permission mode such as 0660")
+	}
+	maxMessage, err := strconv.ParseInt(l.getenv("SMTP_MAX_MESSAGE_BYTES", "52428800"), 10, 64)
+	if err != nil || maxMessage < 0 {
+		return Config{}, fmt.Errorf("SMTP_MAX_MESSAGE_BYTES must be a non-negative integer")
+	}
+	tSec, err := strconv.Atoi(l.getenv("SEND_TIMEOUT_SECONDS", "15"))
 	if err != nil || tSec <= 0 {
-		tSec = 15
+		return Config{}, fmt.Errorf("SEND_TIMEOUT_SECONDS must be a positive integer")
+	}
+	watch, err := time.ParseDuration(l.getenv("CONFIG_WATCH_INTERVAL", "10s"))
+	if err != nil || watch < 0 {
+		return Config{}, fmt.Errorf("CONFIG_WATCH_INTERVAL must be a duration such as 10s, or 0 to disable watching")
+	}
+	genText, err := l.getenvBool("GENERATE_TEXT_FROM_HTML", false)
+	if err != nil {
+		return Config{}, err
+	}
+	trusted := l.getenvList("SMTP_TRUSTED_NETWORKS")
+	requireAuth, err := l.getenvBool("SMTP_REQUIRE_AUTH", len(trusted) > 0)
+	if err != nil {
+		return Config{}, err
+	}
+	rateLimits, err := parseRateLimits(l.get("RATE_LIMITS"))
+	if err != nil {
+		return Config{}, err
+	}
+	maxConns, err := strconv.Atoi(l.getenv("RATE_LIMIT_CONNECTIONS_PER_IP", "0"))
+	if err != nil || maxConns < 0 {
+		return Config{}, fmt.Errorf("RATE_LIMIT_CONNECTIONS_PER_IP must be a non-negative integer")
+	}
+	sendersByUser, err := parseSendersByUser(l.get("ALLOWED_SENDERS_BY_USER"))
+	if err != nil {
+		return Config{}, err
+	}
+	sandboxMode := strings.ToLower(l.get("SANDBOX_MODE"))
+	switch sandboxMode {
+	case "", "reject", "drop":
+	case "redirect":
+		if l.get("SANDBOX_CATCH_ALL") == "" {
+			return Config{}, fmt.Errorf("SANDBOX_CATCH_ALL is required when SANDBOX_MODE is redirect")
+		}
+	default:
+		return Config{}, fmt.Errorf("SANDBOX_MODE must be reject, drop or redirect, got %q", sandboxMode)
 	}
 	return Config{
 		ResendAPIKey:   key,
 		SMTPListerAddr: addr,
 		SendTimeout:    time.Duration(tSec) * time.Second,
+		GenerateText:   genText,
+		Routes:         routes,
+		SMTPUsers:      users,
+		APITokens:      apiTokens,
+
+		SMTPMaxMessageBytes: maxMessage,
+		LMTPListenAddr:      l.get("LMTP_LISTEN_ADDR"),
+		SocketMode:          fs.FileMode(socketMode),
+
+		AllowNetworks:   l.getenvList("SMTP_ALLOW_NETWORKS"),
+		DenyNetworks:    l.getenvList("SMTP_DENY_NETWORKS"),
+		TrustedNetworks: trusted,
+		RequireAuth:     requireAuth,
+		ProxyNetworks:   l.getenvList("PROXY_PROTOCOL_TRUSTED_NETWORKS"),
+
+		RateLimits:         rateLimits,
+		MaxConnsPerIP:      maxConns,
+		RateLimitStateFile: l.get("RATE_LIMIT_STATE_FILE"),
+
+		AllowedSenders:       l.getenvList("ALLOWED_SENDERS"),
+		AllowedSendersByUser: sendersByUser,
+		SenderRewriteFrom:    l.get("SENDER_REWRITE_FROM"),
+
+		SandboxMode:      sandboxMode,
+		SandboxDomains:   l.getenvList("SANDBOX_ALLOWED_DOMAINS"),
+		SandboxAddresses: l.getenvList("SANDBOX_ALLOWED_ADDRESSES"),
+		SandboxPatterns:  l.getenvList("SANDBOX_ALLOWED_PATTERNS"),
+		SandboxCatchAll:  l.get("SANDBOX_CATCH_ALL"),
+
+		Provider:           provider,
+		ProviderImplicit:   implicit,
+		CaptureDir:         l.get("CAPTURE_DIR"),
+		CaptureMaxMessages: captureMax,
+		HTTPListenAddr:     l.getenv("HTTP_LISTEN_ADDR", httpAddr),
+		DryRun:             dryRun,
+		DryRunDir:          l.get("DRY_RUN_DIR"),
+		ArchiveDir:         l.get("ARCHIVE_DIR"),
+		ArchiveLayout:      archiveLayout,
+		ArchiveGzip:        archiveGzip,
+		WebhookSecret:      secretValues["RESEND_WEBHOOK_SECRET"],
+		DeliveryTrackMax:   trackMax,
+		SuppressionFile:    l.get("SUPPRESSION_FILE"),
+		SuppressionTTL:     suppressionTTL,
+		AdminToken:         secretValues["ADMIN_API_TOKEN"],
+		DSNFrom:            l.get("DSN_FROM"),
+		DSNReportingMTA:    l.getenv("DSN_REPORTING_MTA", hostname()),
+		ConfigFile:         l.path,
+		WatchInterval:      watch,
+		Secrets:            sourced,
+		SecretsRefresh:     refresh,
 	}, nil
 }
+
+// reloadable lists the Config fields a running gateway applies when the
+// configuration is reloaded; the others take effect after a restart.
+var reloadable = map[string]bool{
+	"ResendAPIKey":         true,
+	"Routes":               true,
+	"Secrets":              true,
+	"SendTimeout":          true,
+	"GenerateText":         true,
+	"RateLimits":           true,
+	"AllowedSenders":       true,
+	"AllowedSendersByUser": true,
+	"SenderRewriteFrom":    true,
+	"SandboxMode":          true,
+	"SandboxDomains":       true,
+	"SandboxAddresses":     true,
+	"SandboxPatterns":      true,
+	"SandboxCatchAll":      true,
+	"SMTPUsers":            true,
+}
+
+// reloadableWhileSet lists the Config fields a reload applies as long as
+// they stay set: setting or clearing one adds or removes an HTTP endpoint,
+// which takes effect after a restart.
+var reloadableWhileSet = map[string]bool{
+	"WebhookSecret": true,
+	"APITokens":     true,
+	"AdminToken":    true,
+}
+
+// RestartRequired returns the names of the fields that differ between old
+// and new but are not applied by a reload.
+func RestartRequired(old, new Config) []string {
+	var changed []string
+	o, n := reflect.ValueOf(old), reflect.ValueOf(new)
+	for i := 0; i < o.NumField(); i++ {
+		name := o.Type().Field(i).Name
+		if reloadable[name] || (reloadableWhileSet[name] && o.Field(i).Len() > 0 && n.Field(i).Len() > 0) {
+			continue
+		}
+		if !reflect.DeepEqual(o.Field(i).Interface(), n.Field(i).Interface()) {
+			changed = append(changed, name)
+		}
+	}
+	return changed
+}
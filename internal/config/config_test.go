@@ -0,0 +1,182 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRoutes(t *testing.T) {
+	routes, err := parseRoutes("user:alice=re_1, mail-from:acme.com=re_2,from:beta.io=re_3,")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []Route{
+		{Match: "user", Value: "alice", APIKey: "re_1"},
+		{Match: "mail-from", Value: "acme.com", APIKey: "re_2"},
+		{Match: "from", Value: "beta.io", APIKey: "re_3"},
+	}
+	if len(routes) != len(want) {
+		t.Fatalf("expected %d routes, got %v", len(want), routes)
+	}
+	for i := range want {
+		if routes[i] != want[i] {
+			t.Errorf("route %d: expected %+v, got %+v", i, want[i], routes[i])
+		}
+	}
+
+	for _, bad := range []string{"alice=re_1", "user:alice", "domain:acme.com=re_1", "user:=re_1"} {
+		if _, err := parseRoutes(bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestLoad_RoutesWithoutDefaultKey(t *testing.T) {
+	t.Setenv("RESEND_API_KEY", "")
+	t.Setenv("RESEND_ROUTES", "user:alice=re_1")
+	t.Setenv("SMTP_USERS", "alice:secret")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.Routes) != 1 || cfg.SMTPUsers["alice"] != "secret" {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+}
+
+func TestLoad_APITokens(t *testing.T) {
+	t.Setenv("HTTP_API_TOKENS", "billing:tok_1, crm:tok_2")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.APITokens) != 2 || cfg.APITokens["crm"] != "tok_2" {
+		t.Errorf("unexpected tokens %v", cfg.APITokens)
+	}
+
+	t.Setenv("HTTP_API_TOKENS", "billing")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "HTTP_API_TOKENS") {
+		t.Errorf("expected HTTP_API_TOKENS error, got %v", err)
+	}
+}
+
+func TestParseRateLimits(t *testing.T) {
+	limits, err := parseRateLimits("user:minute=60, domain:day=5000,ip:hour=500")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []RateLimit{
+		{Scope: "user", Window: time.Minute, Limit: 60},
+		{Scope: "domain", Window: 24 * time.Hour, Limit: 5000},
+		{Scope: "ip", Window: time.Hour, Limit: 500},
+	}
+	if len(limits) != len(want) {
+		t.Fatalf("expected %d limits, got %v", len(want), limits)
+	}
+	for i := range want {
+		if limits[i] != want[i] {
+			t.Errorf("limit %d: expected %+v, got %+v", i, want[i], limits[i])
+		}
+	}
+	for _, bad := range []string{"user=1", "tenant:day=1", "user:week=1", "user:day=0"} {
+		if _, err := parseRateLimits(bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestLoad_Provider(t *testing.T) {
+	tests := []struct {
+		name, provider, key string
+		want                string
+		wantErr             bool
+	}{
+		{name: "capture without key", want: ProviderCapture},
+		{name: "resend with key", key: "re_1", want: ProviderResend},
+		{name: "explicit capture with key", provider: "capture", key: "re_1", want: ProviderCapture},
+		{name: "explicit resend without key", provider: "resend", wantErr: true},
+		{name: "file without archive dir", provider: "file", wantErr: true},
+		{name: "unknown", provider: "smtp", key: "re_1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("RESEND_API_KEY", tt.key)
+			t.Setenv("RESEND_ROUTES", "")
+			t.Setenv("PROVIDER", tt.provider)
+
+			cfg, err := Load()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got provider %q", cfg.Provider)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if cfg.Provider != tt.want {
+				t.Errorf("provider = %q, want %q", cfg.Provider, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoad_CaptureListensLocally(t *testing.T) {
+	t.Setenv("RESEND_API_KEY", "")
+	t.Setenv("RESEND_ROUTES", "")
+	t.Setenv("PROVIDER", "")
+	t.Setenv("HTTP_LISTEN_ADDR", "")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.ProviderImplicit || cfg.HTTPListenAddr != "127.0.0.1:8025" {
+		t.Errorf("implicit=%v addr=%q", cfg.ProviderImplicit, cfg.HTTPListenAddr)
+	}
+
+	t.Setenv("RESEND_API_KEY", "re_1")
+	if cfg, err = Load(); err != nil || cfg.HTTPListenAddr != ":8025" {
+		t.Errorf("resend provider should listen on all interfaces, got %q, %v", cfg.HTTPListenAddr, err)
+	}
+	t.Setenv("RESEND_API_KEY", "")
+	t.Setenv("PROVIDER", "capture")
+	t.Setenv("HTTP_LISTEN_ADDR", ":9000")
+	if cfg, err = Load(); err != nil || cfg.ProviderImplicit || cfg.HTTPListenAddr != ":9000" {
+		t.Errorf("explicit capture: implicit=%v addr=%q, %v", cfg.ProviderImplicit, cfg.HTTPListenAddr, err)
+	}
+}
+
+func TestLoad_DryRunWithoutKey(t *testing.T) {
+	t.Setenv("RESEND_API_KEY", "")
+	t.Setenv("RESEND_ROUTES", "")
+	t.Setenv("PROVIDER", "")
+	t.Setenv("DRY_RUN", "true")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Provider != ProviderResend || !cfg.DryRun {
+		t.Errorf("unexpected config provider=%q dry_run=%v", cfg.Provider, cfg.DryRun)
+	}
+}
+
+func TestParseTTL(t *testing.T) {
+	cases := map[string]time.Duration{"": 0, "30d": 30 * 24 * time.Hour, "90m": 90 * time.Minute}
+	for in, want := range cases {
+		t.Setenv("SUPPRESSION_TTL_BOUNCE", in)
+		got, err := (loader{}).parseTTL("SUPPRESSION_TTL_BOUNCE")
+		if err != nil || got != want {
+			t.Errorf("parseTTL(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"soon", "-1d", "-5m"} {
+		t.Setenv("SUPPRESSION_TTL_BOUNCE", in)
+		if _, err := (loader{}).parseTTL("SUPPRESSION_TTL_BOUNCE"); err == nil {
+			t.Errorf("parseTTL(%q): expected error", in)
+		}
+	}
+}
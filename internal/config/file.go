@@ -0,0 +1,252 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// shape is how a setting is written in a configuration file. Every setting
+// also accepts a string in the syntax of its environment variable.
+type shape int
+
+const (
+	scalar      shape = iota // a string, number or boolean
+	list                     // a sequence, joined with commas
+	credentials              // a mapping of name to secret, written "name:secret,..."
+	assignments              // a mapping written "key=value,..." (routes, rate limits)
+	senderLists              // a mapping of user to a sequence, written "user=a|b;..."
+)
+
+// settings is the schema of the configuration file: every setting by the
+// name of its environment variable.
+var settings = map[string]shape{
+	"RESEND_API_KEY":                  scalar,
+	"RESEND_ROUTES":                   assignments,
+	"RESEND_WEBHOOK_SECRET":           scalar,
+	"PROVIDER":                        scalar,
+	"DRY_RUN":                         scalar,
+	"DRY_RUN_DIR":                     scalar,
+	"SEND_TIMEOUT_SECONDS":            scalar,
+	"GENERATE_TEXT_FROM_HTML":         scalar,
+	"SMTP_LISTEN_ADDR":                list,
+	"LMTP_LISTEN_ADDR":                list,
+	"SOCKET_MODE":                     scalar,
+	"SMTP_MAX_MESSAGE_BYTES":          scalar,
+	"SMTP_USERS":                      credentials,
+	"SMTP_ALLOW_NETWORKS":             list,
+	"SMTP_DENY_NETWORKS":              list,
+	"SMTP_TRUSTED_NETWORKS":           list,
+	"SMTP_REQUIRE_AUTH":               scalar,
+	"PROXY_PROTOCOL_TRUSTED_NETWORKS": list,
+	"RATE_LIMITS":                     assignments,
+	"RATE_LIMIT_CONNECTIONS_PER_IP":   scalar,
+	"RATE_LIMIT_STATE_FILE":           scalar,
+	"ALLOWED_SENDERS":                 list,
+	"ALLOWED_SENDERS_BY_USER":         senderLists,
+	"SENDER_REWRITE_FROM":             scalar,
+	"SANDBOX_MODE":                    scalar,
+	"SANDBOX_ALLOWED_DOMAINS":         list,
+	"SANDBOX_ALLOWED_ADDRESSES":       list,
+	"SANDBOX_ALLOWED_PATTERNS":        list,
+	"SANDBOX_CATCH_ALL":               scalar,
+	"CAPTURE_DIR":                     scalar,
+	"CAPTURE_MAX_MESSAGES":            scalar,
+	"HTTP_LISTEN_ADDR":                scalar,
+	"HTTP_API_TOKENS":                 credentials,
+	"ARCHIVE_DIR":                     scalar,
+	"ARCHIVE_LAYOUT":                  scalar,
+	"ARCHIVE_GZIP":                    scalar,
+	"DELIVERY_TRACK_MAX":              scalar,
+	"SUPPRESSION_FILE":                scalar,
+	"SUPPRESSION_TTL_BOUNCE":          scalar,
+	"SUPPRESSION_TTL_COMPLAINT":       scalar,
+	"SUPPRESSION_TTL_REJECTED":        scalar,
+	"SUPPRESSION_TTL_MANUAL":          scalar,
+	"ADMIN_API_TOKEN":                 scalar,
+	"DSN_FROM":                        scalar,
+	"DSN_REPORTING_MTA":               scalar,
+	"CONFIG_WATCH_INTERVAL":           scalar,
+	"SECRETS_DIR":                     scalar,
+	"SECRETS_REFRESH_INTERVAL":        scalar,
+	"VAULT_ADDR":                      scalar,
+	"VAULT_TOKEN":                     scalar,
+	"VAULT_TOKEN_FILE":                scalar,
+	"VAULT_NAMESPACE":                 scalar,
+	"RESEND_API_KEY_FILE":             scalar,
+	"RESEND_API_KEY_VAULT":            scalar,
+	"RESEND_ROUTES_FILE":              scalar,
+	"RESEND_ROUTES_VAULT":             scalar,
+	"RESEND_WEBHOOK_SECRET_FILE":      scalar,
+	"RESEND_WEBHOOK_SECRET_VAULT":     scalar,
+	"SMTP_USERS_FILE":                 scalar,
+	"SMTP_USERS_VAULT":                scalar,
+	"HTTP_API_TOKENS_FILE":            scalar,
+	"HTTP_API_TOKENS_VAULT":           scalar,
+	"ADMIN_API_TOKEN_FILE":            scalar,
+	"ADMIN_API_TOKEN_VAULT":           scalar,
+}
+
+// readFile reads a YAML configuration file into settings in the syntax of
+// their environment variables. A setting is named by its variable, in any
+// case, or by nesting its parts, so that
+//
+//	smtp:
+//	  listen_addr: [":2525", "unix:/run/gateway/smtp.sock"]
+//	  users:
+//	    alice: s3cret
+//	rate_limits:
+//	  user:minute: 60
+//
+// sets SMTP_LISTEN_ADDR, SMTP_USERS and RATE_LIMITS. Unknown settings,
+// values of the wrong shape and settings given twice are errors naming the
+// file and line.
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("CONFIG_FILE: %w", err)
+	}
+	var doc yaml.Node
+	if err := yaml.NewDecoder(bytes.NewReader(data)).Decode(&doc); err != nil {
+		if errors.Is(err, io.EOF) {
+			return map[string]string{}, nil
+		}
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s:%d: expected a mapping of settings", path, root.Line)
+	}
+	f := fileReader{path: path, values: map[string]string{}}
+	if err := f.section(root, ""); err != nil {
+		return nil, err
+	}
+	return f.values, nil
+}
+
+type fileReader struct {
+	path   string
+	values map[string]string
+}
+
+// section reads the settings of a mapping whose keys follow prefix.
+func (f fileReader) section(node *yaml.Node, prefix string) error {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], resolve(node.Content[i+1])
+		name := strings.ToUpper(strings.ReplaceAll(key.Value, "-", "_"))
+		if prefix != "" {
+			name = prefix + "_" + name
+		}
+		sh, ok := settings[name]
+		switch {
+		case ok:
+			v, err := format(value, sh)
+			if err != nil {
+				return fmt.Errorf("%s:%d: %s: %w", f.path, value.Line, name, err)
+			}
+			if _, dup := f.values[name]; dup {
+				return fmt.Errorf("%s:%d: %s is set more than once", f.path, key.Line, name)
+			}
+			f.values[name] = v
+		case value.Kind == yaml.MappingNode && isSection(name):
+			if err := f.section(value, name); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("%s:%d: unknown setting %q", f.path, key.Line, key.Value)
+		}
+	}
+	return nil
+}
+
+// isSection reports whether some setting is named prefix followed by more parts.
+func isSection(prefix string) bool {
+	for name := range settings {
+		if strings.HasPrefix(name, prefix+"_") {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve follows YAML aliases to the node they refer to.
+func resolve(n *yaml.Node) *yaml.Node {
+	for n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	return n
+}
+
+// format converts a setting's value to the syntax of its environment variable.
+func format(n *yaml.Node, sh shape) (string, error) {
+	if n.Kind == yaml.ScalarNode {
+		if n.Tag == "!!null" {
+			return "", nil
+		}
+		return n.Value, nil
+	}
+	switch {
+	case sh == list && n.Kind == yaml.SequenceNode:
+		return join(n, ",")
+	case sh != scalar && sh != list && n.Kind == yaml.MappingNode:
+		kv, sep := map[shape]string{credentials: ":", assignments: "=", senderLists: "="}[sh], ","
+		if sh == senderLists {
+			sep = ";"
+		}
+		entries := make([]string, 0, len(n.Content)/2)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i], resolve(n.Content[i+1])
+			v, err := entry(value, sh)
+			if err != nil {
+				return "", fmt.Errorf("%s: %w", key.Value, err)
+			}
+			if strings.ContainsAny(key.Value, kv+sep) {
+				return "", fmt.Errorf("key %q must not contain %q or %q", key.Value, kv, sep)
+			}
+			if strings.Contains(v, sep) {
+				return "", fmt.Errorf("%s: value must not contain %q", key.Value, sep)
+			}
+			entries = append(entries, key.Value+kv+v)
+		}
+		return strings.Join(entries, sep), nil
+	}
+	return "", fmt.Errorf("expected %s", map[shape]string{
+		scalar:      "a single value",
+		list:        "a value or a list of values",
+		credentials: "a mapping of names to secrets",
+		assignments: "a mapping",
+		senderLists: "a mapping of users to lists of senders",
+	}[sh])
+}
+
+// entry formats the value of one mapping entry.
+func entry(n *yaml.Node, sh shape) (string, error) {
+	switch {
+	case n.Kind == yaml.ScalarNode:
+		return n.Value, nil
+	case sh == senderLists && n.Kind == yaml.SequenceNode:
+		return join(n, "|")
+	}
+	return "", errors.New("expected a single value")
+}
+
+// join joins the scalar items of a sequence with sep.
+func join(n *yaml.Node, sep string) (string, error) {
+	items := make([]string, 0, len(n.Content))
+	for _, item := range n.Content {
+		item = resolve(item)
+		if item.Kind != yaml.ScalarNode {
+			return "", fmt.Errorf("line %d: expected a single value in the list", item.Line)
+		}
+		if strings.Contains(item.Value, sep) {
+			return "", fmt.Errorf("line %d: %q must not contain %q", item.Line, item.Value, sep)
+		}
+		items = append(items, item.Value)
+	}
+	return strings.Join(items, sep), nil
+}
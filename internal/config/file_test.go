@@ -0,0 +1,129 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "gateway.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadFile(t *testing.T) {
+	path := writeFile(t, `
+resend:
+  api_key: re_file
+  routes:
+    user:alice: re_alice
+smtp:
+  listen_addr: [":2525", "unix:/run/gw.sock"]
+  users:
+    alice: "s3cret:with:colons"
+  require-auth: true
+rate_limits:
+  user:minute: 60
+  ip:hour: 500
+allowed_senders_by_user:
+  alice: [acme.com, noreply@beta.io]
+  bob: bob.dev
+SEND_TIMEOUT_SECONDS: 30
+dsn_from: ~
+`)
+	got, err := readFile(path)
+	if err != nil {
+		t.Fatalf("readFile: %v", err)
+	}
+	want := map[string]string{
+		"RESEND_API_KEY":          "re_file",
+		"RESEND_ROUTES":           "user:alice=re_alice",
+		"SMTP_LISTEN_ADDR":        ":2525,unix:/run/gw.sock",
+		"SMTP_USERS":              "alice:s3cret:with:colons",
+		"SMTP_REQUIRE_AUTH":       "true",
+		"RATE_LIMITS":             "user:minute=60,ip:hour=500",
+		"ALLOWED_SENDERS_BY_USER": "alice=acme.com|noreply@beta.io;bob=bob.dev",
+		"SEND_TIMEOUT_SECONDS":    "30",
+		"DSN_FROM":                "",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v\nwant %v", got, want)
+	}
+}
+
+func TestReadFile_Errors(t *testing.T) {
+	cases := map[string]string{
+		"smtp:\n  lisen_addr: :2525\n":                    `:2: unknown setting "lisen_addr"`,
+		"smtp_listen_addr: {a: b}\n":                      ":1: SMTP_LISTEN_ADDR: expected a value or a list of values",
+		"resend_api_key: [a, b]\n":                        "RESEND_API_KEY: expected a single value",
+		"smtp_users: {alice: [a]}\n":                      "SMTP_USERS: alice: expected a single value",
+		"allowed_senders: [a.com, 'b.com,c.com']\n":       `must not contain ","`,
+		"dsn_from: a@x\ndsn:\n  from: b@x\n":              ":3: DSN_FROM is set more than once",
+		"- a\n- b\n":                                      ":1: expected a mapping of settings",
+		"smtp: [a]\n":                                     `unknown setting "smtp"`,
+		"smtp:\n  listen_addr: :2525\n   bad indent: x\n": "gateway.yaml",
+	}
+	for content, want := range cases {
+		_, err := readFile(writeFile(t, content))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: got error %v, want it to contain %q", content, err, want)
+		}
+	}
+}
+
+func TestLoad_ConfigFile(t *testing.T) {
+	path := writeFile(t, "provider: capture\nsend_timeout_seconds: 30\nallowed_senders: [example.com]\nsmtp:\n  listen_addr: \":2626\"\n")
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("SMTP_LISTEN_ADDR", ":2727")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.SendTimeout != 30*time.Second || cfg.AllowedSenders[0] != "example.com" || cfg.ConfigFile != path {
+		t.Errorf("file settings not applied: %+v", cfg)
+	}
+	if cfg.SMTPListerAddr != ":2727" {
+		t.Errorf("environment should override the file, got %q", cfg.SMTPListerAddr)
+	}
+	t.Setenv("ALLOWED_SENDERS", "")
+	if cfg, err = Load(); err != nil || len(cfg.AllowedSenders) != 0 {
+		t.Errorf("an empty variable should clear the file value, got %v, %v", cfg.AllowedSenders, err)
+	}
+
+	t.Setenv("CONFIG_FILE", writeFile(t, "send_timeout_seconds: soon\n"))
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "SEND_TIMEOUT_SECONDS") {
+		t.Errorf("expected SEND_TIMEOUT_SECONDS error, got %v", err)
+	}
+}
+
+func TestRestartRequired(t *testing.T) {
+	old := Config{SMTPListerAddr: ":2525", AllowedSenders: []string{"a.com"}, SendTimeout: time.Second}
+	next := old
+	next.AllowedSenders = []string{"b.com"}
+	next.SendTimeout = 2 * time.Second
+	if got := RestartRequired(old, next); len(got) != 0 {
+		t.Errorf("reloadable changes reported: %v", got)
+	}
+	next.SMTPListerAddr = ":2626"
+	if got := RestartRequired(old, next); !reflect.DeepEqual(got, []string{"SMTPListerAddr"}) {
+		t.Errorf("got %v", got)
+	}
+
+	old = Config{SMTPUsers: map[string]string{"alice": "a"}, APITokens: map[string]string{"ci": "t1"}, AdminToken: "adm1"}
+	next = Config{SMTPUsers: map[string]string{"alice": "b"}, APITokens: map[string]string{"ci": "t2"}, AdminToken: "adm2"}
+	if got := RestartRequired(old, next); len(got) != 0 {
+		t.Errorf("rotated credentials reported: %v", got)
+	}
+	next.AdminToken, next.WebhookSecret = "", "whsec_b2xk"
+	if got := RestartRequired(old, next); !reflect.DeepEqual(got, []string{"WebhookSecret", "AdminToken"}) {
+		t.Errorf("enabling or disabling an endpoint should need a restart, got %v", got)
+	}
+}
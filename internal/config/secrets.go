@@ -0,0 +1,106 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"reflect"
+	"strings"
+
+	"github.com/igorrius/resend-railway-gateway/internal/secrets"
+)
+
+// secretSettings are the settings holding credentials, with their Config
+// fields. Besides their own variable, each can be read from a secret source
+// (see loader.secret).
+var secretSettings = []struct{ name, field string }{
+	{"RESEND_API_KEY", "ResendAPIKey"},
+	{"RESEND_ROUTES", "Routes"},
+	{"RESEND_WEBHOOK_SECRET", "WebhookSecret"},
+	{"SMTP_USERS", "SMTPUsers"},
+	{"HTTP_API_TOKENS", "APITokens"},
+	{"ADMIN_API_TOKEN", "AdminToken"},
+}
+
+// secretSuffixes name the secret sources: a setting NAME is read from the
+// source of the NAME_<suffix> variable that is set.
+var secretSuffixes = []string{"FILE", "VAULT"}
+
+// suffixed returns the names of the variables referring to key in a secret source.
+func suffixed(key string) []string {
+	names := make([]string, 0, len(secretSuffixes))
+	for _, suffix := range secretSuffixes {
+		names = append(names, key+"_"+suffix)
+	}
+	return names
+}
+
+// secretSources returns the configured secret sources by suffix.
+func (l loader) secretSources() map[string]secrets.Source {
+	sources := map[string]secrets.Source{"FILE": secrets.Files{}}
+	if addr := l.get("VAULT_ADDR"); addr != "" {
+		opts := []secrets.VaultOption{secrets.WithToken(l.get("VAULT_TOKEN")), secrets.WithNamespace(l.get("VAULT_NAMESPACE"))}
+		if path := l.get("VAULT_TOKEN_FILE"); path != "" {
+			opts = append(opts, secrets.WithTokenFile(path))
+		}
+		sources["VAULT"] = secrets.NewVault(addr, opts...)
+	}
+	return sources
+}
+
+// secret looks up the secret setting key: given directly, then from a
+// secret source named by a suffixed variable (RESEND_API_KEY_FILE,
+// RESEND_API_KEY_VAULT) and finally from a file named after the setting, in
+// upper or lower case, in SECRETS_DIR as Docker and Kubernetes mount
+// secrets. sourced reports whether the value came from a secret source.
+func (l loader) secret(key string, sources map[string]secrets.Source) (value string, sourced bool, err error) {
+	ctx := context.Background()
+	var set []string
+	for _, name := range append([]string{key}, suffixed(key)...) {
+		if l.get(name) != "" {
+			set = append(set, name)
+		}
+	}
+	switch {
+	case len(set) > 1:
+		return "", false, fmt.Errorf("%s are mutually exclusive", strings.Join(set, " and "))
+	case len(set) == 1 && set[0] == key:
+		return l.get(key), false, nil
+	case len(set) == 1:
+		suffix := strings.TrimPrefix(set[0], key+"_")
+		src, ok := sources[suffix]
+		if !ok {
+			return "", false, fmt.Errorf("%s: %s_ADDR is required", set[0], suffix)
+		}
+		if value, err = src.Fetch(ctx, l.get(set[0])); err != nil {
+			return "", false, fmt.Errorf("%s: %w", set[0], err)
+		}
+		return value, true, nil
+	}
+	if dir := l.get("SECRETS_DIR"); dir != "" {
+		for _, name := range []string{key, strings.ToLower(key)} {
+			value, err = (secrets.Files{Dir: dir}).Fetch(ctx, name)
+			if err == nil {
+				return value, true, nil
+			}
+			if !errors.Is(err, fs.ErrNotExist) {
+				return "", false, fmt.Errorf("SECRETS_DIR: %w", err)
+			}
+		}
+	}
+	return "", false, nil
+}
+
+// SecretsChanged returns the names of the secret settings whose values
+// differ between old and new, such as a rotated RESEND_API_KEY.
+func SecretsChanged(old, new Config) []string {
+	var changed []string
+	o, n := reflect.ValueOf(old), reflect.ValueOf(new)
+	for _, s := range secretSettings {
+		if !reflect.DeepEqual(o.FieldByName(s.field).Interface(), n.FieldByName(s.field).Interface()) {
+			changed = append(changed, s.name)
+		}
+	}
+	return changed
+}
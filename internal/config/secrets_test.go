@@ -0,0 +1,82 @@
+package config
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoad_SecretFiles(t *testing.T) {
+	t.Setenv("RESEND_API_KEY", "")
+	t.Setenv("RESEND_API_KEY_FILE", writeFile(t, "re_from_file\n"))
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "smtp_users"), []byte("alice:s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SECRETS_DIR", dir)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.ResendAPIKey != "re_from_file" || cfg.SMTPUsers["alice"] != "s3cret" || cfg.Provider != ProviderResend {
+		t.Errorf("secrets not read: %+v", cfg)
+	}
+	if !reflect.DeepEqual(cfg.Secrets, []string{"RESEND_API_KEY", "SMTP_USERS"}) {
+		t.Errorf("Secrets = %v", cfg.Secrets)
+	}
+
+	t.Setenv("RESEND_API_KEY", "re_plain")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "RESEND_API_KEY and RESEND_API_KEY_FILE are mutually exclusive") {
+		t.Errorf("expected mutually exclusive error, got %v", err)
+	}
+	t.Setenv("RESEND_API_KEY", "")
+	t.Setenv("RESEND_API_KEY_FILE", filepath.Join(dir, "missing"))
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "RESEND_API_KEY_FILE") {
+		t.Errorf("expected RESEND_API_KEY_FILE error, got %v", err)
+	}
+}
+
+func TestLoad_SecretVault(t *testing.T) {
+	key := "re_v1"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/secret/data/gateway" || r.Header.Get("X-Vault-Token") != "s.token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"data":{"data":{"resend_api_key":"` + key + `","admin":"adm"},"metadata":{}}}`))
+	}))
+	defer srv.Close()
+	t.Setenv("RESEND_API_KEY", "")
+	t.Setenv("RESEND_API_KEY_VAULT", "secret/data/gateway#resend_api_key")
+	t.Setenv("ADMIN_API_TOKEN_VAULT", "secret/data/gateway#admin")
+	t.Setenv("VAULT_TOKEN", "s.token")
+
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "VAULT_ADDR is required") {
+		t.Errorf("expected VAULT_ADDR error, got %v", err)
+	}
+	t.Setenv("VAULT_ADDR", srv.URL)
+	old, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if old.ResendAPIKey != "re_v1" || old.AdminToken != "adm" {
+		t.Errorf("secrets not read: %+v", old)
+	}
+
+	key = "re_v2"
+	next, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := SecretsChanged(old, next); !reflect.DeepEqual(got, []string{"RESEND_API_KEY"}) {
+		t.Errorf("SecretsChanged = %v", got)
+	}
+	if got := RestartRequired(old, next); len(got) != 0 {
+		t.Errorf("rotating the API key should not require a restart: %v", got)
+	}
+}
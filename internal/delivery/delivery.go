@@ -0,0 +1,248 @@
+// Package delivery tracks what happened to relayed emails after the provider
+// accepted them, based on events reported by provider webhooks.
+package delivery
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/igorrius/resend-railway-gateway/internal/domain"
+	"github.com/igorrius/resend-railway-gateway/internal/mimebuild"
+)
+
+// Status is the delivery state of an email.
+type Status string
+
+// Statuses reported by Resend webhooks, plus StatusSent for emails the
+// provider accepted but has not reported on yet.
+const (
+	StatusSent            Status = "sent"
+	StatusDelivered       Status = "delivered"
+	StatusDeliveryDelayed Status = "delivery_delayed"
+	StatusBounced         Status = "bounced"
+	StatusComplained      Status = "complained"
+	StatusFailed          Status = "failed"
+	StatusOpened          Status = "opened"
+	StatusClicked         Status = "clicked"
+)
+
+// Event is a single provider report about an email.
+type Event struct {
+	// Type is the provider event type, e.g. "email.bounced".
+	Type      string    `json:"type"`
+	EmailID   string    `json:"email_id"`
+	CreatedAt time.Time `json:"created_at"`
+	// Recipients the event applies to.
+	To []string `json:"to,omitempty"`
+	// Detail is a human readable explanation, e.g. the bounce message.
+	Detail string `json:"detail,omitempty"`
+	// BounceType is "Permanent", "Transient" or "Undetermined" for bounces.
+	BounceType string `json:"bounce_type,omitempty"`
+}
+
+// Status maps the event type to a Status, stripping the "email." prefix.
+func (e Event) Status() Status {
+	return Status(strings.TrimPrefix(e.Type, "email."))
+}
+
+// Record is everything known about one relayed email.
+type Record struct {
+	ID        string    `json:"id"`
+	From      string    `json:"from"`
+	To        []string  `json:"to"`
+	Subject   string    `json:"subject"`
+	MailFrom  string    `json:"mail_from,omitempty"`
+	User      string    `json:"user,omitempty"`
+	SentAt    time.Time `json:"sent_at,omitzero"`
+	Status    Status    `json:"status"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Events    []Event   `json:"events,omitempty"`
+	// Header is the header block of the original message, kept for
+	// delivery status notifications.
+	Header []byte `json:"-"`
+	// Message is the full original message, kept only when the client asked
+	// for it to be returned in notifications (RET=FULL) and it is small
+	// enough; otherwise notifications return the headers, as RFC 3461 allows.
+	Message []byte `json:"-"`
+	// Envelope holds the DSN parameters the message was submitted with.
+	Envelope domain.Envelope `json:"-"`
+}
+
+// Limits on the original messages kept for notifications, so that clients
+// asking for RET=FULL cannot make the tracker hold arbitrary amounts of mail.
+const (
+	// maxHeaderBytes bounds the header block kept per record.
+	maxHeaderBytes = 64 << 10
+	// maxMessageBytes is the largest message kept for RET=FULL.
+	maxMessageBytes = 256 << 10
+	// maxRetainedBytes bounds the messages kept by a tracker; the oldest
+	// are dropped first.
+	maxRetainedBytes = 64 << 20
+)
+
+// Tracker keeps the most recent records in memory. It is safe for concurrent use.
+type Tracker struct {
+	max         int
+	maxMessage  int
+	maxRetained int
+	now         func() time.Time
+
+	mu       sync.Mutex
+	records  map[string]*Record
+	order    []string // IDs, oldest first
+	retained int      // bytes of all Record.Message
+}
+
+// NewTracker creates a Tracker remembering at most max emails; zero means unbounded.
+func NewTracker(max int) *Tracker {
+	return &Tracker{max: max, maxMessage: maxMessageBytes, maxRetained: maxRetainedBytes, now: time.Now, records: map[string]*Record{}}
+}
+
+// Sent records that the provider accepted email under id.
+func (t *Tracker) Sent(id string, email domain.Email) {
+	now := t.now().UTC()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r := t.record(id)
+	r.From = email.From.String()
+	r.To = domain.AddressStrings(email.To)
+	r.Subject = email.Subject
+	r.MailFrom = email.Envelope.MailFrom
+	r.User = email.Envelope.User
+	r.SentAt = now
+	r.Header = headerBlock(email, now)
+	r.Envelope = email.Envelope
+	if email.Envelope.Return == domain.DSNReturnFull && len(email.Raw) <= t.maxMessage {
+		msg := email.Raw
+		if len(msg) == 0 {
+			msg = mimebuild.Build(email, now)
+		}
+		t.retain(r, msg)
+	}
+	if r.Status == "" {
+		r.Status, r.UpdatedAt = StatusSent, now
+	}
+}
+
+// Apply adds ev to the record of its email and returns the updated record.
+// known reports whether the email was relayed through this tracker; events
+// for unknown emails are recorded too, so that their status can be queried.
+// The status follows the newest event, so out-of-order delivery of webhooks
+// does not roll it back.
+func (t *Tracker) Apply(ev Event) (rec Record, known bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, known = t.records[ev.EmailID]
+	r := t.record(ev.EmailID)
+	if len(r.To) == 0 {
+		r.To = ev.To
+	}
+	r.Events = append(r.Events, ev)
+	if ev.CreatedAt.IsZero() || !ev.CreatedAt.Before(r.UpdatedAt) || r.Status == StatusSent {
+		r.Status = ev.Status()
+		r.UpdatedAt = ev.CreatedAt
+		if r.UpdatedAt.IsZero() {
+			r.UpdatedAt = t.now().UTC()
+		}
+	}
+	return r.clone(), known
+}
+
+// Get returns the record for id.
+func (t *Tracker) Get(id string) (Record, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r, ok := t.records[id]
+	if !ok {
+		return Record{}, false
+	}
+	return r.clone(), true
+}
+
+// record returns the record for id, creating it and evicting the oldest
+// record when the tracker is full. t.mu must be held.
+func (t *Tracker) record(id string) *Record {
+	if r, ok := t.records[id]; ok {
+		return r
+	}
+	r := &Record{ID: id}
+	t.records[id] = r
+	t.order = append(t.order, id)
+	if t.max > 0 && len(t.order) > t.max {
+		t.retained -= len(t.records[t.order[0]].Message)
+		delete(t.records, t.order[0])
+		t.order = t.order[1:]
+	}
+	return r
+}
+
+// retain keeps msg as the message of r unless it is too large, dropping the
+// messages of the oldest records while the retained total exceeds the
+// budget. t.mu must be held.
+func (t *Tracker) retain(r *Record, msg []byte) {
+	t.retained -= len(r.Message)
+	r.Message = nil
+	if len(msg) > t.maxMessage {
+		return
+	}
+	r.Message = msg
+	t.retained += len(msg)
+	for _, id := range t.order {
+		if t.retained <= t.maxRetained {
+			break
+		}
+		old := t.records[id]
+		t.retained -= len(old.Message)
+		old.Message = nil
+	}
+}
+
+// headerBlock returns the header section of the original message, or
+// renders one when the email did not arrive as MIME. Header sections longer
+// than maxHeaderBytes are cut after the last complete line that fits.
+func headerBlock(email domain.Email, now time.Time) []byte {
+	if raw := email.Raw; len(raw) > 0 {
+		for _, sep := range []string{"\r\n\r\n", "\n\n"} {
+			if i := bytes.Index(raw, []byte(sep)); i >= 0 {
+				raw = raw[:i+len(sep)/2]
+				break
+			}
+		}
+		if len(raw) > maxHeaderBytes {
+			raw = raw[:maxHeaderBytes]
+			if i := bytes.LastIndexByte(raw, '\n'); i >= 0 {
+				raw = raw[:i+1]
+			}
+		}
+		return bytes.Clone(raw)
+	}
+	var b bytes.Buffer
+	mimebuild.WriteHeader(&b, mimebuild.Header(email, now))
+	return b.Bytes()
+}
+
+func (r *Record) clone() Record {
+	c := *r
+	c.To = append([]string(nil), r.To...)
+	c.Events = append([]Event(nil), r.Events...)
+	return c
+}
+
+// Recorder wraps a sender and records every accepted email in Tracker.
+type Recorder struct {
+	Sender  domain.OutboundEmailSender
+	Tracker *Tracker
+}
+
+// Send delivers the email and records its provider ID.
+func (r Recorder) Send(email domain.Email) (string, error) {
+	id, err := r.Sender.Send(email)
+	if err == nil && id != "" {
+		r.Tracker.Sent(id, email)
+	}
+	return id, err
+}
+
+var _ domain.OutboundEmailSender = Recorder{}
@@ -0,0 +1,93 @@
+package delivery
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/igorrius/resend-railway-gateway/internal/domain"
+)
+
+func TestTracker_OutOfOrderEvents(t *testing.T) {
+	tr := NewTracker(0)
+	tr.Sent("em_1", domain.Email{To: []domain.Address{{Addr: "b@example.com"}}})
+	base := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tr.Apply(Event{Type: "email.clicked", EmailID: "em_1", CreatedAt: base.Add(2 * time.Minute)})
+	rec, known := tr.Apply(Event{Type: "email.delivered", EmailID: "em_1", CreatedAt: base.Add(time.Minute)})
+	if !known {
+		t.Error("expected em_1 to be known")
+	}
+	if rec.Status != StatusClicked || len(rec.Events) != 2 {
+		t.Errorf("expected the newest event to win, got %+v", rec)
+	}
+
+	rec, known = tr.Apply(Event{Type: "email.delivered", EmailID: "em_2", CreatedAt: base, To: []string{"c@example.com"}})
+	if known || rec.Status != StatusDelivered || rec.To[0] != "c@example.com" {
+		t.Errorf("unexpected record for unknown email %+v (known=%v)", rec, known)
+	}
+}
+
+func TestTracker_Bounded(t *testing.T) {
+	tr := NewTracker(2)
+	for _, id := range []string{"a", "b", "c"} {
+		tr.Sent(id, domain.Email{})
+	}
+	if _, ok := tr.Get("a"); ok {
+		t.Error("oldest record should have been evicted")
+	}
+	if r, ok := tr.Get("c"); !ok || r.Status != StatusSent {
+		t.Errorf("unexpected record %+v", r)
+	}
+}
+
+func TestTracker_RetainedMessagesBounded(t *testing.T) {
+	tr := NewTracker(0)
+	tr.maxMessage, tr.maxRetained = 100, 250
+	full := domain.Envelope{Return: domain.DSNReturnFull}
+	msg := func(n int) []byte { return append([]byte("Subject: x\r\n\r\n"), bytes.Repeat([]byte("a"), n)...) }
+
+	tr.Sent("big", domain.Email{Raw: msg(200), Envelope: full})
+	if r, _ := tr.Get("big"); r.Message != nil || string(r.Header) != "Subject: x\r\n" {
+		t.Errorf("large message should fall back to headers, got %d bytes, header %q", len(r.Message), r.Header)
+	}
+	for _, id := range []string{"a", "b", "c"} {
+		tr.Sent(id, domain.Email{Raw: msg(80), Envelope: full})
+	}
+	if r, _ := tr.Get("a"); r.Message != nil {
+		t.Error("the oldest message should be dropped once the budget is exceeded")
+	}
+	if r, _ := tr.Get("c"); len(r.Message) == 0 {
+		t.Error("the newest message should be kept")
+	}
+	if tr.retained > tr.maxRetained {
+		t.Errorf("retained %d bytes, budget %d", tr.retained, tr.maxRetained)
+	}
+
+	long := append(bytes.Repeat([]byte("X-Filler: 0123456789\r\n"), maxHeaderBytes/10), "\r\nbody"...)
+	tr.Sent("headers", domain.Email{Raw: long})
+	if r, _ := tr.Get("headers"); len(r.Header) > maxHeaderBytes || !bytes.HasSuffix(r.Header, []byte("\r\n")) {
+		t.Errorf("header block of %d bytes not cut at a line", len(r.Header))
+	}
+}
+
+type stubSender struct{ err error }
+
+func (s stubSender) Send(domain.Email) (string, error) { return "em_1", s.err }
+
+func TestRecorder(t *testing.T) {
+	tr := NewTracker(0)
+	if _, err := (Recorder{Sender: stubSender{err: errors.New("boom")}, Tracker: tr}).Send(domain.Email{}); err == nil {
+		t.Fatal("expected error")
+	}
+	if _, ok := tr.Get("em_1"); ok {
+		t.Error("failed sends must not be recorded")
+	}
+	if _, err := (Recorder{Sender: stubSender{}, Tracker: tr}).Send(domain.Email{Subject: "Hi"}); err != nil {
+		t.Fatal(err)
+	}
+	if r, _ := tr.Get("em_1"); r.Subject != "Hi" {
+		t.Errorf("unexpected record %+v", r)
+	}
+}
@@ -0,0 +1,151 @@
+package domain
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/igorrius/resend-railway-gateway/internal/charset"
+	"golang.org/x/net/idna"
+)
+
+// addressParser decodes RFC 2047 display names in any supported charset.
+var addressParser = &mail.AddressParser{WordDecoder: charset.WordDecoder}
+
+// Address is a single mailbox with an optional display name.
+type Address struct {
+	Name string
+	Addr string
+}
+
+// String renders the address in RFC 5322 form, e.g. `"Doe, John" <j@x.com>`.
+// Addresses without a display name are rendered as the bare mailbox.
+func (a Address) String() string {
+	if a.Name == "" {
+		return a.Addr
+	}
+	return (&mail.Address{Name: a.Name, Address: a.Addr}).String()
+}
+
+// Domain returns the part of the mailbox after the last '@', or an empty string.
+func (a Address) Domain() string {
+	i := strings.LastIndexByte(a.Addr, '@')
+	if i < 0 {
+		return ""
+	}
+	return a.Addr[i+1:]
+}
+
+// Validate checks the mailbox syntax and that the domain is a valid
+// (possibly internationalized) host name.
+func (a Address) Validate() error {
+	parsed, err := mail.ParseAddress(a.Addr)
+	if err != nil || parsed.Address != a.Addr || parsed.Name != "" {
+		return fmt.Errorf("invalid address %q", a.Addr)
+	}
+	if _, err := idna.Lookup.ToASCII(a.Domain()); err != nil {
+		return fmt.Errorf("invalid domain in address %q: %v", a.Addr, err)
+	}
+	return nil
+}
+
+// Normalize returns the address with its domain in ASCII form (see NormalizeAddr).
+func (a Address) Normalize() Address {
+	a.Addr = NormalizeAddr(a.Addr)
+	return a
+}
+
+// NormalizeAddr converts an internationalized domain to its ASCII (punycode)
+// form, e.g. "user@Bücher.de" becomes "user@xn--bcher-kva.de", so that the
+// address compares equal however the domain was written. The local part,
+// which may be UTF-8 (RFC 6531), is kept. ASCII domains and domains that are
+// not valid host names are returned unchanged; Validate reports the latter.
+func NormalizeAddr(addr string) string {
+	i := strings.LastIndexByte(addr, '@')
+	if i < 0 {
+		return addr
+	}
+	d := NormalizeDomain(addr[i+1:])
+	return addr[:i+1] + d
+}
+
+// NormalizeDomain returns the ASCII form of an internationalized domain name.
+func NormalizeDomain(d string) string {
+	if isASCII(d) {
+		return d
+	}
+	ascii, err := idna.Lookup.ToASCII(d)
+	if err != nil {
+		return d
+	}
+	return ascii
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// NormalizeAddresses applies Address.Normalize to every address in place.
+func NormalizeAddresses(list []Address) {
+	for i := range list {
+		list[i] = list[i].Normalize()
+	}
+}
+
+// ParseAddress parses a single RFC 5322 address such as `John <j@x.com>`.
+// Display names may use RFC 2047 encoded words and addresses raw UTF-8.
+func ParseAddress(s string) (Address, error) {
+	a, err := addressParser.Parse(s)
+	if err != nil {
+		return Address{}, err
+	}
+	return Address{Name: a.Name, Addr: a.Address}, nil
+}
+
+// ParseAddressList parses a comma separated list of addresses with net/mail
+// semantics: quoted display names may contain commas and group syntax
+// (`team: a@x.com, b@x.com;`) is flattened into its members.
+func ParseAddressList(s string) ([]Address, error) {
+	list, err := addressParser.ParseList(s)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Address, 0, len(list))
+	for _, a := range list {
+		out = append(out, Address{Name: a.Name, Addr: a.Address})
+	}
+	return out, nil
+}
+
+// ParseAddressListLenient is like ParseAddressList but never fails: a value that
+// cannot be parsed is kept verbatim as a single address so that Validate can
+// report it instead of the recipient being silently dropped.
+func ParseAddressListLenient(s string) []Address {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	list, err := ParseAddressList(s)
+	if err != nil {
+		return []Address{{Addr: s}}
+	}
+	return list
+}
+
+// AddressStrings renders each address with Address.String.
+func AddressStrings(addrs []Address) []string {
+	if len(addrs) == 0 {
+		return nil
+	}
+	out := make([]string, 0, len(addrs))
+	for _, a := range addrs {
+		out = append(out, a.String())
+	}
+	return out
+}
@@ -0,0 +1,67 @@
+package domain
+
+import "testing"
+
+func TestAddress_Validate(t *testing.T) {
+	cases := []struct {
+		addr  string
+		valid bool
+	}{
+		{"user@example.com", true},
+		{"user@bücher.de", true},
+		{"user@xn--bcher-kva.de", true},
+		{"user@exa_mple.com", false},
+		{"user@", false},
+		{"not an address", false},
+		{"John <j@example.com>", false},
+	}
+	for _, c := range cases {
+		err := Address{Addr: c.addr}.Validate()
+		if c.valid && err != nil {
+			t.Errorf("%q: expected valid, got %v", c.addr, err)
+		}
+		if !c.valid && err == nil {
+			t.Errorf("%q: expected error", c.addr)
+		}
+	}
+}
+
+func TestAddress_String(t *testing.T) {
+	if got := (Address{Addr: "a@example.com"}).String(); got != "a@example.com" {
+		t.Errorf("unexpected bare rendering %q", got)
+	}
+	if got := (Address{Name: "Doe, John", Addr: "j@example.com"}).String(); got != `"Doe, John" <j@example.com>` {
+		t.Errorf("unexpected named rendering %q", got)
+	}
+}
+
+func TestNormalizeAddr(t *testing.T) {
+	cases := map[string]string{
+		"user@example.com": "user@example.com",
+		"User@Example.COM": "User@Example.COM",
+		"user@bücher.de":   "user@xn--bcher-kva.de",
+		"user@BÜCHER.de":   "user@xn--bcher-kva.de",
+		"δοκιμή@παράδειγμα.δοκιμή": "δοκιμή@xn--hxajbheg2az3al.xn--jxalpdlp",
+		"用户@例子.广告":                 "用户@xn--fsqu00a.xn--4rr70v",
+		"user@exa_mple.com":        "user@exa_mple.com",
+		"no-at-sign":               "no-at-sign",
+	}
+	for in, want := range cases {
+		if got := NormalizeAddr(in); got != want {
+			t.Errorf("NormalizeAddr(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestParseAddress_Internationalized(t *testing.T) {
+	a, err := ParseAddress("=?KOI8-R?B?8NLJ18XU?= <иван@пример.рф>")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if a.Name != "Привет" || a.Addr != "иван@пример.рф" {
+		t.Errorf("unexpected address %+v", a)
+	}
+	if err := a.Normalize().Validate(); err != nil {
+		t.Errorf("UTF-8 local part with IDN domain should be valid: %v", err)
+	}
+}
@@ -1,68 +1,225 @@
 package domain
 
 import (
+	"encoding/base64"
 	"errors"
+	"fmt"
 	"strings"
+	"time"
 )
 
 // Email represents a normalized email message in the domain layer.
 // It contains all the necessary fields for sending an email through the gateway.
 type Email struct {
-	From        string
-	To          []string
-	Cc          []string
-	Bcc         []string
+	From        Address
+	To          []Address
+	Cc          []Address
+	Bcc         []Address
 	Subject     string
 	Text        string
 	HTML        string
-	ReplyTo     string
+	ReplyTo     []Address
 	Headers     map[string]string
 	Attachments []Attachment
 	Tags        []Tag
+	// ScheduledAt is the requested delivery time in RFC 3339 format.
+	// An empty value means the email is sent immediately.
+	ScheduledAt string
+	// IdempotencyKey lets the provider deduplicate retried submissions.
+	IdempotencyKey string
+	// DryRun asks for the email to be rendered and recorded instead of sent.
+	DryRun bool
+	// Envelope carries transport-level metadata about how the email was submitted.
+	Envelope Envelope
+	// Raw is the original MIME message when the email arrived as one (e.g. over SMTP).
+	Raw []byte
 }
 
-// Validate checks that the email has all essential fields required for sending.
-// Returns an error if the 'From' field is empty or if there are no recipients.
+// Envelope holds submission metadata that is not part of the message itself.
+type Envelope struct {
+	// MailFrom is the envelope sender (SMTP MAIL FROM); empty for null senders.
+	MailFrom string
+	// User is the authenticated identity of the submitting client, if any.
+	User string
+	// RemoteAddr is the client's network address; behind a PROXY protocol
+	// aware load balancer this is the original client, not the proxy.
+	RemoteAddr string
+	// Return and EnvelopeID are the RET and ENVID parameters of MAIL FROM
+	// (RFC 3461); both are empty when the client did not request them.
+	Return     DSNReturn
+	EnvelopeID string
+	// Recipients holds the DSN parameters of each RCPT TO, in order.
+	Recipients []EnvelopeRecipient
+}
+
+// DSNReturn selects what a delivery status notification returns of the message.
+type DSNReturn string
+
+const (
+	DSNReturnFull    DSNReturn = "FULL"
+	DSNReturnHeaders DSNReturn = "HDRS"
+)
+
+// DSNNotify is a condition under which a recipient asked to be notified.
+type DSNNotify string
+
+const (
+	DSNNotifyNever   DSNNotify = "NEVER"
+	DSNNotifySuccess DSNNotify = "SUCCESS"
+	DSNNotifyFailure DSNNotify = "FAILURE"
+	DSNNotifyDelay   DSNNotify = "DELAY"
+)
+
+// EnvelopeRecipient is an envelope recipient with its DSN parameters.
+type EnvelopeRecipient struct {
+	Addr string
+	// Notify is the NOTIFY parameter; empty means it was not given.
+	Notify []DSNNotify
+	// Original is the ORCPT parameter in "addr-type;address" form.
+	Original string
+}
+
+// Wants reports whether the recipient asked for notifications of kind n.
+// Without a NOTIFY parameter only failures are reported (RFC 3461 section 4.1).
+func (r EnvelopeRecipient) Wants(n DSNNotify) bool {
+	if len(r.Notify) == 0 {
+		return n == DSNNotifyFailure
+	}
+	for _, v := range r.Notify {
+		if v == n {
+			return true
+		}
+	}
+	return false
+}
+
+// Recipient returns the DSN parameters given for addr, or defaults when the
+// address was not an envelope recipient (e.g. it was added by a transform).
+// Addresses are compared in their normalized form, so that an envelope
+// recipient with an internationalized domain matches its punycode form.
+func (e Envelope) Recipient(addr string) EnvelopeRecipient {
+	norm := NormalizeAddr(addr)
+	for _, r := range e.Recipients {
+		if strings.EqualFold(NormalizeAddr(r.Addr), norm) {
+			return r
+		}
+	}
+	return EnvelopeRecipient{Addr: addr}
+}
+
+// Validate checks the email against the constraints of the Resend API.
+// All violations are collected into a *ValidationError so that callers can
+// report every offending field at once.
 func (e Email) Validate() error {
-	if strings.TrimSpace(e.From) == "" {
-		return errors.New("from is required")
+	v := &ValidationError{}
+	if strings.TrimSpace(e.From.Addr) == "" {
+		v.add("from", ViolationInvalid, "from is required")
+	} else if err := e.From.Validate(); err != nil {
+		v.add("from", ViolationInvalid, err.Error())
 	}
 	if len(e.To) == 0 {
-		return errors.New("at least one recipient is required")
+		v.add("to", ViolationRecipient, "at least one recipient is required")
 	}
-	return nil
+	for _, list := range []struct {
+		field string
+		addrs []Address
+	}{{"to", e.To}, {"cc", e.Cc}, {"bcc", e.Bcc}} {
+		if len(list.addrs) > MaxRecipients {
+			v.add(list.field, ViolationRecipient, fmt.Sprintf("at most %d recipients are allowed, got %d", MaxRecipients, len(list.addrs)))
+		}
+		for i, a := range list.addrs {
+			if err := a.Validate(); err != nil {
+				v.add(fmt.Sprintf("%s[%d]", list.field, i), ViolationRecipient, err.Error())
+			}
+		}
+	}
+	for i, a := range e.ReplyTo {
+		if err := a.Validate(); err != nil {
+			v.add(fmt.Sprintf("reply_to[%d]", i), ViolationInvalid, err.Error())
+		}
+	}
+	if strings.TrimSpace(e.Subject) == "" {
+		v.add("subject", ViolationInvalid, "subject is required")
+	} else if strings.ContainsAny(e.Subject, "\r\n") {
+		v.add("subject", ViolationInvalid, "subject must not contain line breaks")
+	}
+	if strings.TrimSpace(e.Text) == "" && strings.TrimSpace(e.HTML) == "" {
+		v.add("body", ViolationInvalid, "a text or html body is required")
+	}
+	for k, val := range e.Headers {
+		if strings.ContainsAny(k, "\r\n:") || strings.ContainsAny(val, "\r\n") {
+			v.add("headers."+k, ViolationInvalid, "header names and values must not contain line breaks")
+		}
+	}
+	var size int
+	for i, a := range e.Attachments {
+		if a.Filename == "" {
+			v.add(fmt.Sprintf("attachments[%d]", i), ViolationInvalid, "filename is required")
+		}
+		size += base64.StdEncoding.EncodedLen(len(a.Content))
+	}
+	if size > MaxAttachmentBytes {
+		v.add("attachments", ViolationTooLarge, fmt.Sprintf("total size %d bytes exceeds the %d byte limit", size, MaxAttachmentBytes))
+	}
+	for i, t := range e.Tags {
+		if err := t.Validate(); err != nil {
+			v.add(fmt.Sprintf("tags[%d]", i), ViolationInvalid, err.Error())
+		}
+	}
+	if e.ScheduledAt != "" {
+		if _, err := time.Parse(time.RFC3339, e.ScheduledAt); err != nil {
+			v.add("scheduled_at", ViolationInvalid, fmt.Sprintf("must be an RFC 3339 timestamp: %q", e.ScheduledAt))
+		}
+	}
+	if len(e.IdempotencyKey) > maxIdempotencyKeyLen {
+		v.add("idempotency_key", ViolationInvalid, fmt.Sprintf("must not exceed %d characters", maxIdempotencyKeyLen))
+	}
+	return v.err()
 }
 
 // NewEmail constructs an Email ensuring defaults and immutability of maps.
-// It normalizes recipient addresses (trimming whitespace and filtering empty ones)
+// It parses the sender and recipient addresses (filtering empty ones)
 // and performs validation before returning the email. Returns an error if validation fails.
 func NewEmail(from string, to []string, subject, text, html string, headers map[string]string) (Email, error) {
-	normalizedTo := make([]string, 0, len(to))
+	normalizedTo := make([]Address, 0, len(to))
 	for _, r := range to {
-		r = strings.TrimSpace(r)
-		if r != "" {
-			normalizedTo = append(normalizedTo, r)
-		}
+		normalizedTo = append(normalizedTo, ParseAddressListLenient(r)...)
+	}
+	var sender Address
+	if list := ParseAddressListLenient(from); len(list) > 0 {
+		sender = list[0]
 	}
 	copiedHeaders := map[string]string{}
 	for k, v := range headers {
 		copiedHeaders[k] = v
 	}
 	e := Email{
-		From:    strings.TrimSpace(from),
+		From:    sender,
 		To:      normalizedTo,
 		Subject: subject,
 		Text:    text,
 		HTML:    html,
 		Headers: copiedHeaders,
 	}
+	e.NormalizeAddresses()
 	return e, e.Validate()
 }
 
+// NormalizeAddresses converts the internationalized domains of every address
+// of the email to ASCII (see NormalizeAddr).
+func (e *Email) NormalizeAddresses() {
+	e.From = e.From.Normalize()
+	for _, list := range [][]Address{e.To, e.Cc, e.Bcc, e.ReplyTo} {
+		NormalizeAddresses(list)
+	}
+}
+
 // Attachment represents a file attachment with its filename and content.
 type Attachment struct {
 	Filename string
 	Content  []byte
+	// ContentType is the MIME type; empty lets the provider derive it from Filename.
+	ContentType string
 }
 
 // Tag represents provider-specific metadata tags for analytics or categorization.
@@ -70,3 +227,34 @@ type Tag struct {
 	Name  string
 	Value string
 }
+
+// Validate checks that the tag name and value are non-empty, at most 256 characters
+// long and only contain ASCII letters, numbers, underscores or dashes.
+func (t Tag) Validate() error {
+	if t.Name == "" {
+		return errors.New("tag name is required")
+	}
+	if t.Value == "" {
+		return fmt.Errorf("tag %q: value is required", t.Name)
+	}
+	for _, s := range []string{t.Name, t.Value} {
+		if len(s) > maxTagLen {
+			return fmt.Errorf("tag %q: must not exceed %d characters", t.Name, maxTagLen)
+		}
+		if !isTagToken(s) {
+			return fmt.Errorf("tag %q: only ASCII letters, numbers, underscores and dashes are allowed", t.Name)
+		}
+	}
+	return nil
+}
+
+func isTagToken(s string) bool {
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,53 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEmail_ValidateCollectsFieldErrors(t *testing.T) {
+	e := Email{
+		From:        Address{Addr: "not-an-address"},
+		Subject:     "line\nbreak",
+		Attachments: []Attachment{{Content: []byte("x")}},
+		Tags:        []Tag{{Name: "bad name", Value: "v"}},
+	}
+
+	var verr *ValidationError
+	if !errors.As(e.Validate(), &verr) {
+		t.Fatalf("expected *ValidationError")
+	}
+	want := map[string]bool{"from": true, "to": true, "subject": true, "body": true, "attachments[0]": true, "tags[0]": true}
+	for _, f := range verr.Fields {
+		delete(want, f.Field)
+	}
+	if len(want) != 0 {
+		t.Errorf("missing field errors for %v in %v", want, verr.Fields)
+	}
+	if !verr.Has(ViolationRecipient) {
+		t.Errorf("expected a recipient violation")
+	}
+}
+
+func TestEmail_ValidateOK(t *testing.T) {
+	e, err := NewEmail("Sender <a@example.com>", []string{"b@example.com"}, "hi", "", "<p>hi</p>", nil)
+	if err != nil {
+		t.Fatalf("expected valid email, got %v", err)
+	}
+	if e.From.Name != "Sender" {
+		t.Errorf("expected display name to be parsed, got %v", e.From)
+	}
+}
+
+func TestEnvelope_RecipientIDN(t *testing.T) {
+	env := Envelope{Recipients: []EnvelopeRecipient{
+		{Addr: "user@Bücher.de", Notify: []DSNNotify{DSNNotifyDelay}, Original: "rfc822;user@Bücher.de"},
+	}}
+	got := env.Recipient("user@xn--bcher-kva.de")
+	if len(got.Notify) != 1 || got.Notify[0] != DSNNotifyDelay || got.Original != "rfc822;user@Bücher.de" {
+		t.Errorf("normalized address did not match its envelope recipient: %+v", got)
+	}
+	if got := env.Recipient("other@example.com"); got.Addr != "other@example.com" || len(got.Notify) != 0 {
+		t.Errorf("unexpected recipient %+v", got)
+	}
+}
@@ -0,0 +1,30 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+)
+
+// Policy errors returned by the application layer. Adapters map them to
+// protocol specific replies (e.g. SMTP 553/550).
+var (
+	// ErrSenderNotAllowed means the sender address is not permitted for the client.
+	ErrSenderNotAllowed = errors.New("sender address not allowed")
+	// ErrRecipientNotAllowed means the recipient is outside the sandbox allowlist.
+	ErrRecipientNotAllowed = errors.New("recipient not allowed")
+	// ErrRecipientSuppressed means the recipient is on the suppression list.
+	ErrRecipientSuppressed = errors.New("recipient is suppressed after earlier bounces or complaints")
+)
+
+// RecipientRejectedError is returned by an OutboundEmailSender when the
+// provider refused the email because of specific recipients.
+type RecipientRejectedError struct {
+	Recipients []string
+	// Permanent distinguishes hard (5xx) rejections from temporary ones.
+	Permanent bool
+	Message   string
+}
+
+func (e *RecipientRejectedError) Error() string {
+	return "recipients rejected (" + strings.Join(e.Recipients, ", ") + "): " + e.Message
+}
@@ -4,7 +4,8 @@ package domain
 // Implementations of this interface handle the actual delivery of emails
 // through services like Resend, SendGrid, etc.
 type OutboundEmailSender interface {
-	Send(email Email) error
+	// Send delivers the email and returns the ID the provider assigned to it.
+	Send(email Email) (string, error)
 }
 
 // MessageLogger abstracts logging in the domain/app layers.
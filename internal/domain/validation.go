@@ -0,0 +1,72 @@
+package domain
+
+import (
+	"strings"
+)
+
+// Limits enforced by Email.Validate. They mirror the constraints of the
+// Resend API so that messages are rejected before the provider round-trip.
+const (
+	MaxRecipients        = 50
+	MaxAttachmentBytes   = 40 << 20 // total size after base64 encoding
+	maxTagLen            = 256
+	maxIdempotencyKeyLen = 256
+)
+
+// ViolationKind classifies a validation failure so that adapters can map it
+// to a protocol specific status (e.g. an SMTP reply code).
+type ViolationKind int
+
+const (
+	// ViolationInvalid is a malformed or missing value.
+	ViolationInvalid ViolationKind = iota
+	// ViolationRecipient is a problem with the recipient list.
+	ViolationRecipient
+	// ViolationTooLarge is a value exceeding a size limit.
+	ViolationTooLarge
+)
+
+// FieldError describes a single field that failed validation.
+type FieldError struct {
+	Field   string
+	Kind    ViolationKind
+	Message string
+}
+
+func (e FieldError) Error() string { return e.Field + ": " + e.Message }
+
+// ValidationError is returned by Email.Validate and lists every field that
+// failed validation.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		msgs = append(msgs, f.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Has reports whether any field failed with the given kind.
+func (e *ValidationError) Has(kind ViolationKind) bool {
+	for _, f := range e.Fields {
+		if f.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *ValidationError) add(field string, kind ViolationKind, msg string) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Kind: kind, Message: msg})
+}
+
+// err returns e as an error, or nil when no field failed.
+func (e *ValidationError) err() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
@@ -0,0 +1,235 @@
+// Package dsn composes RFC 3464 delivery status notifications.
+package dsn
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/igorrius/resend-railway-gateway/internal/domain"
+	"github.com/igorrius/resend-railway-gateway/internal/mimebuild"
+)
+
+// Action is the per-recipient outcome reported in a DSN (RFC 3464 section 2.3.3).
+type Action string
+
+const (
+	ActionFailed    Action = "failed"
+	ActionDelayed   Action = "delayed"
+	ActionDelivered Action = "delivered"
+	ActionRelayed   Action = "relayed"
+)
+
+// Recipient is the status of one recipient.
+type Recipient struct {
+	// Final is the address the gateway attempted delivery to.
+	Final string
+	// Original is the ORCPT address supplied by the client, if any.
+	Original string
+	Action   Action
+	// Status is an RFC 3463 status code such as "5.1.1".
+	Status string
+	// Diagnostic is the remote server's explanation, e.g. "550 mailbox unavailable".
+	Diagnostic string
+}
+
+// Report is the content of a delivery status notification.
+type Report struct {
+	// ReportingMTA names the gateway, e.g. its host name.
+	ReportingMTA string
+	// EnvelopeID is the ENVID supplied by the client, if any.
+	EnvelopeID string
+	// Arrival is when the gateway accepted the original message.
+	Arrival    time.Time
+	Recipients []Recipient
+	// Original is the returned content: the full message or only its header
+	// block. Headers are returned as text/rfc822-headers, full messages as
+	// message/rfc822.
+	Original     []byte
+	HeadersOnly  bool
+	OriginalFrom string // shown in the human readable part
+	Subject      string // subject of the original message
+}
+
+var statusCode = regexp.MustCompile(`\b([245])\.(\d{1,3})\.(\d{1,3})\b`)
+
+// StatusFromDiagnostic extracts an RFC 3463 status code from diagnostic text,
+// falling back to def when none is present or its class does not match.
+func StatusFromDiagnostic(diag, def string) string {
+	if m := statusCode.FindString(diag); m != "" && m[0] == def[0] {
+		return m
+	}
+	return def
+}
+
+// Compose renders report as a multipart/report email from `from` to `to`.
+// The email is sent with the null envelope sender and Auto-Submitted so that
+// it can never trigger another notification.
+//
+// The exact RFC 3464 message is stored in Raw. Providers that cannot send a
+// prepared MIME message get the human readable part as Text and the
+// machine readable parts as attachments.
+func Compose(r Report, from domain.Address, to string, now time.Time) domain.Email {
+	subject := "Delivery Status Notification (" + summary(r) + ")"
+	text := humanText(r)
+	status := statusFields(r)
+
+	original := domain.Attachment{Filename: "original.eml", ContentType: "message/rfc822", Content: r.Original}
+	if r.HeadersOnly {
+		original = domain.Attachment{Filename: "original-headers.txt", ContentType: "text/rfc822-headers", Content: r.Original}
+	}
+	attachments := []domain.Attachment{{Filename: "delivery-status.txt", ContentType: "message/delivery-status", Content: status}}
+	if len(r.Original) > 0 {
+		attachments = append(attachments, original)
+	}
+
+	email := domain.Email{
+		From:        from,
+		To:          []domain.Address{{Addr: to}},
+		Subject:     subject,
+		Text:        text,
+		Headers:     map[string]string{"Auto-Submitted": "auto-replied"},
+		Attachments: attachments,
+		Envelope:    domain.Envelope{MailFrom: ""},
+	}
+	email.Raw = render(email, now)
+	return email
+}
+
+func summary(r Report) string {
+	actions := map[Action]bool{}
+	for _, rc := range r.Recipients {
+		actions[rc.Action] = true
+	}
+	switch {
+	case actions[ActionFailed]:
+		return "Failure"
+	case actions[ActionDelayed]:
+		return "Delay"
+	default:
+		return "Success"
+	}
+}
+
+func humanText(r Report) string {
+	var b strings.Builder
+	b.WriteString("This is an automatically generated Delivery Status Notification.\n\n")
+	if r.Subject != "" {
+		fmt.Fprintf(&b, "Original subject: %s\n\n", r.Subject)
+	}
+	for _, rc := range r.Recipients {
+		switch rc.Action {
+		case ActionFailed:
+			fmt.Fprintf(&b, "Delivery to %s failed permanently.\n", rc.Final)
+		case ActionDelayed:
+			fmt.Fprintf(&b, "Delivery to %s has been delayed; delivery will be retried.\n", rc.Final)
+		case ActionDelivered:
+			fmt.Fprintf(&b, "Your message was delivered to %s.\n", rc.Final)
+		case ActionRelayed:
+			fmt.Fprintf(&b, "Your message was relayed to %s, which does not send delivery notifications.\n", rc.Final)
+		}
+		if rc.Diagnostic != "" {
+			fmt.Fprintf(&b, "  %s\n", rc.Diagnostic)
+		}
+	}
+	return b.String()
+}
+
+// statusFields renders the message/delivery-status body: per-message fields,
+// then one block of per-recipient fields for each recipient.
+func statusFields(r Report) []byte {
+	var b bytes.Buffer
+	if r.EnvelopeID != "" {
+		fmt.Fprintf(&b, "Original-Envelope-Id: %s\r\n", xtext(r.EnvelopeID))
+	}
+	fmt.Fprintf(&b, "Reporting-MTA: dns; %s\r\n", r.ReportingMTA)
+	if !r.Arrival.IsZero() {
+		fmt.Fprintf(&b, "Arrival-Date: %s\r\n", r.Arrival.Format(time.RFC1123Z))
+	}
+	for _, rc := range r.Recipients {
+		b.WriteString("\r\n")
+		if rc.Original != "" {
+			fmt.Fprintf(&b, "Original-Recipient: %s\r\n", addrField(rc.Original))
+		}
+		fmt.Fprintf(&b, "Final-Recipient: rfc822; %s\r\n", rc.Final)
+		fmt.Fprintf(&b, "Action: %s\r\n", rc.Action)
+		fmt.Fprintf(&b, "Status: %s\r\n", rc.Status)
+		if rc.Diagnostic != "" {
+			fmt.Fprintf(&b, "Diagnostic-Code: smtp; %s\r\n", oneLine(rc.Diagnostic))
+		}
+	}
+	return b.Bytes()
+}
+
+// addrField renders an ORCPT value; values already carrying an address type
+// (e.g. "rfc822;a@b") are kept as given.
+func addrField(v string) string {
+	if t, addr, ok := strings.Cut(v, ";"); ok && !strings.Contains(t, "@") {
+		return strings.TrimSpace(t) + "; " + strings.TrimSpace(addr)
+	}
+	return "rfc822; " + v
+}
+
+// xtext keeps printable ASCII and drops anything that could break the field.
+func xtext(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < '!' || r > '~' {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func render(email domain.Email, now time.Time) []byte {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	h := textproto.MIMEHeader{}
+	h.Set("Content-Type", "text/plain; charset=utf-8")
+	w, _ := mw.CreatePart(h)
+	w.Write([]byte(strings.ReplaceAll(email.Text, "\n", "\r\n")))
+	for _, a := range email.Attachments {
+		h := textproto.MIMEHeader{}
+		h.Set("Content-Type", a.ContentType)
+		w, _ := mw.CreatePart(h)
+		w.Write(a.Content)
+	}
+	mw.Close()
+
+	var out bytes.Buffer
+	hdr := mimebuild.Header(email, now)
+	hdr.Set("Content-Type", mime.FormatMediaType("multipart/report", map[string]string{
+		"report-type": "delivery-status",
+		"boundary":    mw.Boundary(),
+	}))
+	mimebuild.WriteHeader(&out, hdr)
+	out.WriteString("\r\n")
+	out.Write(body.Bytes())
+	return out.Bytes()
+}
+
+// ShouldReport reports whether a notification may be sent about a message
+// with the given envelope sender and header block. Messages from the null
+// sender, automatically submitted messages and reports themselves never
+// get one, which prevents notification loops.
+func ShouldReport(mailFrom string, header []byte) bool {
+	if strings.TrimSpace(mailFrom) == "" {
+		return false
+	}
+	tp := textproto.NewReader(bufio.NewReader(bytes.NewReader(header)))
+	h, _ := tp.ReadMIMEHeader()
+	if v := strings.ToLower(strings.TrimSpace(h.Get("Auto-Submitted"))); v != "" && v != "no" {
+		return false
+	}
+	mediatype, params, _ := mime.ParseMediaType(h.Get("Content-Type"))
+	return !(mediatype == "multipart/report" && strings.EqualFold(params["report-type"], "delivery-status"))
+}
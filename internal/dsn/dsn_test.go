@@ -0,0 +1,120 @@
+package dsn
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/igorrius/resend-railway-gateway/internal/domain"
+)
+
+func TestCompose(t *testing.T) {
+	r := Report{
+		ReportingMTA: "gateway.example.com",
+		EnvelopeID:   "env-1",
+		Arrival:      time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+		Recipients: []Recipient{{
+			Final:      "dead@example.org",
+			Original:   "rfc822;Dead@example.org",
+			Action:     ActionFailed,
+			Status:     "5.1.1",
+			Diagnostic: "550 5.1.1 user\n unknown",
+		}},
+		Original:    []byte("From: app@example.com\r\nSubject: Invoice\r\n\r\n"),
+		HeadersOnly: true,
+		Subject:     "Invoice",
+	}
+	email := Compose(r, domain.Address{Name: "Mail Delivery System", Addr: "mailer-daemon@example.com"}, "app@example.com", time.Now())
+
+	if email.Envelope.MailFrom != "" || email.Headers["Auto-Submitted"] != "auto-replied" {
+		t.Errorf("DSN must use the null sender and Auto-Submitted, got %q %v", email.Envelope.MailFrom, email.Headers)
+	}
+	if email.Subject != "Delivery Status Notification (Failure)" || email.To[0].Addr != "app@example.com" {
+		t.Errorf("unexpected subject/to %q %v", email.Subject, email.To)
+	}
+	if err := email.Validate(); err != nil {
+		t.Errorf("DSN email must be valid: %v", err)
+	}
+
+	tp := textproto.NewReader(bufio.NewReader(bytes.NewReader(email.Raw)))
+	hdr, err := tp.ReadMIMEHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mediatype, params, _ := mime.ParseMediaType(hdr.Get("Content-Type"))
+	if mediatype != "multipart/report" || params["report-type"] != "delivery-status" {
+		t.Fatalf("unexpected content type %q", hdr.Get("Content-Type"))
+	}
+	mr := multipart.NewReader(tp.R, params["boundary"])
+	var types []string
+	var status string
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		types = append(types, p.Header.Get("Content-Type"))
+		body, _ := io.ReadAll(p)
+		if p.Header.Get("Content-Type") == "message/delivery-status" {
+			status = string(body)
+		}
+	}
+	if strings.Join(types, ",") != "text/plain; charset=utf-8,message/delivery-status,text/rfc822-headers" {
+		t.Errorf("unexpected parts %v", types)
+	}
+	for _, want := range []string{
+		"Original-Envelope-Id: env-1\r\n",
+		"Reporting-MTA: dns; gateway.example.com\r\n",
+		"\r\n\r\nOriginal-Recipient: rfc822; Dead@example.org\r\n",
+		"Final-Recipient: rfc822; dead@example.org\r\n",
+		"Action: failed\r\nStatus: 5.1.1\r\n",
+		"Diagnostic-Code: smtp; 550 5.1.1 user unknown\r\n",
+	} {
+		if !strings.Contains(status, want) {
+			t.Errorf("delivery-status lacks %q:\n%s", want, status)
+		}
+	}
+}
+
+func TestShouldReport(t *testing.T) {
+	cases := []struct {
+		name     string
+		mailFrom string
+		header   string
+		want     bool
+	}{
+		{"normal", "app@example.com", "Subject: hi\r\n\r\n", true},
+		{"null sender", "", "Subject: hi\r\n\r\n", false},
+		{"auto submitted", "app@example.com", "Auto-Submitted: auto-generated\r\n\r\n", false},
+		{"auto submitted no", "app@example.com", "Auto-Submitted: no\r\n\r\n", true},
+		{"report", "app@example.com", "Content-Type: multipart/report; report-type=delivery-status; boundary=x\r\n\r\n", false},
+	}
+	for _, c := range cases {
+		if got := ShouldReport(c.mailFrom, []byte(c.header)); got != c.want {
+			t.Errorf("%s: expected %v, got %v", c.name, c.want, got)
+		}
+	}
+}
+
+func TestStatusFromDiagnostic(t *testing.T) {
+	cases := []struct{ diag, def, want string }{
+		{"550 5.1.1 <a@b>: user unknown", "5.0.0", "5.1.1"},
+		{"mailbox full", "5.0.0", "5.0.0"},
+		{"421 4.7.0 try later", "5.0.0", "5.0.0"},
+		{"452 4.2.2 over quota", "4.0.0", "4.2.2"},
+	}
+	for _, c := range cases {
+		if got := StatusFromDiagnostic(c.diag, c.def); got != c.want {
+			t.Errorf("StatusFromDiagnostic(%q) = %q, want %q", c.diag, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+// Package gateway builds the application service from the configuration:
+// the provider, archiving, delivery tracking, policies and the suppression
+// list. It is shared by the gateway server and the sendmail command.
+package gateway
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"time"
+
+	"github.com/igorrius/resend-railway-gateway/internal/adapters/archive"
+	"github.com/igorrius/resend-railway-gateway/internal/adapters/capture"
+	resendclient "github.com/igorrius/resend-railway-gateway/internal/adapters/resend"
+	"github.com/igorrius/resend-railway-gateway/internal/adapters/webhook"
+	"github.com/igorrius/resend-railway-gateway/internal/app"
+	"github.com/igorrius/resend-railway-gateway/internal/config"
+	"github.com/igorrius/resend-railway-gateway/internal/delivery"
+	"github.com/igorrius/resend-railway-gateway/internal/domain"
+	"github.com/igorrius/resend-railway-gateway/internal/suppression"
+)
+
+// Gateway is the application service together with the components built
+// for it that protocol and HTTP adapters need.
+type Gateway struct {
+	Service      *app.Service
+	Suppressions *suppression.List
+	// Capture is the message store of the capture provider; nil otherwise.
+	Capture capture.Store
+	// Verifier and Tracker are set when webhooks are enabled.
+	Verifier *webhook.Verifier
+	Tracker  *delivery.Tracker
+
+	// router selects the Resend API key of the resend provider; nil otherwise.
+	router *resendclient.Router
+}
+
+// New builds the gateway described by cfg. root receives the logs of
+// adapters that use slog directly, logger the per-message logs.
+func New(cfg config.Config, root *slog.Logger, logger domain.MessageLogger) (*Gateway, error) {
+	g := &Gateway{}
+	var archiver *archive.Writer
+	if cfg.ArchiveDir != "" {
+		var err error
+		archiver, err = archive.NewWriter(cfg.ArchiveDir, archive.Layout(cfg.ArchiveLayout), cfg.ArchiveGzip)
+		if err != nil {
+			return nil, fmt.Errorf("ARCHIVE_DIR: %w", err)
+		}
+	}
+
+	suppressions, err := suppressionList(cfg)
+	if err != nil {
+		return nil, err
+	}
+	g.Suppressions = suppressions
+
+	var sender domain.OutboundEmailSender
+	switch cfg.Provider {
+	case config.ProviderFile:
+		sender = archiver
+	case config.ProviderCapture:
+		store, err := captureStore(cfg)
+		if err != nil {
+			return nil, err
+		}
+		sender = capture.NewSender(store)
+		g.Capture = store
+	default:
+		dryRun, err := resendclient.NewDryRun(root, cfg.DryRunDir)
+		if err != nil {
+			return nil, fmt.Errorf("DRY_RUN_DIR: %w", err)
+		}
+		if cfg.DryRun {
+			sender = dryRun
+		} else {
+			g.router = resendclient.NewRouter(resendRoutes(cfg), cfg.ResendAPIKey)
+			sender = resendclient.DryRunSwitch{Live: g.router, DryRun: dryRun}
+		}
+	}
+	if archiver != nil && cfg.Provider != config.ProviderFile {
+		sender = archive.Tee{Primary: sender, Archive: archiver, Logger: logger}
+	}
+	if cfg.WebhookSecret != "" {
+		g.Verifier, err = webhook.NewVerifier(cfg.WebhookSecret)
+		if err != nil {
+			return nil, fmt.Errorf("RESEND_WEBHOOK_SECRET: %w", err)
+		}
+		g.Tracker = delivery.NewTracker(cfg.DeliveryTrackMax)
+		sender = delivery.Recorder{Sender: sender, Tracker: g.Tracker}
+	}
+	opts, err := serviceOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, app.WithSuppressions(suppressions))
+	g.Service = app.NewService(sender, logger, cfg.SendTimeout, opts...)
+	return g, nil
+}
+
+// Reconfigure applies the settings of cfg that can change while the gateway
+// runs (see config.RestartRequired) to the service, the Resend API keys and
+// the webhook signing secret. Nothing is changed when they are invalid.
+func (g *Gateway) Reconfigure(cfg config.Config) error {
+	opts, err := serviceOptions(cfg)
+	if err != nil {
+		return err
+	}
+	if g.Verifier != nil && cfg.WebhookSecret != "" {
+		if err := g.Verifier.SetSecret(cfg.WebhookSecret); err != nil {
+			return fmt.Errorf("RESEND_WEBHOOK_SECRET: %w", err)
+		}
+	}
+	g.Service.Reconfigure(cfg.SendTimeout, opts...)
+	if g.router != nil {
+		g.router.SetKeys(resendRoutes(cfg), cfg.ResendAPIKey)
+	}
+	return nil
+}
+
+// resendRoutes converts the configured routes to Resend API key routes.
+func resendRoutes(cfg config.Config) []resendclient.Route {
+	routes := make([]resendclient.Route, 0, len(cfg.Routes))
+	for _, r := range cfg.Routes {
+		routes = append(routes, resendclient.Route{Kind: resendclient.MatchKind(r.Match), Value: r.Value, APIKey: r.APIKey})
+	}
+	return routes
+}
+
+// serviceOptions builds the transformation steps and policies of the service.
+func serviceOptions(cfg config.Config) ([]app.Option, error) {
+	var opts []app.Option
+	if cfg.GenerateText {
+		opts = append(opts, app.WithTransforms(app.GeneratePlainText()))
+	}
+	senders, err := senderPolicy(cfg)
+	if err != nil {
+		return nil, err
+	}
+	recipients, err := recipientPolicy(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return append(opts, app.WithSenderPolicy(senders), app.WithRecipientPolicy(recipients)), nil
+}
+
+// captureStore opens the on-disk capture store when CAPTURE_DIR is set and
+// falls back to a bounded in-memory store otherwise.
+func captureStore(cfg config.Config) (capture.Store, error) {
+	if cfg.CaptureDir == "" {
+		return capture.NewMemoryStore(cfg.CaptureMaxMessages), nil
+	}
+	store, err := capture.NewDiskStore(cfg.CaptureDir)
+	if err != nil {
+		return nil, fmt.Errorf("CAPTURE_DIR: %w", err)
+	}
+	return store, nil
+}
+
+// suppressionList opens the suppression list with the configured expiry rules.
+func suppressionList(cfg config.Config) (*suppression.List, error) {
+	ttl := make(map[suppression.Reason]time.Duration, len(cfg.SuppressionTTL))
+	for reason, d := range cfg.SuppressionTTL {
+		ttl[suppression.Reason(reason)] = d
+	}
+	list, err := suppression.Open(cfg.SuppressionFile, ttl)
+	if err != nil {
+		return nil, fmt.Errorf("SUPPRESSION_FILE: %w", err)
+	}
+	return list, nil
+}
+
+// senderPolicy builds the allowed sender policy from the configuration.
+func senderPolicy(cfg config.Config) (*app.SenderPolicy, error) {
+	p := &app.SenderPolicy{Allowed: cfg.AllowedSenders, PerUser: cfg.AllowedSendersByUser}
+	if cfg.SenderRewriteFrom != "" {
+		addr, err := domain.ParseAddress(cfg.SenderRewriteFrom)
+		if err != nil {
+			return nil, fmt.Errorf("SENDER_REWRITE_FROM: %w", err)
+		}
+		p.RewriteTo = addr
+	}
+	return p, nil
+}
+
+// recipientPolicy builds the sandbox recipient policy from the configuration.
+func recipientPolicy(cfg config.Config) (*app.RecipientPolicy, error) {
+	p := &app.RecipientPolicy{
+		Action:    app.SandboxAction(cfg.SandboxMode),
+		Domains:   cfg.SandboxDomains,
+		Addresses: cfg.SandboxAddresses,
+	}
+	for _, expr := range cfg.SandboxPatterns {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("SANDBOX_ALLOWED_PATTERNS: %w", err)
+		}
+		p.Patterns = append(p.Patterns, re)
+	}
+	if cfg.SandboxCatchAll != "" {
+		addr, err := domain.ParseAddress(cfg.SandboxCatchAll)
+		if err != nil {
+			return nil, fmt.Errorf("SANDBOX_CATCH_ALL: %w", err)
+		}
+		p.CatchAll = addr
+	}
+	return p, nil
+}
@@ -0,0 +1,260 @@
+// Package htmltext renders HTML email bodies as readable plain text.
+package htmltext
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Render converts an HTML document or fragment to plain text:
+//   - scripts, styles and the document head are dropped
+//   - block elements and <br> become line breaks
+//   - list items are prefixed with "- " or their number
+//   - table rows become lines with cells separated by spaces
+//   - links are numbered and listed as footnotes at the end
+func Render(src string) string {
+	r := &renderer{}
+	z := html.NewTokenizer(strings.NewReader(src))
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			return r.finish()
+		case html.TextToken:
+			if r.skip == 0 {
+				r.text(string(z.Text()))
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			r.start(tok, tt == html.SelfClosingTagToken)
+		case html.EndTagToken:
+			r.end(z.Token())
+		}
+	}
+}
+
+type list struct {
+	ordered bool
+	n       int
+}
+
+type link struct {
+	href  string
+	start int // length of out when the <a> was opened
+}
+
+type renderer struct {
+	out      strings.Builder
+	skip     int // depth inside elements whose content is dropped
+	pre      int // depth inside <pre>
+	breaks   int // line breaks requested before the next text
+	space    bool
+	lists    []list
+	prefix   string // list marker written before the next text
+	links    []link
+	footnote []string
+	cellSeen bool
+}
+
+func (r *renderer) start(tok html.Token, selfClosing bool) {
+	switch tok.DataAtom {
+	case atom.Script, atom.Style, atom.Head, atom.Title, atom.Noscript, atom.Template:
+		if !selfClosing {
+			r.skip++
+		}
+		return
+	}
+	if r.skip > 0 {
+		return
+	}
+	switch tok.DataAtom {
+	case atom.Br:
+		// Pending breaks and markers go before the line break; a pending
+		// space would only trail the line.
+		r.space = false
+		r.flush()
+		r.out.WriteString("\n")
+	case atom.Hr:
+		r.block(2)
+		r.out.WriteString("----")
+		r.block(2)
+	case atom.P, atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Blockquote, atom.Table:
+		r.block(2)
+	case atom.Div, atom.Section, atom.Article, atom.Header, atom.Footer, atom.Center, atom.Dl, atom.Dt, atom.Dd:
+		r.block(1)
+	case atom.Pre:
+		r.block(2)
+		r.pre++
+	case atom.Ul, atom.Ol:
+		r.block(1)
+		r.lists = append(r.lists, list{ordered: tok.DataAtom == atom.Ol})
+	case atom.Li:
+		r.block(1)
+		marker := "- "
+		if n := len(r.lists); n > 0 {
+			l := &r.lists[n-1]
+			if l.ordered {
+				l.n++
+				marker = strconv.Itoa(l.n) + ". "
+			}
+		}
+		r.prefix = strings.Repeat("  ", max(len(r.lists)-1, 0)) + marker
+	case atom.Tr:
+		r.block(1)
+		r.cellSeen = false
+	case atom.Td, atom.Th:
+		if r.cellSeen {
+			r.space = true
+		}
+		r.cellSeen = true
+	case atom.Img:
+		if alt := strings.TrimSpace(attr(tok, "alt")); alt != "" {
+			r.text(alt)
+		}
+	case atom.A:
+		r.links = append(r.links, link{href: strings.TrimSpace(attr(tok, "href")), start: r.out.Len()})
+	}
+}
+
+func (r *renderer) end(tok html.Token) {
+	switch tok.DataAtom {
+	case atom.Script, atom.Style, atom.Head, atom.Title, atom.Noscript, atom.Template:
+		if r.skip > 0 {
+			r.skip--
+		}
+		return
+	}
+	if r.skip > 0 {
+		return
+	}
+	switch tok.DataAtom {
+	case atom.P, atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Blockquote, atom.Table:
+		r.block(2)
+	case atom.Div, atom.Section, atom.Article, atom.Header, atom.Footer, atom.Center, atom.Dl, atom.Dt, atom.Dd, atom.Li, atom.Tr:
+		r.block(1)
+	case atom.Pre:
+		if r.pre > 0 {
+			r.pre--
+		}
+		r.block(2)
+	case atom.Ul, atom.Ol:
+		if n := len(r.lists); n > 0 {
+			r.lists = r.lists[:n-1]
+		}
+		r.block(1)
+	case atom.A:
+		n := len(r.links)
+		if n == 0 {
+			return
+		}
+		l := r.links[n-1]
+		r.links = r.links[:n-1]
+		if !footnoteWorthy(l.href) {
+			return
+		}
+		label := strings.TrimSpace(r.out.String()[l.start:])
+		if label == l.href || "mailto:"+label == l.href {
+			return
+		}
+		r.footnote = append(r.footnote, l.href)
+		fmt.Fprintf(&r.out, " [%d]", len(r.footnote))
+	}
+}
+
+// block requests at least n line breaks before the next piece of text.
+func (r *renderer) block(n int) {
+	if r.out.Len() == 0 {
+		return
+	}
+	r.breaks = max(r.breaks, n)
+	r.space = false
+}
+
+func (r *renderer) text(s string) {
+	if r.pre > 0 {
+		r.flush()
+		r.out.WriteString(s)
+		return
+	}
+	s = strings.ReplaceAll(s, "\u00a0", " ")
+	if s != "" && isSpace(s[0]) {
+		r.space = true
+	}
+	words := strings.Fields(s)
+	for i, w := range words {
+		if i > 0 {
+			r.space = true
+		}
+		r.flush()
+		r.out.WriteString(w)
+	}
+	if s != "" && len(words) > 0 && isSpace(s[len(s)-1]) {
+		r.space = true
+	}
+}
+
+// flush writes pending line breaks, list markers and spaces.
+func (r *renderer) flush() {
+	if r.breaks > 0 {
+		// Line breaks already written by <br> count towards those requested.
+		s := r.out.String()
+		have := len(s) - len(strings.TrimRight(s, "\n"))
+		r.out.WriteString(strings.Repeat("\n", max(r.breaks-have, 0)))
+		r.breaks, r.space = 0, false
+	}
+	if r.prefix != "" {
+		r.out.WriteString(r.prefix)
+		r.prefix, r.space = "", false
+	}
+	if r.space {
+		if s := r.out.String(); s != "" && !strings.HasSuffix(s, "\n") {
+			r.out.WriteByte(' ')
+		}
+		r.space = false
+	}
+}
+
+func (r *renderer) finish() string {
+	lines := strings.Split(r.out.String(), "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimRight(l, " \t")
+	}
+	text := strings.TrimSpace(strings.Join(lines, "\n"))
+	for strings.Contains(text, "\n\n\n") {
+		text = strings.ReplaceAll(text, "\n\n\n", "\n\n")
+	}
+	if len(r.footnote) > 0 {
+		var b strings.Builder
+		b.WriteString(text)
+		b.WriteString("\n\n")
+		for i, href := range r.footnote {
+			fmt.Fprintf(&b, "[%d] %s\n", i+1, href)
+		}
+		text = strings.TrimRight(b.String(), "\n")
+	}
+	return text
+}
+
+func footnoteWorthy(href string) bool {
+	if href == "" || strings.HasPrefix(href, "#") {
+		return false
+	}
+	return !strings.HasPrefix(strings.ToLower(href), "javascript:")
+}
+
+func attr(tok html.Token, name string) string {
+	for _, a := range tok.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r' || b == '\f'
+}
@@ -0,0 +1,59 @@
+package htmltext
+
+import "testing"
+
+func TestRender(t *testing.T) {
+	cases := []struct {
+		name string
+		html string
+		want string
+	}{
+		{
+			name: "paragraphs and breaks",
+			html: "<p>Hello&nbsp;<b>World</b>!</p><p>Line one<br>Line   two</p>",
+			want: "Hello World!\n\nLine one\nLine two",
+		},
+		{
+			name: "breaks after blocks",
+			html: "<p>First</p><br>Second <br> <div>Third</div><br><ul><li><br>Item</li></ul>",
+			want: "First\n\nSecond\nThird\n\n-\nItem",
+		},
+		{
+			name: "scripts and styles dropped",
+			html: "<html><head><title>T</title><style>p{color:red}</style></head><body><script>alert(1)</script><p>Visible</p></body></html>",
+			want: "Visible",
+		},
+		{
+			name: "links as footnotes",
+			html: `<p>Read the <a href="https://example.com/docs">docs</a> or visit <a href="https://example.com">https://example.com</a>.</p><a href="#top">top</a>`,
+			want: "Read the docs [1] or visit https://example.com.\n\ntop\n\n[1] https://example.com/docs",
+		},
+		{
+			name: "lists",
+			html: "<ul><li>One</li><li>Two<ol><li>A</li><li>B</li></ol></li></ul>",
+			want: "- One\n- Two\n  1. A\n  2. B",
+		},
+		{
+			name: "tables flattened",
+			html: "<table><tr><th>Item</th><th>Qty</th></tr><tr><td>Apple</td><td>3</td></tr></table>",
+			want: "Item Qty\nApple 3",
+		},
+		{
+			name: "preformatted",
+			html: "<pre>a  b\n  c</pre>",
+			want: "a  b\n  c",
+		},
+		{
+			name: "image alt",
+			html: `<p><img src="logo.png" alt="ACME"> News</p>`,
+			want: "ACME News",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := Render(c.html); got != c.want {
+				t.Errorf("Render() =\n%q\nwant\n%q", got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+// Package listener opens the gateway's network listeners: TCP addresses,
+// Unix domain sockets and sockets passed by systemd socket activation.
+package listener
+
+import (
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// UnixPath reports whether addr names a Unix socket, written as "unix:/path"
+// or as an absolute path, and returns the socket path.
+func UnixPath(addr string) (string, bool) {
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		return path, true
+	}
+	return addr, strings.HasPrefix(addr, "/")
+}
+
+// Listen opens a TCP listener on addr, or a Unix socket when addr is a
+// UnixPath. A socket file left behind by a previous run is removed first and
+// the new socket gets the permissions mode unless it is zero.
+func Listen(addr string, mode fs.FileMode) (net.Listener, error) {
+	path, ok := UnixPath(addr)
+	if !ok {
+		return net.Listen("tcp", addr)
+	}
+	if fi, err := os.Lstat(path); err == nil && fi.Mode().Type() == fs.ModeSocket {
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if mode != 0 {
+		if err := os.Chmod(path, mode); err != nil {
+			l.Close()
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// Inherited is a listener passed by systemd with its FileDescriptorName.
+type Inherited struct {
+	net.Listener
+	Name string
+}
+
+// listenFdsStart is the first file descriptor passed by systemd (SD_LISTEN_FDS_START).
+const listenFdsStart = 3
+
+// Systemd returns the listeners passed by systemd socket activation, or nil
+// when the process was not socket activated. Like sd_listen_fds(3) it unsets
+// the LISTEN_* variables so that child processes do not inherit them.
+func Systemd() ([]Inherited, error) {
+	pid, fds, names := os.Getenv("LISTEN_PID"), os.Getenv("LISTEN_FDS"), os.Getenv("LISTEN_FDNAMES")
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+	return inherit(pid, fds, names, listenFdsStart)
+}
+
+func inherit(pid, fds, names string, first int) ([]Inherited, error) {
+	if pid == "" || fds == "" {
+		return nil, nil
+	}
+	if p, err := strconv.Atoi(pid); err != nil || p != os.Getpid() {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(fds)
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("invalid LISTEN_FDS %q", fds)
+	}
+	nameList := strings.Split(names, ":")
+	out := make([]Inherited, 0, n)
+	for i := range n {
+		fd := first + i
+		name := ""
+		if i < len(nameList) {
+			name = nameList[i]
+		}
+		// FileListener duplicates the descriptor, so the inherited one is
+		// closed right away.
+		f := os.NewFile(uintptr(fd), name)
+		l, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			for _, o := range out {
+				o.Close()
+			}
+			return nil, fmt.Errorf("LISTEN_FDS: descriptor %d: %w", fd, err)
+		}
+		out = append(out, Inherited{Listener: l, Name: name})
+	}
+	return out, nil
+}
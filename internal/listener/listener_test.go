@@ -0,0 +1,90 @@
+//go:build unix
+
+package listener
+
+import (
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+func TestUnixPath(t *testing.T) {
+	cases := []struct {
+		addr, path string
+		unix       bool
+	}{
+		{":2525", ":2525", false},
+		{"127.0.0.1:2525", "127.0.0.1:2525", false},
+		{"unix:/run/gw.sock", "/run/gw.sock", true},
+		{"/run/gw.sock", "/run/gw.sock", true},
+	}
+	for _, c := range cases {
+		path, ok := UnixPath(c.addr)
+		if path != c.path || ok != c.unix {
+			t.Errorf("UnixPath(%q) = %q, %v", c.addr, path, ok)
+		}
+	}
+}
+
+func TestListen_UnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "smtp.sock")
+	for range 2 { // the second run replaces the stale socket file
+		l, err := Listen("unix:"+path, 0o660)
+		if err != nil {
+			t.Fatalf("listen: %v", err)
+		}
+		fi, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Mode().Type() != fs.ModeSocket || fi.Mode().Perm() != 0o660 {
+			t.Errorf("unexpected socket mode %v", fi.Mode())
+		}
+		// Keep the file: closing a Unix listener unlinks it by default.
+		l.(*net.UnixListener).SetUnlinkOnClose(false)
+		l.Close()
+	}
+
+	regular := filepath.Join(t.TempDir(), "file")
+	os.WriteFile(regular, nil, 0o600)
+	if _, err := Listen(regular, 0); err == nil {
+		t.Error("a regular file must not be replaced by a socket")
+	}
+}
+
+func TestInherit(t *testing.T) {
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tcp.Close()
+	f, err := tcp.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	fd, err := syscall.Dup(int(f.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := inherit(strconv.Itoa(os.Getpid()), "1", "lmtp", fd)
+	if err != nil {
+		t.Fatalf("inherit: %v", err)
+	}
+	if len(got) != 1 || got[0].Name != "lmtp" || got[0].Addr().String() != tcp.Addr().String() {
+		t.Fatalf("unexpected listeners %+v", got)
+	}
+	got[0].Close()
+
+	if got, _ := inherit("1", "1", "", fd); got != nil {
+		t.Error("descriptors passed to another process must be ignored")
+	}
+	if got, _ := inherit("", "", "", listenFdsStart); got != nil {
+		t.Error("expected no listeners without socket activation")
+	}
+}
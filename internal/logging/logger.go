@@ -2,6 +2,7 @@ package logging
 
 import (
 	"context"
+	"io"
 	"log/slog"
 	"os"
 	"strings"
@@ -40,6 +41,12 @@ func (l *StdLogger) Error(msg string, fields map[string]any) {
 // It uses JSON handler for cloud environments and text handler for local development.
 // Log level is controlled by LOG_LEVEL environment variable (default: INFO).
 func NewConfiguredLogger() *slog.Logger {
+	return NewConfiguredLoggerTo(os.Stdout)
+}
+
+// NewConfiguredLoggerTo is NewConfiguredLogger writing to w, for commands
+// whose standard output is reserved for something else.
+func NewConfiguredLoggerTo(w io.Writer) *slog.Logger {
 	// Determine log level from environment variable
 	logLevel := getLogLevel()
 
@@ -50,12 +57,12 @@ func NewConfiguredLogger() *slog.Logger {
 
 	if isCloudRun {
 		// Use JSON handler for cloud environments
-		handler = slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+		handler = slog.NewJSONHandler(w, &slog.HandlerOptions{
 			Level: logLevel,
 		})
 	} else {
 		// Use text handler for local development
-		handler = slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		handler = slog.NewTextHandler(w, &slog.HandlerOptions{
 			Level: logLevel,
 		})
 	}
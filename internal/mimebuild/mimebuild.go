@@ -0,0 +1,180 @@
+// Package mimebuild renders domain emails as RFC 5322 MIME messages.
+package mimebuild
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/igorrius/resend-railway-gateway/internal/domain"
+)
+
+// Build renders email as a MIME message dated at date:
+//   - text and HTML bodies become a multipart/alternative
+//   - attachments wrap the body in a multipart/mixed, base64 encoded
+//   - Bcc is kept so that archived copies record every recipient
+//
+// A Message-ID is generated unless email.Headers provides one; other header
+// fields the message is built from are never taken from email.Headers.
+func Build(email domain.Email, date time.Time) []byte {
+	var b bytes.Buffer
+	h := Header(email, date)
+	body, contentType, qp := buildBody(email)
+	h.Set("Content-Type", contentType)
+	if qp {
+		h.Set("Content-Transfer-Encoding", "quoted-printable")
+	}
+	WriteHeader(&b, h)
+	b.WriteString("\r\n")
+	b.Write(body)
+	return b.Bytes()
+}
+
+// Header returns the top-level header fields of email except Content-Type,
+// for callers that assemble the body themselves.
+func Header(email domain.Email, date time.Time) textproto.MIMEHeader {
+	h := textproto.MIMEHeader{}
+	h.Set("Date", date.Format(time.RFC1123Z))
+	h.Set("From", email.From.String())
+	setList(h, "To", email.To)
+	setList(h, "Cc", email.Cc)
+	setList(h, "Bcc", email.Bcc)
+	setList(h, "Reply-To", email.ReplyTo)
+	h.Set("Subject", mime.QEncoding.Encode("utf-8", email.Subject))
+	h.Set("Message-Id", messageID(email.From.Domain()))
+	h.Set("Mime-Version", "1.0")
+	for k, v := range email.Headers {
+		if k = textproto.CanonicalMIMEHeaderKey(k); !generated[k] && !strings.HasPrefix(k, "Content-") {
+			h.Set(k, v)
+		}
+	}
+	return h
+}
+
+// generated are the header fields rendered from the fields of an email. Copies
+// in email.Headers are stale after policies rewrote the sender or recipients.
+var generated = map[string]bool{
+	"Date":         true,
+	"From":         true,
+	"To":           true,
+	"Cc":           true,
+	"Bcc":          true,
+	"Reply-To":     true,
+	"Subject":      true,
+	"Mime-Version": true,
+}
+
+func setList(h textproto.MIMEHeader, key string, addrs []domain.Address) {
+	if len(addrs) > 0 {
+		h.Set(key, strings.Join(domain.AddressStrings(addrs), ", "))
+	}
+}
+
+// WriteHeader writes h sorted by name so that output is deterministic.
+func WriteHeader(w io.Writer, h textproto.MIMEHeader) {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range h[k] {
+			fmt.Fprintf(w, "%s: %s\r\n", k, v)
+		}
+	}
+}
+
+// buildBody returns the message body, its content type and whether it is a
+// single quoted-printable part.
+func buildBody(email domain.Email) ([]byte, string, bool) {
+	var alt bytes.Buffer
+	var altType string
+	switch {
+	case email.Text != "" && email.HTML != "":
+		mw := multipart.NewWriter(&alt)
+		writeTextPart(mw, "text/plain; charset=utf-8", email.Text)
+		writeTextPart(mw, "text/html; charset=utf-8", email.HTML)
+		mw.Close()
+		altType = "multipart/alternative; boundary=" + mw.Boundary()
+	case email.HTML != "":
+		altType = "text/html; charset=utf-8"
+		writeQP(&alt, email.HTML)
+	default:
+		altType = "text/plain; charset=utf-8"
+		writeQP(&alt, email.Text)
+	}
+	if len(email.Attachments) == 0 {
+		return alt.Bytes(), altType, strings.HasPrefix(altType, "text/")
+	}
+
+	var mixed bytes.Buffer
+	mw := multipart.NewWriter(&mixed)
+	ph := textproto.MIMEHeader{}
+	ph.Set("Content-Type", altType)
+	if strings.HasPrefix(altType, "text/") {
+		ph.Set("Content-Transfer-Encoding", "quoted-printable")
+	}
+	pw, _ := mw.CreatePart(ph)
+	pw.Write(alt.Bytes())
+	for _, a := range email.Attachments {
+		ah := textproto.MIMEHeader{}
+		ct := a.ContentType
+		if ct == "" {
+			ct = mime.TypeByExtension(filepath.Ext(a.Filename))
+		}
+		if ct == "" {
+			ct = "application/octet-stream"
+		}
+		ah.Set("Content-Type", ct)
+		ah.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": a.Filename}))
+		ah.Set("Content-Transfer-Encoding", "base64")
+		aw, _ := mw.CreatePart(ah)
+		writeBase64(aw, a.Content)
+	}
+	mw.Close()
+	return mixed.Bytes(), "multipart/mixed; boundary=" + mw.Boundary(), false
+}
+
+func writeTextPart(mw *multipart.Writer, contentType, body string) {
+	h := textproto.MIMEHeader{}
+	h.Set("Content-Type", contentType)
+	h.Set("Content-Transfer-Encoding", "quoted-printable")
+	w, _ := mw.CreatePart(h)
+	writeQP(w, body)
+}
+
+func writeQP(w io.Writer, s string) {
+	qw := quotedprintable.NewWriter(w)
+	io.WriteString(qw, s)
+	qw.Close()
+}
+
+// writeBase64 writes data base64 encoded in 76 character lines.
+func writeBase64(w io.Writer, data []byte) {
+	enc := base64.StdEncoding.EncodeToString(data)
+	for len(enc) > 76 {
+		io.WriteString(w, enc[:76]+"\r\n")
+		enc = enc[76:]
+	}
+	io.WriteString(w, enc+"\r\n")
+}
+
+func messageID(domain string) string {
+	if domain == "" {
+		domain = "localhost"
+	}
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return "<" + hex.EncodeToString(b) + "@" + domain + ">"
+}
@@ -0,0 +1,89 @@
+package mimebuild_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/igorrius/resend-railway-gateway/internal/adapters/smtp"
+	"github.com/igorrius/resend-railway-gateway/internal/domain"
+	"github.com/igorrius/resend-railway-gateway/internal/mimebuild"
+)
+
+func TestBuild_RoundTrip(t *testing.T) {
+	in := domain.Email{
+		From:        domain.Address{Name: "Zoë", Addr: "zoe@example.com"},
+		To:          []domain.Address{{Addr: "a@example.com"}, {Name: "B", Addr: "b@example.com"}},
+		Cc:          []domain.Address{{Addr: "c@example.com"}},
+		ReplyTo:     []domain.Address{{Addr: "r@example.com"}},
+		Subject:     "Greetings",
+		Text:        "Hello = world," + strings.Repeat(" long line", 20),
+		HTML:        "<p>Hello</p>",
+		Headers:     map[string]string{"X-Campaign": "fall"},
+		Attachments: []domain.Attachment{{Filename: "data.bin", Content: bytes.Repeat([]byte{0, 1, 2, 255}, 100)}},
+	}
+	raw := mimebuild.Build(in, time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+	if !bytes.Contains(raw, []byte("Date: Fri, 02 Jan 2026 03:04:05 +0000\r\n")) {
+		t.Errorf("missing date header in\n%s", raw)
+	}
+
+	out := smtp.ParseMIMEMessage("", domain.AddressStrings(in.To), raw)
+	if out.From != in.From || out.Subject != in.Subject {
+		t.Errorf("from/subject: got %v %q", out.From, out.Subject)
+	}
+	if len(out.To) != 2 || out.To[1] != in.To[1] || len(out.Cc) != 1 || len(out.ReplyTo) != 1 {
+		t.Errorf("recipients: to=%v cc=%v reply_to=%v", out.To, out.Cc, out.ReplyTo)
+	}
+	if strings.TrimSpace(out.Text) != in.Text || strings.TrimSpace(out.HTML) != in.HTML {
+		t.Errorf("bodies: text=%q html=%q", out.Text, out.HTML)
+	}
+	if len(out.Attachments) != 1 || !bytes.Equal(out.Attachments[0].Content, in.Attachments[0].Content) {
+		t.Errorf("attachments: %+v", out.Attachments)
+	}
+	if out.Headers["X-Campaign"] != "fall" {
+		t.Errorf("custom header lost: %v", out.Headers)
+	}
+}
+
+func TestBuild_TextOnly(t *testing.T) {
+	raw := mimebuild.Build(domain.Email{
+		From:    domain.Address{Addr: "a@example.com"},
+		To:      []domain.Address{{Addr: "b@example.com"}},
+		Subject: "Hi",
+		Text:    "plain body",
+	}, time.Now())
+	if !bytes.Contains(raw, []byte("Content-Type: text/plain; charset=utf-8\r\n")) {
+		t.Errorf("expected a single text/plain part:\n%s", raw)
+	}
+	if out := smtp.ParseMIMEMessage("", nil, raw); strings.TrimSpace(out.Text) != "plain body" {
+		t.Errorf("unexpected text %q", out.Text)
+	}
+}
+
+func TestBuild_GeneratedHeadersWin(t *testing.T) {
+	raw := mimebuild.Build(domain.Email{
+		From:    domain.Address{Addr: "rewritten@example.com"},
+		To:      []domain.Address{{Addr: "catch-all@example.com"}},
+		Subject: "Hi",
+		Text:    "plain body",
+		Headers: map[string]string{
+			"from":                      "original@other.org",
+			"To":                        "customer@example.net",
+			"Mime-Version":              "2.0",
+			"Content-Transfer-Encoding": "base64",
+			"Message-Id":                "<original@other.org>",
+			"X-Campaign":                "fall",
+		},
+	}, time.Now())
+	for _, unwanted := range []string{"From: original", "customer@example.net", "2.0", "base64"} {
+		if bytes.Contains(raw, []byte(unwanted)) {
+			t.Errorf("caller header %q leaked into\n%s", unwanted, raw)
+		}
+	}
+	for _, want := range []string{"From: rewritten@example.com\r\n", "To: catch-all@example.com\r\n", "Message-Id: <original@other.org>\r\n", "X-Campaign: fall\r\n"} {
+		if !bytes.Contains(raw, []byte(want)) {
+			t.Errorf("missing %q in\n%s", want, raw)
+		}
+	}
+}
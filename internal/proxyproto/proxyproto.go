@@ -0,0 +1,233 @@
+// Package proxyproto implements the receiving side of the HAProxy PROXY
+// protocol (versions 1 and 2) as a net.Listener wrapper.
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultHeaderTimeout bounds how long a trusted peer may take to send the header.
+const DefaultHeaderTimeout = 5 * time.Second
+
+var (
+	v1Prefix    = []byte("PROXY ")
+	v2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+)
+
+// ErrInvalidHeader is returned when a trusted peer sends a missing or malformed header.
+var ErrInvalidHeader = errors.New("proxyproto: invalid PROXY protocol header")
+
+// Listener wraps a net.Listener and strips PROXY protocol headers sent by
+// trusted proxies. Connections from trusted peers must start with a v1 or v2
+// header; their RemoteAddr reports the original client. Connections from
+// other peers are passed through untouched.
+type Listener struct {
+	net.Listener
+	// Trusted lists the proxy networks whose headers are honoured.
+	Trusted []netip.Prefix
+	// HeaderTimeout bounds reading the header; zero means DefaultHeaderTimeout.
+	HeaderTimeout time.Duration
+}
+
+// Accept waits for the next connection. Header parsing is deferred to the
+// first Read or RemoteAddr call so that a slow peer cannot block Accept.
+func (l *Listener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if !l.trusted(c.RemoteAddr()) {
+		return c, nil
+	}
+	timeout := l.HeaderTimeout
+	if timeout == 0 {
+		timeout = DefaultHeaderTimeout
+	}
+	return &Conn{Conn: c, r: bufio.NewReader(c), timeout: timeout}, nil
+}
+
+func (l *Listener) trusted(addr net.Addr) bool {
+	tcp, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	ip := tcp.AddrPort().Addr().Unmap()
+	for _, p := range l.Trusted {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Conn is a connection from a trusted proxy.
+type Conn struct {
+	net.Conn
+	r       *bufio.Reader
+	timeout time.Duration
+
+	once   sync.Once
+	err    error
+	remote net.Addr
+
+	mu       sync.Mutex
+	deadline time.Time // read deadline set by the caller
+}
+
+// SetDeadline sets the read and write deadlines, remembering the read
+// deadline so that header parsing does not discard it.
+func (c *Conn) SetDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deadline = t
+	return c.Conn.SetDeadline(t)
+}
+
+// SetReadDeadline sets the read deadline, remembering it so that header
+// parsing does not discard it.
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deadline = t
+	return c.Conn.SetReadDeadline(t)
+}
+
+// Read reads connection data following the PROXY header.
+func (c *Conn) Read(b []byte) (int, error) {
+	if err := c.init(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(b)
+}
+
+// RemoteAddr returns the client address announced by the proxy, or the
+// proxy's own address for LOCAL/UNKNOWN headers.
+func (c *Conn) RemoteAddr() net.Addr {
+	if c.init() != nil || c.remote == nil {
+		return c.Conn.RemoteAddr()
+	}
+	return c.remote
+}
+
+func (c *Conn) init() error {
+	c.once.Do(func() {
+		// The header deadline never extends one the caller already set, and
+		// the caller's deadline is restored once the header has been read.
+		c.mu.Lock()
+		deadline := time.Now().Add(c.timeout)
+		if !c.deadline.IsZero() && c.deadline.Before(deadline) {
+			deadline = c.deadline
+		}
+		_ = c.Conn.SetReadDeadline(deadline)
+		c.mu.Unlock()
+		c.remote, c.err = readHeader(c.r)
+		c.mu.Lock()
+		_ = c.Conn.SetReadDeadline(c.deadline)
+		c.mu.Unlock()
+		if c.err != nil {
+			_ = c.Conn.Close()
+		}
+	})
+	return c.err
+}
+
+// readHeader parses a v1 or v2 header. A nil address means the proxy did not
+// convey a client address (LOCAL command or UNKNOWN protocol).
+func readHeader(r *bufio.Reader) (net.Addr, error) {
+	sig, err := r.Peek(len(v1Prefix))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidHeader, err)
+	}
+	if bytes.Equal(sig, v1Prefix) {
+		return readV1(r)
+	}
+	sig, err = r.Peek(len(v2Signature))
+	if err == nil && bytes.Equal(sig, v2Signature) {
+		return readV2(r)
+	}
+	return nil, ErrInvalidHeader
+}
+
+// readV1 parses "PROXY TCP4 src dst sport dport\r\n" (at most 107 bytes).
+func readV1(r *bufio.Reader) (net.Addr, error) {
+	var line []byte
+	for len(line) < 107 {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidHeader, err)
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, ErrInvalidHeader
+	}
+	fields := strings.Fields(string(line[:len(line)-2]))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, ErrInvalidHeader
+	}
+	ip, err := netip.ParseAddr(fields[2])
+	if err != nil || ip.Is4() != (fields[1] == "TCP4") {
+		return nil, ErrInvalidHeader
+	}
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if err != nil {
+		return nil, ErrInvalidHeader
+	}
+	return net.TCPAddrFromAddrPort(netip.AddrPortFrom(ip, uint16(port))), nil
+}
+
+// readV2 parses the binary v2 header, ignoring any TLVs.
+func readV2(r *bufio.Reader) (net.Addr, error) {
+	hdr := make([]byte, 16)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidHeader, err)
+	}
+	if hdr[12]>>4 != 2 {
+		return nil, ErrInvalidHeader
+	}
+	command, family := hdr[12]&0x0f, hdr[13]
+	body := make([]byte, binary.BigEndian.Uint16(hdr[14:16]))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidHeader, err)
+	}
+	switch command {
+	case 0x0: // LOCAL: health checks from the proxy itself
+		return nil, nil
+	case 0x1: // PROXY
+	default:
+		return nil, ErrInvalidHeader
+	}
+	switch family {
+	case 0x11: // TCP over IPv4
+		if len(body) < 12 {
+			return nil, ErrInvalidHeader
+		}
+		ip := netip.AddrFrom4([4]byte(body[0:4]))
+		return net.TCPAddrFromAddrPort(netip.AddrPortFrom(ip, binary.BigEndian.Uint16(body[8:10]))), nil
+	case 0x21: // TCP over IPv6
+		if len(body) < 36 {
+			return nil, ErrInvalidHeader
+		}
+		ip := netip.AddrFrom16([16]byte(body[0:16])).Unmap()
+		return net.TCPAddrFromAddrPort(netip.AddrPortFrom(ip, binary.BigEndian.Uint16(body[32:34]))), nil
+	default: // UNSPEC or non-TCP families carry no usable client address
+		return nil, nil
+	}
+}
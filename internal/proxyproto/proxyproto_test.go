@@ -0,0 +1,143 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadHeader(t *testing.T) {
+	v2 := func(command, family byte, body []byte) string {
+		var b bytes.Buffer
+		b.Write(v2Signature)
+		b.WriteByte(0x20 | command)
+		b.WriteByte(family)
+		_ = binary.Write(&b, binary.BigEndian, uint16(len(body)))
+		b.Write(body)
+		return b.String()
+	}
+	v4body := append(append([]byte{192, 0, 2, 10}, 198, 51, 100, 1), 0x30, 0x39, 0x00, 0x19)
+	v6body := make([]byte, 36)
+	copy(v6body, netip.MustParseAddr("2001:db8::1").AsSlice())
+	binary.BigEndian.PutUint16(v6body[32:], 4242)
+
+	cases := []struct {
+		name   string
+		header string
+		want   string
+		err    bool
+	}{
+		{"v1 tcp4", "PROXY TCP4 192.0.2.10 198.51.100.1 12345 25\r\n", "192.0.2.10:12345", false},
+		{"v1 tcp6", "PROXY TCP6 2001:db8::1 2001:db8::2 4242 25\r\n", "[2001:db8::1]:4242", false},
+		{"v1 unknown", "PROXY UNKNOWN\r\n", "", false},
+		{"v1 family mismatch", "PROXY TCP4 2001:db8::1 2001:db8::2 1 25\r\n", "", true},
+		{"v1 no crlf", "PROXY TCP4 192.0.2.10 198.51.100.1 12345 25\n", "", true},
+		{"v2 tcp4", v2(0x1, 0x11, v4body), "192.0.2.10:12345", false},
+		{"v2 tcp6", v2(0x1, 0x21, v6body), "[2001:db8::1]:4242", false},
+		{"v2 local", v2(0x0, 0x00, nil), "", false},
+		{"missing header", "EHLO client\r\n", "", true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			addr, err := readHeader(bufio.NewReader(strings.NewReader(c.header + "EHLO x\r\n")))
+			if c.err {
+				if err == nil {
+					t.Fatalf("expected error, got %v", addr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got := ""
+			if addr != nil {
+				got = addr.String()
+			}
+			if got != c.want {
+				t.Errorf("expected %q, got %q", c.want, got)
+			}
+		})
+	}
+}
+
+func TestListener(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer inner.Close()
+
+	for _, tc := range []struct {
+		name    string
+		trusted []netip.Prefix
+		send    string
+		remote  string
+		payload string
+	}{
+		{"trusted proxy", []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")}, "PROXY TCP4 203.0.113.9 127.0.0.1 5555 25\r\nhello", "203.0.113.9:5555", "hello"},
+		{"untrusted peer", nil, "PROXY TCP4 203.0.113.9 127.0.0.1 5555 25\r\n", "127.0.0.1", "PROXY TCP4"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			l := &Listener{Listener: inner, Trusted: tc.trusted}
+			client, err := net.Dial("tcp", inner.Addr().String())
+			if err != nil {
+				t.Fatalf("dial: %v", err)
+			}
+			defer client.Close()
+			go func() { _, _ = io.WriteString(client, tc.send) }()
+
+			c, err := l.Accept()
+			if err != nil {
+				t.Fatalf("accept: %v", err)
+			}
+			defer c.Close()
+			if got := c.RemoteAddr().String(); !strings.HasPrefix(got, tc.remote) {
+				t.Errorf("expected remote %s, got %s", tc.remote, got)
+			}
+			buf := make([]byte, len(tc.payload))
+			if _, err := io.ReadFull(c, buf); err != nil || string(buf) != tc.payload {
+				t.Errorf("expected payload %q, got %q (%v)", tc.payload, buf, err)
+			}
+		})
+	}
+}
+
+func TestConnKeepsReadDeadline(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer inner.Close()
+	l := &Listener{Listener: inner, Trusted: []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")}}
+	client, err := net.Dial("tcp", inner.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer client.Close()
+	go func() { _, _ = io.WriteString(client, "PROXY TCP4 203.0.113.9 127.0.0.1 5555 25\r\n") }()
+
+	c, err := l.Accept()
+	if err != nil {
+		t.Fatalf("accept: %v", err)
+	}
+	defer c.Close()
+	if err := c.SetReadDeadline(time.Now().Add(50 * time.Millisecond)); err != nil {
+		t.Fatalf("set deadline: %v", err)
+	}
+	// The header arrives but nothing follows, so the caller's deadline must fire.
+	_, err = c.Read(make([]byte, 1))
+	var ne net.Error
+	if !errors.As(err, &ne) || !ne.Timeout() {
+		t.Fatalf("expected timeout, got %v", err)
+	}
+	if got := c.RemoteAddr().String(); got != "203.0.113.9:5555" {
+		t.Errorf("expected remote 203.0.113.9:5555, got %s", got)
+	}
+}
@@ -0,0 +1,258 @@
+// Package ratelimit implements fixed-window message quotas and concurrent
+// connection limits for inbound traffic.
+package ratelimit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Scope identifies what a Rule counts against.
+type Scope string
+
+const (
+	ScopeUser         Scope = "user"
+	ScopeSenderDomain Scope = "domain"
+	ScopeClientIP     Scope = "ip"
+)
+
+// Rule allows at most Limit messages per Window for every distinct value of Scope.
+// Windows are aligned to multiples of Window in UTC, so a 24h window resets at midnight UTC.
+type Rule struct {
+	Scope  Scope
+	Window time.Duration
+	Limit  int
+}
+
+// pruneInterval is how often Allow and Check drop counters whose window has
+// ended, bounding the memory used by scope values that are not seen again.
+const pruneInterval = time.Minute
+
+type counter struct {
+	Start time.Time `json:"start"`
+	Count int       `json:"count"`
+}
+
+// Limiter enforces message quotas. It is safe for concurrent use.
+type Limiter struct {
+	now func() time.Time
+
+	mu        sync.Mutex
+	rules     []Rule
+	counters  map[string]*counter
+	nextPrune time.Time
+}
+
+// New creates a Limiter for the given rules.
+func New(rules []Rule) *Limiter {
+	return &Limiter{rules: rules, now: time.Now, counters: map[string]*counter{}}
+}
+
+// SetRules replaces the rules, e.g. after the configuration was reloaded.
+// Rules with the scope and window of an old rule keep its counters.
+func (l *Limiter) SetRules(rules []Rule) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rules = rules
+}
+
+// Allow records one message for the given scope values if every applicable
+// rule has capacity left. Otherwise nothing is recorded and the returned
+// duration tells when the most restrictive exhausted window resets.
+// Scopes with an empty value are ignored.
+func (l *Limiter) Allow(subjects map[Scope]string) (time.Duration, bool) {
+	return l.take(subjects, true)
+}
+
+// Check is Allow without recording the message, for refusing a message
+// early whose acceptance is decided later; Record then counts it.
+func (l *Limiter) Check(subjects map[Scope]string) (time.Duration, bool) {
+	return l.take(subjects, false)
+}
+
+// Record counts one accepted message for the given scope values, even when
+// it exceeds a quota because it was checked before others were recorded.
+func (l *Limiter) Record(subjects map[Scope]string) {
+	if l == nil {
+		return
+	}
+	now := l.now().UTC()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, r := range l.rules {
+		if value := subjects[r.Scope]; value != "" {
+			l.counterLocked(r, value, now).Count++
+		}
+	}
+}
+
+// take checks every applicable rule for capacity and, if record is set and
+// all have some, counts the message.
+func (l *Limiter) take(subjects map[Scope]string, record bool) (time.Duration, bool) {
+	if l == nil {
+		return 0, true
+	}
+	now := l.now().UTC()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.rules) == 0 {
+		return 0, true
+	}
+	if !now.Before(l.nextPrune) {
+		l.pruneLocked(now)
+		l.nextPrune = now.Add(pruneInterval)
+	}
+
+	var hits []*counter
+	var retry time.Duration
+	for _, r := range l.rules {
+		value := subjects[r.Scope]
+		if value == "" {
+			continue
+		}
+		c := l.counterLocked(r, value, now)
+		if c.Count >= r.Limit {
+			retry = max(retry, c.Start.Add(r.Window).Sub(now))
+			continue
+		}
+		hits = append(hits, c)
+	}
+	if retry > 0 {
+		return retry, false
+	}
+	if record {
+		for _, c := range hits {
+			c.Count++
+		}
+	}
+	return 0, true
+}
+
+// counterLocked returns the counter of rule r for value in the window
+// containing now, starting a new one when the previous window has ended.
+func (l *Limiter) counterLocked(r Rule, value string, now time.Time) *counter {
+	start := now.Truncate(r.Window)
+	key := counterKey(r, value)
+	c := l.counters[key]
+	if c == nil || !c.Start.Equal(start) {
+		c = &counter{Start: start}
+		l.counters[key] = c
+	}
+	return c
+}
+
+func counterKey(r Rule, value string) string {
+	return fmt.Sprintf("%s|%s|%s", r.Scope, r.Window, strings.ToLower(value))
+}
+
+// Load restores counters saved by Save. A missing file is not an error.
+// Counters whose window has already ended are discarded.
+func (l *Limiter) Load(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	saved := map[string]*counter{}
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return fmt.Errorf("ratelimit: decode %s: %w", path, err)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.counters = saved
+	l.pruneLocked(l.now().UTC())
+	return nil
+}
+
+// Save atomically writes the live counters to path as JSON.
+func (l *Limiter) Save(path string) error {
+	l.mu.Lock()
+	l.pruneLocked(l.now().UTC())
+	data, err := json.Marshal(l.counters)
+	l.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".ratelimit-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// pruneLocked drops counters whose window has ended or that no rule uses.
+func (l *Limiter) pruneLocked(now time.Time) {
+	windows := map[string]time.Duration{}
+	for _, r := range l.rules {
+		windows[string(r.Scope)+"|"+r.Window.String()] = r.Window
+	}
+	for key, c := range l.counters {
+		parts := strings.SplitN(key, "|", 3)
+		if len(parts) != 3 {
+			delete(l.counters, key)
+			continue
+		}
+		w, ok := windows[parts[0]+"|"+parts[1]]
+		if !ok || !c.Start.Add(w).After(now) {
+			delete(l.counters, key)
+		}
+	}
+}
+
+// ConnLimiter caps concurrent connections per client. It is safe for concurrent use.
+type ConnLimiter struct {
+	max int
+
+	mu     sync.Mutex
+	active map[string]int
+}
+
+// NewConnLimiter allows at most max concurrent connections per client; zero disables the limit.
+func NewConnLimiter(max int) *ConnLimiter {
+	return &ConnLimiter{max: max, active: map[string]int{}}
+}
+
+// Acquire reserves a connection slot for client and reports whether one was available.
+func (c *ConnLimiter) Acquire(client string) bool {
+	if c == nil || c.max <= 0 || client == "" {
+		return true
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.active[client] >= c.max {
+		return false
+	}
+	c.active[client]++
+	return true
+}
+
+// Release frees a slot reserved by a successful Acquire.
+func (c *ConnLimiter) Release(client string) {
+	if c == nil || c.max <= 0 || client == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.active[client] <= 1 {
+		delete(c.active, client)
+		return
+	}
+	c.active[client]--
+}
@@ -0,0 +1,162 @@
+package ratelimit
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func fixedClock(t *time.Time) func() time.Time { return func() time.Time { return *t } }
+
+func TestLimiter_Allow(t *testing.T) {
+	now := time.Date(2030, 1, 1, 10, 0, 30, 0, time.UTC)
+	l := New([]Rule{
+		{Scope: ScopeUser, Window: time.Minute, Limit: 2},
+		{Scope: ScopeClientIP, Window: 24 * time.Hour, Limit: 3},
+	})
+	l.now = fixedClock(&now)
+	alice := map[Scope]string{ScopeUser: "alice", ScopeClientIP: "192.0.2.1"}
+
+	for i := 0; i < 2; i++ {
+		if _, ok := l.Allow(alice); !ok {
+			t.Fatalf("message %d should be allowed", i+1)
+		}
+	}
+	retry, ok := l.Allow(alice)
+	if ok || retry != 30*time.Second {
+		t.Fatalf("expected rejection with 30s retry, got ok=%v retry=%v", ok, retry)
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok := l.Allow(alice); !ok {
+		t.Fatalf("expected new minute window to allow the message")
+	}
+	retry, ok = l.Allow(map[Scope]string{ScopeUser: "bob", ScopeClientIP: "192.0.2.1"})
+	if ok || retry != 13*time.Hour+58*time.Minute+30*time.Second {
+		t.Fatalf("expected daily IP quota to be exhausted until midnight, got ok=%v retry=%v", ok, retry)
+	}
+}
+
+func TestLimiter_RejectedMessageNotCounted(t *testing.T) {
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := New([]Rule{
+		{Scope: ScopeUser, Window: time.Hour, Limit: 1},
+		{Scope: ScopeSenderDomain, Window: time.Hour, Limit: 5},
+	})
+	l.now = fixedClock(&now)
+	l.Allow(map[Scope]string{ScopeUser: "alice", ScopeSenderDomain: "acme.com"})
+	for i := 0; i < 3; i++ {
+		l.Allow(map[Scope]string{ScopeUser: "alice", ScopeSenderDomain: "acme.com"})
+	}
+	for i, user := range []string{"bob", "carol", "dave", "erin"} {
+		if _, ok := l.Allow(map[Scope]string{ScopeUser: user, ScopeSenderDomain: "acme.com"}); !ok {
+			t.Fatalf("domain quota should not count rejected messages (message %d)", i+1)
+		}
+	}
+}
+
+func TestLimiter_CheckAndRecord(t *testing.T) {
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := New([]Rule{{Scope: ScopeUser, Window: time.Hour, Limit: 1}})
+	l.now = fixedClock(&now)
+	alice := map[Scope]string{ScopeUser: "alice"}
+	for i := 0; i < 3; i++ {
+		if _, ok := l.Check(alice); !ok {
+			t.Fatal("Check must not count the message")
+		}
+	}
+	l.Record(alice)
+	if _, ok := l.Check(alice); ok {
+		t.Error("a recorded message should exhaust the quota")
+	}
+	l.Record(alice)
+	now = now.Add(time.Hour)
+	if _, ok := l.Check(alice); !ok {
+		t.Error("the quota should reset with the window")
+	}
+}
+
+func TestLimiter_PrunesEndedWindows(t *testing.T) {
+	now := time.Date(2030, 1, 1, 10, 0, 0, 0, time.UTC)
+	l := New([]Rule{{Scope: ScopeSenderDomain, Window: time.Minute, Limit: 10}})
+	l.now = fixedClock(&now)
+	for _, domain := range []string{"a.example", "b.example", "c.example"} {
+		l.Allow(map[Scope]string{ScopeSenderDomain: domain})
+	}
+	if len(l.counters) != 3 {
+		t.Fatalf("expected 3 counters, got %d", len(l.counters))
+	}
+
+	now = now.Add(pruneInterval + time.Minute)
+	l.Allow(map[Scope]string{ScopeSenderDomain: "d.example"})
+	if len(l.counters) != 1 || l.counters[counterKey(l.rules[0], "d.example")] == nil {
+		t.Errorf("expected only the live counter to remain, got %v", l.counters)
+	}
+}
+
+func TestLimiter_SetRules(t *testing.T) {
+	now := time.Date(2030, 1, 1, 10, 0, 0, 0, time.UTC)
+	l := New(nil)
+	l.now = fixedClock(&now)
+	alice := map[Scope]string{ScopeUser: "alice"}
+	l.Allow(alice)
+
+	l.SetRules([]Rule{{Scope: ScopeUser, Window: time.Minute, Limit: 2}})
+	for i := 0; i < 2; i++ {
+		if _, ok := l.Allow(alice); !ok {
+			t.Fatalf("message %d should be allowed", i+1)
+		}
+	}
+	// A raised limit keeps counting from where the old one was.
+	l.SetRules([]Rule{{Scope: ScopeUser, Window: time.Minute, Limit: 3}})
+	if _, ok := l.Allow(alice); !ok {
+		t.Fatal("expected the raised limit to allow one more message")
+	}
+	if _, ok := l.Allow(alice); ok {
+		t.Fatal("expected the raised limit to be exhausted")
+	}
+}
+
+func TestLimiter_Persistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quota.json")
+	now := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	rules := []Rule{{Scope: ScopeUser, Window: 24 * time.Hour, Limit: 1}}
+
+	l := New(rules)
+	l.now = fixedClock(&now)
+	l.Allow(map[Scope]string{ScopeUser: "alice"})
+	if err := l.Save(path); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	restarted := New(rules)
+	restarted.now = fixedClock(&now)
+	if err := restarted.Load(path); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if _, ok := restarted.Allow(map[Scope]string{ScopeUser: "alice"}); ok {
+		t.Fatalf("expected daily quota to survive a restart")
+	}
+
+	now = now.Add(12 * time.Hour)
+	if err := restarted.Load(path); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if _, ok := restarted.Allow(map[Scope]string{ScopeUser: "alice"}); !ok {
+		t.Fatalf("expected expired counters to be discarded on load")
+	}
+}
+
+func TestConnLimiter(t *testing.T) {
+	c := NewConnLimiter(1)
+	if !c.Acquire("192.0.2.1") || c.Acquire("192.0.2.1") {
+		t.Fatalf("expected exactly one connection to be allowed")
+	}
+	if !c.Acquire("192.0.2.2") {
+		t.Fatalf("limits must be per client")
+	}
+	c.Release("192.0.2.1")
+	if !c.Acquire("192.0.2.1") {
+		t.Fatalf("expected slot to be released")
+	}
+}
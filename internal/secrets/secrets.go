@@ -0,0 +1,44 @@
+// Package secrets reads credentials from sources other than the environment:
+// files such as Docker and Kubernetes mounted secrets, and secret managers.
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Source resolves a reference to the current value of a secret. The syntax
+// of references depends on the source.
+type Source interface {
+	Fetch(ctx context.Context, ref string) (string, error)
+}
+
+// Files is a Source reading each secret from a file. A reference is a path,
+// relative to Dir unless it is absolute. Trailing line breaks are removed, as
+// most tools that write secret files add one. Missing files are errors
+// matching fs.ErrNotExist.
+type Files struct {
+	Dir string
+}
+
+// Fetch reads the file named by ref.
+func (f Files) Fetch(_ context.Context, ref string) (string, error) {
+	path := ref
+	if f.Dir != "" && !filepath.IsAbs(ref) {
+		path = filepath.Join(f.Dir, ref)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	value := strings.TrimRight(string(data), "\r\n")
+	if value == "" {
+		return "", fmt.Errorf("%s: empty secret", path)
+	}
+	return value, nil
+}
+
+var _ Source = Files{}
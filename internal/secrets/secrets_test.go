@@ -0,0 +1,105 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFiles(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "resend_api_key"), []byte("re_file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if v, err := (Files{Dir: dir}).Fetch(ctx, "resend_api_key"); err != nil || v != "re_file" {
+		t.Errorf("relative: %q, %v", v, err)
+	}
+	if v, err := (Files{}).Fetch(ctx, filepath.Join(dir, "resend_api_key")); err != nil || v != "re_file" {
+		t.Errorf("absolute: %q, %v", v, err)
+	}
+	if _, err := (Files{Dir: dir}).Fetch(ctx, "missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("missing: %v", err)
+	}
+}
+
+// vaultStub serves KV secrets at /v1/<path> for requests with the token.
+func vaultStub(t *testing.T, token string, secrets map[string]string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != token {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		body, ok := secrets[strings.TrimPrefix(r.URL.Path, "/v1/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestVault(t *testing.T) {
+	srv := vaultStub(t, "s.token", map[string]string{
+		"secret/data/gateway": `{"data":{"data":{"resend_api_key":"re_v2","admin":"adm"},"metadata":{"version":3}}}`,
+		"kv/gateway":          `{"data":{"resend_api_key":"re_v1"}}`,
+		"secret/data/number":  `{"data":{"data":{"port":25},"metadata":{"version":1}}}`,
+	})
+	v := NewVault(srv.URL+"/", WithToken("s.token"))
+	ctx := context.Background()
+
+	cases := map[string]string{
+		"secret/data/gateway#resend_api_key": "re_v2",
+		"/secret/data/gateway#admin":         "adm",
+		"kv/gateway":                         "re_v1",
+	}
+	for ref, want := range cases {
+		if got, err := v.Fetch(ctx, ref); err != nil || got != want {
+			t.Errorf("%s: got %q, %v, want %q", ref, got, err, want)
+		}
+	}
+	errs := map[string]string{
+		"secret/data/gateway":         "name one of the fields [admin resend_api_key]",
+		"secret/data/gateway#missing": `no field "missing"`,
+		"secret/data/number#port":     "expected a non-empty string",
+		"secret/data/absent#x":        "404 Not Found",
+		"#x":                          "invalid reference",
+	}
+	for ref, want := range errs {
+		if _, err := v.Fetch(ctx, ref); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: got %v, want %q", ref, err, want)
+		}
+	}
+	if _, err := NewVault(srv.URL, WithToken("wrong")).Fetch(ctx, "kv/gateway"); err == nil || !strings.Contains(err.Error(), "403 Forbidden: permission denied") {
+		t.Errorf("wrong token: %v", err)
+	}
+}
+
+func TestVault_TokenFile(t *testing.T) {
+	srv := vaultStub(t, "s.renewed", map[string]string{"kv/gateway": `{"data":{"key":"re_v1"}}`})
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte("s.old\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	v := NewVault(srv.URL, WithTokenFile(path))
+	if _, err := v.Fetch(context.Background(), "kv/gateway"); err == nil {
+		t.Fatal("expected the old token to be rejected")
+	}
+	if err := os.WriteFile(path, []byte("s.renewed\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := v.Fetch(context.Background(), "kv/gateway"); err != nil || got != "re_v1" {
+		t.Errorf("got %q, %v", got, err)
+	}
+}
@@ -0,0 +1,148 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Vault is a Source reading secrets from the HTTP API of HashiCorp Vault or
+// a compatible server (OpenBao). A reference is the API path of a secret and
+// the field holding the value, as in "secret/data/gateway#resend_api_key";
+// the field may be omitted when the secret has a single one. Both versions of
+// the key/value secrets engine are understood: a KV v2 path includes "data/".
+type Vault struct {
+	addr      string
+	token     string
+	tokenFile string
+	namespace string
+	client    *http.Client
+}
+
+// VaultOption configures a Vault source.
+type VaultOption func(*Vault)
+
+// WithToken authenticates requests with a Vault token.
+func WithToken(token string) VaultOption {
+	return func(v *Vault) { v.token = token }
+}
+
+// WithTokenFile reads the token from a file before every request, so that a
+// token renewed by Vault Agent or a Kubernetes projection is picked up. It
+// takes precedence over WithToken.
+func WithTokenFile(path string) VaultOption {
+	return func(v *Vault) { v.tokenFile = path }
+}
+
+// WithNamespace sends requests to a Vault Enterprise namespace.
+func WithNamespace(ns string) VaultOption {
+	return func(v *Vault) { v.namespace = ns }
+}
+
+// WithHTTPClient replaces the HTTP client, which times out after 10 seconds.
+func WithHTTPClient(c *http.Client) VaultOption {
+	return func(v *Vault) { v.client = c }
+}
+
+// NewVault creates a Vault source for the server at addr, such as
+// "https://vault.example.com:8200".
+func NewVault(addr string, opts ...VaultOption) *Vault {
+	v := &Vault{addr: strings.TrimRight(addr, "/"), client: &http.Client{Timeout: 10 * time.Second}}
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v
+}
+
+// Fetch reads the secret at the path of ref and returns its field.
+func (v *Vault) Fetch(ctx context.Context, ref string) (string, error) {
+	path, field, _ := strings.Cut(ref, "#")
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return "", fmt.Errorf("vault: invalid reference %q, expected path#field", ref)
+	}
+	token := v.token
+	if v.tokenFile != "" {
+		var err error
+		if token, err = (Files{}).Fetch(ctx, v.tokenFile); err != nil {
+			return "", fmt.Errorf("vault: token: %w", err)
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.addr+"/v1/"+path, nil)
+	if err != nil {
+		return "", fmt.Errorf("vault: %w", err)
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if v.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.namespace)
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("vault: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("vault: %s: %w", path, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var e struct{ Errors []string }
+		_ = json.Unmarshal(body, &e)
+		msg := resp.Status
+		if len(e.Errors) > 0 {
+			msg += ": " + strings.Join(e.Errors, "; ")
+		}
+		return "", fmt.Errorf("vault: %s: %s", path, msg)
+	}
+	var secret struct {
+		Data map[string]any
+	}
+	if err := json.Unmarshal(body, &secret); err != nil {
+		return "", fmt.Errorf("vault: %s: %w", path, err)
+	}
+	data := secret.Data
+	// KV v2 wraps the fields with their metadata.
+	if inner, ok := data["data"].(map[string]any); ok && data["metadata"] != nil {
+		data = inner
+	}
+	value, err := pick(data, field)
+	if err != nil {
+		return "", fmt.Errorf("vault: %s: %w", path, err)
+	}
+	return value, nil
+}
+
+// pick returns the string field of a secret, or its only field when field is empty.
+func pick(data map[string]any, field string) (string, error) {
+	if field == "" {
+		if len(data) != 1 {
+			fields := make([]string, 0, len(data))
+			for k := range data {
+				fields = append(fields, k)
+			}
+			slices.Sort(fields)
+			return "", fmt.Errorf("name one of the fields %v with #field", fields)
+		}
+		for k := range data {
+			field = k
+		}
+	}
+	raw, ok := data[field]
+	if !ok {
+		return "", fmt.Errorf("no field %q", field)
+	}
+	value, ok := raw.(string)
+	if !ok || value == "" {
+		return "", fmt.Errorf("%s: expected a non-empty string", field)
+	}
+	return value, nil
+}
+
+var _ Source = (*Vault)(nil)
@@ -0,0 +1,29 @@
+package suppression
+
+import (
+	"strings"
+
+	"github.com/igorrius/resend-railway-gateway/internal/delivery"
+)
+
+// FromEvent returns the entries a delivery event calls for: permanent
+// bounces and complaints suppress every recipient of the event.
+func FromEvent(ev delivery.Event) []Entry {
+	var reason Reason
+	switch ev.Status() {
+	case delivery.StatusBounced:
+		if !strings.EqualFold(ev.BounceType, "Permanent") {
+			return nil
+		}
+		reason = ReasonBounce
+	case delivery.StatusComplained:
+		reason = ReasonComplaint
+	default:
+		return nil
+	}
+	entries := make([]Entry, 0, len(ev.To))
+	for _, to := range ev.To {
+		entries = append(entries, Entry{Address: to, Reason: reason, Detail: ev.Detail, CreatedAt: ev.CreatedAt})
+	}
+	return entries
+}
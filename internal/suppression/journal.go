@@ -0,0 +1,132 @@
+package suppression
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// The list file holds one JSON record per line. Changes are appended and
+// synced, so a bounce costs one short write however long the list is, and
+// the file is rewritten without superseded and expired records when it
+// has grown to more than twice the live entries.
+
+// record is a line of the list file: an entry added or an address removed.
+type record struct {
+	Add    *Entry `json:"add,omitempty"`
+	Remove string `json:"remove,omitempty"`
+}
+
+// compactMin is the number of records below which the file is not compacted.
+const compactMin = 1000
+
+// load restores the entries from the contents of the list file. Files
+// written by earlier versions hold a JSON array of entries. An incomplete
+// last line, left by a crash while appending, is ignored.
+func (l *List) load(data []byte) error {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		var entries []Entry
+		if err := json.Unmarshal(trimmed, &entries); err != nil {
+			return fmt.Errorf("suppression: decode %s: %w", l.path, err)
+		}
+		for _, e := range entries {
+			l.entries[normalize(e.Address)] = e
+		}
+		return nil
+	}
+	lines := bytes.Split(data, []byte("\n"))
+	for i, line := range lines {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var r record
+		if err := json.Unmarshal(line, &r); err != nil {
+			if i == len(lines)-1 {
+				break
+			}
+			return fmt.Errorf("suppression: decode %s line %d: %w", l.path, i+1, err)
+		}
+		switch {
+		case r.Add != nil:
+			l.entries[normalize(r.Add.Address)] = *r.Add
+		case r.Remove != "":
+			delete(l.entries, normalize(r.Remove))
+		}
+	}
+	return nil
+}
+
+// appendLocked writes records to the list file and syncs it, compacting the
+// file when it has grown enough. l.mu must be held.
+func (l *List) appendLocked(records []record) error {
+	if l.file == nil {
+		return nil
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	if _, err := l.file.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	if err := l.file.Sync(); err != nil {
+		return err
+	}
+	l.records += len(records)
+	if l.records >= compactMin && l.records > 2*len(l.entries) {
+		return l.compactLocked()
+	}
+	return nil
+}
+
+// compactLocked atomically replaces the list file with one record per
+// unexpired entry and reopens it for appending. l.mu must be held.
+func (l *List) compactLocked() error {
+	entries := l.entriesLocked()
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for i := range entries {
+		if err := enc.Encode(record{Add: &entries[i]}); err != nil {
+			return err
+		}
+	}
+	dir := filepath.Dir(l.path)
+	tmp, err := os.CreateTemp(dir, ".suppression-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), l.path); err != nil {
+		return err
+	}
+	// Persist the rename itself; not every platform can sync a directory.
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	if l.file != nil {
+		l.file.Close()
+	}
+	l.file, err = os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return err
+	}
+	l.records = len(entries)
+	return nil
+}
@@ -0,0 +1,257 @@
+// Package suppression keeps a persistent list of recipients mail must not
+// be sent to, e.g. because they hard-bounced or complained.
+package suppression
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/igorrius/resend-railway-gateway/internal/domain"
+)
+
+// Reason records why an address was suppressed.
+type Reason string
+
+const (
+	// ReasonBounce is a permanent (hard) bounce reported by the provider.
+	ReasonBounce Reason = "bounce"
+	// ReasonComplaint is a spam complaint by the recipient.
+	ReasonComplaint Reason = "complaint"
+	// ReasonRejected is a permanent rejection returned when sending.
+	ReasonRejected Reason = "rejected"
+	// ReasonManual is an entry added by an operator.
+	ReasonManual Reason = "manual"
+)
+
+// Entry is a suppressed address.
+type Entry struct {
+	Address   string    `json:"address"`
+	Reason    Reason    `json:"reason"`
+	Detail    string    `json:"detail,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// ExpiresAt is when the entry lapses; the zero time means never.
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+}
+
+func (e Entry) expired(now time.Time) bool {
+	return !e.ExpiresAt.IsZero() && !now.Before(e.ExpiresAt)
+}
+
+// List is a set of suppressed addresses. When opened with a path every
+// change is appended to the file and synced before it returns (see
+// journal.go). It is safe for concurrent use.
+type List struct {
+	path string
+	// ttl gives entries added without ExpiresAt an expiry per reason.
+	ttl map[Reason]time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]Entry
+	file    *os.File // the list file opened for appending
+	records int      // records in the list file
+}
+
+// Open loads the list stored at path, which need not exist yet, and
+// compacts the file. An empty path keeps the list in memory only. ttl sets
+// how long entries of each reason are kept; reasons without a positive TTL
+// never expire.
+func Open(path string, ttl map[Reason]time.Duration) (*List, error) {
+	l := &List{path: path, ttl: ttl, now: time.Now, entries: map[string]Entry{}}
+	if path == "" {
+		return l, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if err := l.load(data); err != nil {
+		return nil, err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.compactLocked(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Close closes the list file. The list must not be changed afterwards.
+func (l *List) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// normalize lower-cases addresses and converts internationalized domains to
+// ASCII so that lookups match however the address was written.
+func normalize(addr string) string {
+	return strings.ToLower(domain.NormalizeAddr(strings.TrimSpace(addr)))
+}
+
+// Get returns the unexpired entry for addr.
+func (l *List) Get(addr string) (Entry, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e, ok := l.entries[normalize(addr)]
+	if !ok || e.expired(l.now()) {
+		return Entry{}, false
+	}
+	return e, true
+}
+
+// Add suppresses e.Address, replacing any existing entry. CreatedAt defaults
+// to now and ExpiresAt to the TTL configured for e.Reason.
+func (l *List) Add(e Entry) error {
+	return l.AddAll([]Entry{e})
+}
+
+// AddAll adds several entries with a single write to the list file. Nothing
+// is added when an address is invalid.
+func (l *List) AddAll(entries []Entry) error {
+	now := l.now().UTC()
+	records := make([]record, 0, len(entries))
+	for _, e := range entries {
+		addr := normalize(e.Address)
+		if !strings.Contains(addr, "@") {
+			return fmt.Errorf("suppression: invalid address %q", e.Address)
+		}
+		e.Address = addr
+		if e.Reason == "" {
+			e.Reason = ReasonManual
+		}
+		if e.CreatedAt.IsZero() {
+			e.CreatedAt = now
+		}
+		if ttl := l.ttl[e.Reason]; e.ExpiresAt.IsZero() && ttl > 0 {
+			e.ExpiresAt = e.CreatedAt.Add(ttl)
+		}
+		records = append(records, record{Add: &e})
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, r := range records {
+		l.entries[r.Add.Address] = *r.Add
+	}
+	return l.appendLocked(records)
+}
+
+// Remove deletes the entry for addr and reports whether there was one.
+func (l *List) Remove(addr string) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	addr = normalize(addr)
+	if _, ok := l.entries[addr]; !ok {
+		return false, nil
+	}
+	delete(l.entries, addr)
+	return true, l.appendLocked([]record{{Remove: addr}})
+}
+
+// Entries returns all unexpired entries sorted by address.
+func (l *List) Entries() []Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.entriesLocked()
+}
+
+func (l *List) entriesLocked() []Entry {
+	now := l.now()
+	out := make([]Entry, 0, len(l.entries))
+	for _, e := range l.entries {
+		if !e.expired(now) {
+			out = append(out, e)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Address < out[j].Address })
+	return out
+}
+
+// csvHeader is the first line of the import/export format.
+var csvHeader = []string{"address", "reason", "detail", "created_at", "expires_at"}
+
+// Export writes all unexpired entries as CSV with the columns address,
+// reason, detail, created_at and expires_at (RFC 3339, empty for never).
+func (l *List) Export(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, e := range l.Entries() {
+		expires := ""
+		if !e.ExpiresAt.IsZero() {
+			expires = e.ExpiresAt.Format(time.RFC3339)
+		}
+		if err := cw.Write([]string{e.Address, string(e.Reason), e.Detail, e.CreatedAt.Format(time.RFC3339), expires}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Import reads entries in the Export format and adds them, returning how
+// many were imported. Only the address column is required; the header line
+// is optional.
+func (l *List) Import(r io.Reader) (int, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	var entries []Entry
+	for line := 1; ; line++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		if line == 1 && len(rec) > 0 && strings.EqualFold(rec[0], csvHeader[0]) {
+			continue
+		}
+		e, err := parseRecord(rec)
+		if err != nil {
+			return 0, fmt.Errorf("suppression: line %d: %w", line, err)
+		}
+		entries = append(entries, e)
+	}
+	if err := l.AddAll(entries); err != nil {
+		return 0, err
+	}
+	return len(entries), nil
+}
+
+func parseRecord(rec []string) (Entry, error) {
+	field := func(i int) string {
+		if i < len(rec) {
+			return strings.TrimSpace(rec[i])
+		}
+		return ""
+	}
+	e := Entry{Address: field(0), Reason: Reason(field(1)), Detail: field(2)}
+	for i, t := range []*time.Time{&e.CreatedAt, &e.ExpiresAt} {
+		v := field(3 + i)
+		if v == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return Entry{}, fmt.Errorf("%s must be RFC 3339: %q", csvHeader[3+i], v)
+		}
+		*t = parsed
+	}
+	return e, nil
+}
@@ -0,0 +1,171 @@
+package suppression
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/igorrius/resend-railway-gateway/internal/delivery"
+)
+
+func TestList_PersistAndExpire(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "suppressions.json")
+	l, err := Open(path, map[Reason]time.Duration{ReasonBounce: 24 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	l.now = func() time.Time { return now }
+
+	if err := l.Add(Entry{Address: "Dead@Example.com", Reason: ReasonBounce, Detail: "550 no such user"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Add(Entry{Address: "angry@example.com", Reason: ReasonComplaint}); err != nil {
+		t.Fatal(err)
+	}
+	if e, ok := l.Get("dead@example.COM"); !ok || !e.ExpiresAt.Equal(now.Add(24*time.Hour)) {
+		t.Fatalf("unexpected entry %+v (ok=%v)", e, ok)
+	}
+
+	// Reopening restores the entries.
+	l, err = Open(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.now = func() time.Time { return now.Add(25 * time.Hour) }
+	if _, ok := l.Get("dead@example.com"); ok {
+		t.Error("bounce entry should have expired")
+	}
+	if e, ok := l.Get("angry@example.com"); !ok || !e.ExpiresAt.IsZero() {
+		t.Errorf("complaint entry should never expire, got %+v (ok=%v)", e, ok)
+	}
+
+	removed, err := l.Remove("ANGRY@example.com")
+	if err != nil || !removed {
+		t.Fatalf("Remove: %v %v", removed, err)
+	}
+	if len(l.Entries()) != 0 {
+		t.Errorf("expected no entries, got %v", l.Entries())
+	}
+}
+
+func TestList_ImportExport(t *testing.T) {
+	l, _ := Open("", nil)
+	n, err := l.Import(strings.NewReader(`address,reason,detail,created_at,expires_at
+a@example.com,bounce,"550 5.1.1 user unknown, sorry",2030-01-01T00:00:00Z,
+b@example.com
+`))
+	if err != nil || n != 2 {
+		t.Fatalf("Import: %d %v", n, err)
+	}
+	if e, _ := l.Get("b@example.com"); e.Reason != ReasonManual {
+		t.Errorf("expected manual reason by default, got %q", e.Reason)
+	}
+
+	var buf bytes.Buffer
+	if err := l.Export(&buf); err != nil {
+		t.Fatal(err)
+	}
+	other, _ := Open("", nil)
+	if n, err := other.Import(&buf); err != nil || n != 2 {
+		t.Fatalf("re-import: %d %v", n, err)
+	}
+	if e, _ := other.Get("a@example.com"); e.Detail != "550 5.1.1 user unknown, sorry" || e.CreatedAt.Year() != 2030 {
+		t.Errorf("unexpected round-tripped entry %+v", e)
+	}
+
+	if _, err := l.Import(strings.NewReader("not-an-address\n")); err == nil {
+		t.Error("expected an error for an invalid address")
+	}
+	if _, err := l.Import(strings.NewReader("c@example.com,bounce,,yesterday\n")); err == nil {
+		t.Error("expected an error for an invalid timestamp")
+	}
+}
+
+func TestFromEvent(t *testing.T) {
+	cases := []struct {
+		name string
+		ev   delivery.Event
+		want Reason
+	}{
+		{"hard bounce", delivery.Event{Type: "email.bounced", BounceType: "Permanent"}, ReasonBounce},
+		{"soft bounce", delivery.Event{Type: "email.bounced", BounceType: "Transient"}, ""},
+		{"complaint", delivery.Event{Type: "email.complained"}, ReasonComplaint},
+		{"delivered", delivery.Event{Type: "email.delivered"}, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			c.ev.To = []string{"a@example.com", "b@example.com"}
+			entries := FromEvent(c.ev)
+			if c.want == "" {
+				if len(entries) != 0 {
+					t.Errorf("expected no entries, got %v", entries)
+				}
+				return
+			}
+			if len(entries) != 2 || entries[0].Reason != c.want || entries[1].Address != "b@example.com" {
+				t.Errorf("unexpected entries %v", entries)
+			}
+		})
+	}
+}
+
+func TestList_Journal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "suppressions.json")
+	// Files written by earlier versions hold a JSON array.
+	if err := os.WriteFile(path, []byte(`[{"address":"old@example.com","reason":"bounce","created_at":"2030-01-01T00:00:00Z"}]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	l, err := Open(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := l.Add(Entry{Address: fmt.Sprintf("u%d@example.com", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := l.Remove("u1@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+	data, _ := os.ReadFile(path)
+	if lines := strings.Count(string(data), "\n"); lines != 5 {
+		t.Errorf("expected 1 compacted and 4 appended records, got %d lines:\n%s", lines, data)
+	}
+
+	// A crash while appending leaves an incomplete last line.
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	f.WriteString(`{"add":{"address":"torn@exa`)
+	f.Close()
+	l, err = Open(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range l.Entries() {
+		got = append(got, e.Address)
+	}
+	if strings.Join(got, ",") != "old@example.com,u0@example.com,u2@example.com" {
+		t.Errorf("unexpected entries %v", got)
+	}
+
+	// Superseded records are dropped once the file has grown enough.
+	for i := 0; i < compactMin; i++ {
+		if err := l.Add(Entry{Address: "flappy@example.com"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if l.records > 2*len(l.entries) {
+		t.Errorf("expected the file to be compacted, %d records for %d entries", l.records, len(l.entries))
+	}
+	l.Close()
+	data, _ = os.ReadFile(path)
+	if lines := strings.Count(string(data), "\n"); lines != l.records {
+		t.Errorf("file has %d lines, want %d", lines, l.records)
+	}
+}